@@ -1,16 +1,25 @@
 package main
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"time"
+	_ "time/tzdata" // embed the IANA time zone database for hosts without tzdata
 
 	"github.com/moniquelin/monday-hr/internal/api"
 	"github.com/moniquelin/monday-hr/internal/data"
 	"github.com/moniquelin/monday-hr/internal/database"
+	"github.com/moniquelin/monday-hr/internal/keys"
+	"github.com/moniquelin/monday-hr/internal/live"
+	"github.com/moniquelin/monday-hr/internal/mailer"
+	"github.com/moniquelin/monday-hr/internal/oidc"
+	"github.com/moniquelin/monday-hr/internal/secretbox"
 )
 
 func main() {
@@ -20,15 +29,53 @@ func main() {
 	// Read the value of the port and env command-line flags into the config struct
 	flag.IntVar(&cfg.Port, "port", 4000, "API server port")
 	flag.StringVar(&cfg.Env, "env", "development", "Environment (development|staging|production)")
+	flag.BoolVar(&cfg.TrustProxy, "trust-proxy", false, "Read client IP addresses from X-Forwarded-For set by a reverse proxy")
 	flag.StringVar(&cfg.Db.Dsn, "db-dsn", os.Getenv("MONDAY_HR_DB_DSN"), "PostgreSQL DSN")
+	flag.StringVar(&cfg.Timezone, "timezone", "Asia/Jakarta", "Default time zone for employees without an office")
+	flag.StringVar(&cfg.Attendance.WorkdayEnd, "workday-end", "17:00", "Scheduled end of the workday (HH:MM, local time)")
+	flag.StringVar(&cfg.Attendance.AutoCheckoutAt, "auto-checkout-at", "23:00", "Daily time (HH:MM) to close attendances without a check-out, empty to disable")
+	flag.DurationVar(&cfg.Roster.MinRest, "min-rest", 11*time.Hour, "Minimum rest between two rostered working shifts")
+	flag.StringVar(&cfg.Jwt.Algorithm, "jwt-alg", keys.EdDSA, "Algorithm of new token signing keys (EdDSA or RS256)")
+	flag.DurationVar(&cfg.Jwt.KeyRotation, "jwt-key-rotation", 30*24*time.Hour, "How long a token signing key is used before it is rotated")
+	flag.StringVar(&cfg.Jwt.Issuer, "jwt-issuer", "monday-hr", "Issuer (iss claim) of access tokens")
+	flag.DurationVar(&cfg.Jwt.AccessTTL, "access-token-ttl", 15*time.Minute, "Lifetime of access tokens")
+	flag.DurationVar(&cfg.Jwt.RefreshTTL, "refresh-token-ttl", 30*24*time.Hour, "Lifetime of refresh tokens")
+	flag.BoolVar(&cfg.TwoFactor.RequireForAdmins, "require-admin-2fa", true, "Require admins to enable two-factor authentication")
+	flag.StringVar(&cfg.TwoFactor.Issuer, "2fa-issuer", "monday-hr", "Issuer name shown in authenticator apps")
+	flag.StringVar(&cfg.OIDC.Issuer, "oidc-issuer", "", "Issuer URL of the OpenID Connect identity provider, empty to disable single sign-on")
+	flag.StringVar(&cfg.OIDC.ClientID, "oidc-client-id", "monday-hr", "Client ID registered with the identity provider")
+	flag.StringVar(&cfg.OIDC.ClientSecret, "oidc-client-secret", os.Getenv("MONDAY_HR_OIDC_CLIENT_SECRET"), "Client secret registered with the identity provider, empty for a public client")
+	flag.StringVar(&cfg.OIDC.RedirectURL, "oidc-redirect-url", "http://localhost:5173/auth/callback", "URL the identity provider sends users back to")
+	flag.BoolVar(&cfg.OIDC.AutoProvision, "oidc-auto-provision", false, "Create an employee account for users logging in with single sign-on for the first time")
+	flag.StringVar(&cfg.Smtp.Host, "smtp-host", "", "SMTP host, empty to only log emails")
+	flag.IntVar(&cfg.Smtp.Port, "smtp-port", 1025, "SMTP port")
+	flag.StringVar(&cfg.Smtp.Username, "smtp-username", "", "SMTP username")
+	flag.StringVar(&cfg.Smtp.Password, "smtp-password", os.Getenv("MONDAY_HR_SMTP_PASSWORD"), "SMTP password")
+	flag.StringVar(&cfg.Smtp.Sender, "smtp-sender", "monday-hr <no-reply@monday-hr.local>", "SMTP sender")
+	flag.DurationVar(&cfg.Sync.MaxAge, "sync-max-age", 72*time.Hour, "Maximum age of offline attendance events accepted by sync")
 	flag.Parse()
 
-	// Define JWT secret key
+	// Fail fast on an unknown default time zone rather than on the first check-in
+	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+		log.Fatalf("invalid -timezone: %v", err)
+	}
+
+	// Define the secret the token signing keys and TOTP secrets are encrypted
+	// with
 	cfg.Jwt.Secret = os.Getenv("MONDAY_HR_JWT_SECRET")
 	if cfg.Jwt.Secret == "" {
 		log.Fatal("missing MONDAY_HR_JWT_SECRET environment variable")
 	}
 
+	// Define kiosk QR signing key, derived from the JWT secret when not set so
+	// that the two keys are never the same
+	cfg.Kiosk.Secret = os.Getenv("MONDAY_HR_KIOSK_SECRET")
+	if cfg.Kiosk.Secret == "" {
+		mac := hmac.New(sha256.New, []byte(cfg.Jwt.Secret))
+		mac.Write([]byte("monday-hr kiosk qr"))
+		cfg.Kiosk.Secret = hex.EncodeToString(mac.Sum(nil))
+	}
+
 	// Initialize a logger which writes messages to the standard out stream
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
 
@@ -44,11 +91,56 @@ func main() {
 
 	logger.Printf("database connection pool established")
 
+	// Send emails through SMTP when configured, otherwise only log them
+	var mail mailer.Mailer = mailer.NewLog(logger)
+	if cfg.Smtp.Host != "" {
+		mail = mailer.NewSMTP(cfg.Smtp.Host, cfg.Smtp.Port, cfg.Smtp.Username, cfg.Smtp.Password, cfg.Smtp.Sender)
+	}
+
 	// Declare an instance of the application struct
 	app := &api.Application{
 		Config: cfg,
 		Logger: logger,
 		Models: data.NewModels(db),
+		Mailer: mail,
+	}
+
+	// Load the token signing keys, creating the first one if needed, and keep
+	// rotating them
+	keyring, err := keys.NewKeyring(app.Models.SigningKeys, cfg.Jwt.Secret, cfg.Jwt.Algorithm,
+		cfg.Jwt.KeyRotation, cfg.Jwt.AccessTTL, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	go keyring.Run()
+	app.Keys = keyring
+
+	app.TOTPSecrets, err = secretbox.New(cfg.Jwt.Secret, "monday-hr totp secrets")
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// Let users log in with the identity provider when configured
+	if cfg.OIDC.Issuer != "" {
+		app.OIDC = oidc.NewProvider(cfg.OIDC.Issuer, cfg.OIDC.ClientID, cfg.OIDC.ClientSecret, cfg.OIDC.RedirectURL)
+	}
+
+	// Listen for attendance changes to stream to admins
+	broker, err := live.NewBroker(cfg.Db.Dsn, data.AttendanceEventsChannel, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer broker.Close()
+	go broker.Run()
+	app.Live = broker
+
+	// Start the background jobs
+	sched, err := newScheduler(cfg, logger, app.Models)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if sched != nil {
+		go sched.run()
 	}
 
 	// Declare a HTTP server
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/api"
+	"github.com/moniquelin/monday-hr/internal/data"
+)
+
+const (
+	// Name of the auto-checkout job in the job_runs table
+	autoCheckoutJob = "auto_checkout"
+	// How many missed days are caught up at most after a long downtime
+	maxCatchUpDays = 31
+	// Delay before retrying after a failed run
+	jobRetryInterval = 10 * time.Minute
+)
+
+// scheduler runs the daily background jobs of the API server
+type scheduler struct {
+	cfg    api.Config
+	logger *log.Logger
+	models data.Models
+	loc    *time.Location
+	// Time of day of the auto-checkout run, as parsed from the config
+	runAt time.Time
+}
+
+// newScheduler validates the scheduling config. It returns nil if automatic
+// check-out is disabled.
+func newScheduler(cfg api.Config, logger *log.Logger, models data.Models) (*scheduler, error) {
+	if cfg.Attendance.AutoCheckoutAt == "" {
+		return nil, nil
+	}
+
+	runAt, err := time.Parse("15:04", cfg.Attendance.AutoCheckoutAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -auto-checkout-at: must be HH:MM")
+	}
+	if _, err := time.Parse("15:04", cfg.Attendance.WorkdayEnd); err != nil {
+		return nil, fmt.Errorf("invalid -workday-end: must be HH:MM")
+	}
+
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scheduler{cfg: cfg, logger: logger, models: models, loc: loc, runAt: runAt}, nil
+}
+
+// run catches up on the days missed while the server was down, then runs the
+// auto-checkout job once a day. It never returns.
+func (s *scheduler) run() {
+	for {
+		wait := time.Until(s.nextRun(time.Now()))
+		if err := s.runDue(time.Now()); err != nil {
+			s.logger.Printf("auto checkout: %v", err)
+			wait = min(wait, jobRetryInterval)
+		}
+		time.Sleep(wait)
+	}
+}
+
+// runAtOn returns the time the job is scheduled on the day of t
+func (s *scheduler) runAtOn(t time.Time) time.Time {
+	t = t.In(s.loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), s.runAt.Hour(), s.runAt.Minute(), 0, 0, s.loc)
+}
+
+// nextRun returns the first scheduled run after now
+func (s *scheduler) nextRun(now time.Time) time.Time {
+	next := s.runAtOn(now)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// runDue runs the job for every day since the last successful run whose
+// scheduled time has passed, oldest first, and stops at the first failure
+func (s *scheduler) runDue(now time.Time) error {
+	latest := s.runAtOn(now)
+	if latest.After(now) {
+		latest = latest.AddDate(0, 0, -1)
+	}
+	latestDate := dateOf(latest)
+
+	first := latestDate
+	last, err := s.models.JobRuns.LastSucceeded(autoCheckoutJob)
+	switch {
+	case err == nil:
+		lastDate, err := time.Parse(time.DateOnly, last)
+		if err != nil {
+			return err
+		}
+		first = lastDate.AddDate(0, 0, 1)
+	case errors.Is(err, data.ErrRecordNotFound):
+		// First run ever: only close the latest day
+	default:
+		return err
+	}
+
+	if earliest := latestDate.AddDate(0, 0, -(maxCatchUpDays - 1)); first.Before(earliest) {
+		first = earliest
+	}
+
+	for day := first; !day.After(latestDate); day = day.AddDate(0, 0, 1) {
+		if err := s.autoCheckout(day.Format(time.DateOnly)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// autoCheckout closes the attendances of the day, and of any earlier day, which
+// are still missing a check-out, and notifies the employees
+func (s *scheduler) autoCheckout(date string) error {
+	run, err := s.models.JobRuns.Start(autoCheckoutJob, date)
+	if err != nil {
+		return err
+	}
+	if run == nil {
+		// Already done, or being done by another server
+		return nil
+	}
+
+	closed, err := s.models.Attendance.CloseOpen(date, s.cfg.Attendance.WorkdayEnd, s.cfg.Timezone)
+	if finishErr := s.models.JobRuns.Finish(run, len(closed), err); finishErr != nil {
+		s.logger.Printf("auto checkout: recording run for %s: %v", date, finishErr)
+	}
+	if err != nil {
+		return fmt.Errorf("closing attendances for %s: %w", date, err)
+	}
+
+	for _, att := range closed {
+		err := s.models.Notifications.Insert(&data.Notification{
+			UserID: att.EmployeeID,
+			Kind:   "auto_checkout",
+			Title:  "You forgot to check out",
+			Message: fmt.Sprintf("You did not check out on %s, so your attendance was closed automatically "+
+				"at the scheduled end of your workday or shift. Please contact HR if this is not correct.", att.AttDate),
+		})
+		if err != nil {
+			s.logger.Printf("auto checkout: notifying employee %d: %v", att.EmployeeID, err)
+		}
+	}
+
+	s.logger.Printf("auto checkout: closed %d attendances for %s", len(closed), date)
+	return nil
+}
+
+// dateOf returns midnight UTC of the calendar day of t, so days can be stepped
+// through without daylight saving surprises
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,264 @@
+// Command mock-oidc is a minimal OpenID Connect provider for trying single
+// sign-on locally. It logs in anyone as the email address they enter, or pass
+// as login_hint, without a password, so it must never be exposed.
+//
+//	go run ./cmd/mock-oidc -port 9000
+//	go run ./cmd/api -oidc-issuer http://localhost:9000 -oidc-auto-provision
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// How long an authorization code can be exchanged for tokens
+const codeTTL = time.Minute
+
+// authorization struct is a code issued to a client, waiting to be exchanged
+type authorization struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	email       string
+	name        string
+	expiry      time.Time
+}
+
+type provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+	kid          string
+
+	mu    sync.Mutex
+	codes map[string]*authorization
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<title>Mock identity provider</title>
+<h1>Mock identity provider</h1>
+<form method="get">
+{{range $name, $values := .}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">
+{{end}}{{end}}<p><label>Email <input name="login_hint" type="email" required autofocus></label></p>
+<p><label>Name <input name="name"></label></p>
+<p><button>Log in</button></p>
+</form>
+`))
+
+func main() {
+	port := flag.Int("port", 9000, "Port to listen on")
+	issuer := flag.String("issuer", "", "Issuer URL (default http://localhost:<port>)")
+	clientID := flag.String("client-id", "monday-hr", "Client ID accepted")
+	clientSecret := flag.String("client-secret", "", "Client secret required, empty for a public client")
+	flag.Parse()
+
+	if *issuer == "" {
+		*issuer = fmt.Sprintf("http://localhost:%d", *port)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	p := &provider{
+		issuer:       *issuer,
+		clientID:     *clientID,
+		clientSecret: *clientSecret,
+		key:          key,
+		kid:          randomString(8),
+		codes:        make(map[string]*authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discoveryHandler)
+	mux.HandleFunc("GET /jwks", p.jwksHandler)
+	mux.HandleFunc("GET /authorize", p.authorizeHandler)
+	mux.HandleFunc("POST /token", p.tokenHandler)
+
+	log.Printf("mock OpenID Connect provider %s for client %q listening on :%d", p.issuer, p.clientID, *port)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", *port), mux))
+}
+
+func (p *provider) discoveryHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"jwks_uri":                              p.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+	})
+}
+
+func (p *provider) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": p.kid,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorizeHandler shows a login form asking for an email address, or logs in
+// as the login_hint right away, and redirects back to the client with a code
+func (p *provider) authorizeHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	if q.Get("client_id") != p.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("response_type") != "code" {
+		http.Error(w, "response_type must be code", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "a S256 code_challenge is required", http.StatusBadRequest)
+		return
+	}
+
+	email := q.Get("login_hint")
+	if email == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		loginPage.Execute(w, q)
+		return
+	}
+
+	code := randomString(24)
+	p.mu.Lock()
+	p.codes[code] = &authorization{
+		redirectURI: redirectURI.String(),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		email:       email,
+		name:        q.Get("name"),
+		expiry:      time.Now().Add(codeTTL),
+	}
+	p.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// tokenHandler exchanges a code for an ID token, checking the client, the
+// redirect URI and the PKCE verifier
+func (p *provider) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostFormValue("client_id")
+		clientSecret = r.PostFormValue("client_secret")
+	}
+	if clientID != p.clientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.clientSecret)) != 1 {
+		tokenError(w, http.StatusUnauthorized, "invalid_client", "unknown client or wrong secret")
+		return
+	}
+
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	// Codes are single-use, even when the exchange fails
+	code := r.PostFormValue("code")
+	p.mu.Lock()
+	auth := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+
+	if auth == nil || time.Now().After(auth.expiry) {
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "invalid or expired code")
+		return
+	}
+	if r.PostFormValue("redirect_uri") != auth.redirectURI {
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri does not match")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match the challenge")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.issuer,
+		"sub":            "mock|" + auth.email,
+		"aud":            p.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          auth.nonce,
+		"email":          auth.email,
+		"email_verified": true,
+	}
+	if auth.name != "" {
+		claims["name"] = auth.name
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.kid
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(24),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func tokenError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, map[string]string{"error": code, "error_description": description})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomString(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
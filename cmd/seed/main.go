@@ -67,13 +67,33 @@ func main() {
 
 	fmt.Println("Created admin user with ID:", admin.ID)
 
-	// 3. CREATE 100 EMPLOYEES
+	// 3. CREATE OFFICES IN EACH INDONESIAN TIME ZONE
+	offices := []*data.Office{
+		{Name: "Jakarta", Timezone: "Asia/Jakarta"},
+		{Name: "Makassar", Timezone: "Asia/Makassar"},
+		{Name: "Jayapura", Timezone: "Asia/Jayapura"},
+	}
+
+	for _, office := range offices {
+		office.CreatedBy = admin.ID
+		office.UpdatedBy = admin.ID
+
+		err = models.Offices.Insert(office)
+		if err != nil {
+			log.Fatal("error inserting office:", err)
+		}
+	}
+
+	fmt.Println("Created", len(offices), "offices.")
+
+	// 4. CREATE 100 EMPLOYEES, SPREAD ACROSS THE OFFICES
 	for i := 1; i <= 100; i++ {
 		u := &data.User{
 			Role:      "employee",
 			Name:      fmt.Sprintf("Employee %d", i),
 			Email:     fmt.Sprintf("employee%d@example.com", i),
 			Salary:    int64(5000000 + i*10000),
+			OfficeID:  &offices[i%len(offices)].ID,
 			CreatedBy: admin.ID,
 			UpdatedBy: admin.ID,
 		}
@@ -0,0 +1,137 @@
+// Package anomaly flags suspicious attendance patterns, such as buddy punching
+// or forgotten check-outs, by running a set of rules over the attendance of
+// each employee. Rules are pluggable: anything implementing Rule can be added
+// to a Detector.
+package anomaly
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+)
+
+// Severity of a finding
+type Severity string
+
+const (
+	SeverityLow    Severity = "low"
+	SeverityMedium Severity = "medium"
+	SeverityHigh   Severity = "high"
+)
+
+// rank orders severities from low to high
+func (s Severity) rank() int {
+	switch s {
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	case SeverityLow:
+		return 1
+	}
+	return 0
+}
+
+// Valid reports whether s is a known severity
+func (s Severity) Valid() bool {
+	return s.rank() > 0
+}
+
+// AtLeast reports whether s is as severe as min or more
+func (s Severity) AtLeast(min Severity) bool {
+	return s.rank() >= min.rank()
+}
+
+// Employee is the attendance of one employee over the scanned range, with the
+// time zone of their office
+type Employee struct {
+	*data.EmployeeAttendance
+	Location *time.Location
+}
+
+// Finding is an attendance record flagged by a rule. PunchID is set when the
+// rule flagged a single punch of the day.
+type Finding struct {
+	Rule         string   `json:"rule"`
+	Severity     Severity `json:"severity"`
+	EmployeeID   int64    `json:"employee_id"`
+	EmployeeName string   `json:"employee_name"`
+	AttendanceID int64    `json:"attendance_id"`
+	Date         string   `json:"date"`
+	PunchID      *int64   `json:"punch_id,omitempty"`
+	Message      string   `json:"message"`
+}
+
+// Rule detects one kind of anomaly in the attendance of an employee. Check only
+// needs to set the attendance, punch, severity and message of its findings;
+// the detector fills in the rule name and employee.
+type Rule interface {
+	Name() string
+	Check(e *Employee) []Finding
+}
+
+// Detector runs rules over the attendance of employees
+type Detector struct {
+	rules []Rule
+}
+
+// NewDetector returns a detector running the given rules
+func NewDetector(rules ...Rule) *Detector {
+	return &Detector{rules: rules}
+}
+
+// DefaultRules returns the rules of the attendance anomaly report with their
+// default thresholds
+func DefaultRules() []Rule {
+	return []Rule{
+		IdenticalCheckIns{MinDays: 3},
+		UnusualIP{MinDays: 5},
+		UnusualDevice{MinDays: 5},
+		LongShift{Max: 14 * time.Hour},
+		InstantCheckOut{Min: time.Minute},
+	}
+}
+
+// Rules returns the names of the detector's rules
+func (d *Detector) Rules() []string {
+	names := make([]string, len(d.rules))
+	for i, r := range d.rules {
+		names[i] = r.Name()
+	}
+	return names
+}
+
+// Scan runs the rules over the attendance of the employee and returns the
+// findings ordered by date
+func (d *Detector) Scan(e *Employee) []Finding {
+	var findings []Finding
+	for _, r := range d.rules {
+		for _, f := range r.Check(e) {
+			f.Rule = r.Name()
+			f.EmployeeID = e.EmployeeID
+			f.EmployeeName = e.EmployeeName
+			findings = append(findings, f)
+		}
+	}
+
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		return cmp.Compare(a.Date, b.Date)
+	})
+	return findings
+}
+
+// finding returns a finding for the attendance, and the punch if not nil
+func finding(day *data.Attendance, p *data.Punch, severity Severity, message string) Finding {
+	f := Finding{
+		Severity:     severity,
+		AttendanceID: day.ID,
+		Date:         day.AttDate,
+		Message:      message,
+	}
+	if p != nil {
+		f.PunchID = &p.ID
+	}
+	return f
+}
@@ -0,0 +1,218 @@
+package anomaly
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+)
+
+// IdenticalCheckIns flags check-ins at exactly the same local time of day, to
+// the second, on MinDays days or more. People do not arrive at the same second
+// every day, scripts and shared credentials do. Punches imported from device
+// logs on the full minute are left out, as the devices or the log only record
+// minutes and would flag everyone who arrives at the same minute.
+type IdenticalCheckIns struct {
+	MinDays int
+}
+
+func (r IdenticalCheckIns) Name() string { return "identical_checkin_times" }
+
+func (r IdenticalCheckIns) Check(e *Employee) []Finding {
+	type checkIn struct {
+		day   *data.Attendance
+		punch *data.Punch
+	}
+
+	byTime := map[string][]checkIn{}
+	var times []string
+	for _, day := range e.Days {
+		for _, p := range day.Punches {
+			if p.Kind != data.PunchIn || minutePrecisionImport(e, p) {
+				continue
+			}
+			t := p.PunchedAt.In(e.Location).Format("15:04:05")
+			if _, ok := byTime[t]; !ok {
+				times = append(times, t)
+			}
+			byTime[t] = append(byTime[t], checkIn{day, p})
+		}
+	}
+
+	var findings []Finding
+	for _, t := range times {
+		checkIns := byTime[t]
+
+		days := map[int64]bool{}
+		for _, c := range checkIns {
+			days[c.day.ID] = true
+		}
+		if len(days) < r.MinDays {
+			continue
+		}
+
+		for _, c := range checkIns {
+			findings = append(findings, finding(c.day, c.punch, SeverityHigh,
+				fmt.Sprintf("checked in at exactly %s on %d days", t, len(days))))
+		}
+	}
+
+	return findings
+}
+
+// minutePrecisionImport reports whether the punch was recorded on behalf of the
+// employee, by an admin importing a device log, without seconds
+func minutePrecisionImport(e *Employee, p *data.Punch) bool {
+	imported := p.CreatedBy != nil && *p.CreatedBy != e.EmployeeID
+	return imported && p.PunchedAt.Second() == 0
+}
+
+// UnusualIP flags punches sent from an IP address the employee used on a single
+// day only, when they punched from known addresses on at least MinDays days
+type UnusualIP struct {
+	MinDays int
+}
+
+func (r UnusualIP) Name() string { return "unusual_ip" }
+
+func (r UnusualIP) Check(e *Employee) []Finding {
+	return unusualSource(e, r.MinDays, "IP address", func(p *data.Punch) *string {
+		return p.IPAddress
+	})
+}
+
+// UnusualDevice flags punches sent from a device (the offline sync device ID,
+// or else the user agent) the employee used on a single day only, when they
+// punched from known devices on at least MinDays days
+type UnusualDevice struct {
+	MinDays int
+}
+
+func (r UnusualDevice) Name() string { return "unusual_device" }
+
+func (r UnusualDevice) Check(e *Employee) []Finding {
+	return unusualSource(e, r.MinDays, "device", func(p *data.Punch) *string {
+		if p.DeviceID != nil {
+			return p.DeviceID
+		}
+		return p.UserAgent
+	})
+}
+
+// unusualSource flags the punches whose source was only seen on one day
+func unusualSource(e *Employee, minDays int, label string, source func(*data.Punch) *string) []Finding {
+	days := map[string]map[int64]bool{}
+	for _, day := range e.Days {
+		for _, p := range day.Punches {
+			s := source(p)
+			if s == nil || *s == "" {
+				continue
+			}
+			if days[*s] == nil {
+				days[*s] = map[int64]bool{}
+			}
+			days[*s][day.ID] = true
+		}
+	}
+
+	// Days on which the employee punched from a source seen on several days
+	known := map[int64]bool{}
+	for _, sourceDays := range days {
+		if len(sourceDays) > 1 {
+			for id := range sourceDays {
+				known[id] = true
+			}
+		}
+	}
+	if len(known) < minDays {
+		return nil
+	}
+
+	var findings []Finding
+	for _, day := range e.Days {
+		flagged := map[string]bool{}
+		for _, p := range day.Punches {
+			s := source(p)
+			if s == nil || *s == "" || len(days[*s]) > 1 || flagged[*s] {
+				continue
+			}
+			flagged[*s] = true
+			findings = append(findings, finding(day, p, SeverityMedium,
+				fmt.Sprintf("punched from an unusual %s: %s", label, truncate(*s, 100))))
+		}
+	}
+
+	return findings
+}
+
+// LongShift flags days on which the employee worked longer than Max, or whose
+// attendance was closed automatically after they forgot to check out
+type LongShift struct {
+	Max time.Duration
+}
+
+func (r LongShift) Name() string { return "long_shift" }
+
+func (r LongShift) Check(e *Employee) []Finding {
+	var findings []Finding
+	for _, day := range e.Days {
+		worked := time.Duration(day.WorkedMinutes) * time.Minute
+		switch {
+		case worked > r.Max:
+			severity := SeverityMedium
+			if worked > 2*r.Max {
+				severity = SeverityHigh
+			}
+			findings = append(findings, finding(day, nil, severity,
+				fmt.Sprintf("worked %s, more than %s", formatDuration(worked), formatDuration(r.Max))))
+		case day.AutoClosed:
+			findings = append(findings, finding(day, nil, SeverityLow,
+				"did not check out, the attendance was closed automatically"))
+		}
+	}
+	return findings
+}
+
+// InstantCheckOut flags check-outs less than Min after the check-in they end,
+// which record presence without any work
+type InstantCheckOut struct {
+	Min time.Duration
+}
+
+func (r InstantCheckOut) Name() string { return "instant_checkout" }
+
+func (r InstantCheckOut) Check(e *Employee) []Finding {
+	var findings []Finding
+	for _, day := range e.Days {
+		var checkIn *data.Punch
+		for _, p := range day.Punches {
+			switch p.Kind {
+			case data.PunchIn:
+				checkIn = p
+			case data.PunchOut:
+				// Automatic check-outs have no author and are not the employee's doing
+				if checkIn != nil && p.CreatedBy != nil {
+					if d := p.PunchedAt.Sub(checkIn.PunchedAt); d < r.Min {
+						findings = append(findings, finding(day, p, SeverityHigh,
+							fmt.Sprintf("checked out %s after checking in", formatDuration(d))))
+					}
+				}
+				checkIn = nil
+			}
+		}
+	}
+	return findings
+}
+
+// formatDuration formats a duration to the second, e.g. "14h2m0s"
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "") + "…"
+}
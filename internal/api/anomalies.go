@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/anomaly"
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// Longest period which can be scanned for anomalies at once
+const maxAnomalyDays = 366
+
+// attendanceAnomaliesHandler lets admins scan the attendance between two dates
+// for suspicious patterns. The findings can be narrowed down to one rule and a
+// minimum severity (low, medium or high).
+func (app *Application) attendanceAnomaliesHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	from, to, rule := qs.Get("from"), qs.Get("to"), qs.Get("rule")
+	minSeverity := anomaly.Severity(qs.Get("severity"))
+	if minSeverity == "" {
+		minSeverity = anomaly.SeverityLow
+	}
+
+	detector := anomaly.NewDetector(anomaly.DefaultRules()...)
+	available := detector.Rules()
+	if rule != "" {
+		var selected []anomaly.Rule
+		for _, candidate := range anomaly.DefaultRules() {
+			if candidate.Name() == rule {
+				selected = append(selected, candidate)
+			}
+		}
+		detector = anomaly.NewDetector(selected...)
+	}
+
+	v := validator.New()
+	start, err := time.Parse("2006-01-02", from)
+	v.Check(err == nil, "from", "must be a valid date (YYYY-MM-DD)")
+	end, err := time.Parse("2006-01-02", to)
+	v.Check(err == nil, "to", "must be a valid date (YYYY-MM-DD)")
+	if v.Valid() {
+		v.Check(!end.Before(start), "to", "must not be before from")
+		v.Check(end.Sub(start) < maxAnomalyDays*24*time.Hour, "to",
+			fmt.Sprintf("must not be more than %d days after from", maxAnomalyDays-1))
+	}
+	v.Check(len(detector.Rules()) > 0, "rule", fmt.Sprintf("must be one of %s", strings.Join(available, ", ")))
+	v.Check(minSeverity.Valid(), "severity", "must be low, medium or high")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	locations := map[string]*time.Location{}
+	findings := []anomaly.Finding{}
+
+	err = app.Models.Attendance.ForEachEmployee(r.Context(), from, to, app.Config.Timezone, func(e *data.EmployeeAttendance) error {
+		loc, ok := locations[e.Timezone]
+		if !ok {
+			var err error
+			loc, err = time.LoadLocation(e.Timezone)
+			if err != nil {
+				return err
+			}
+			locations[e.Timezone] = loc
+		}
+
+		for _, f := range detector.Scan(&anomaly.Employee{EmployeeAttendance: e, Location: loc}) {
+			if f.Severity.AtLeast(minSeverity) {
+				findings = append(findings, f)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"rules":    detector.Rules(),
+		"findings": findings,
+	}, nil)
+}
@@ -2,8 +2,14 @@ package api
 
 import (
 	"log"
+	"time"
 
 	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/keys"
+	"github.com/moniquelin/monday-hr/internal/live"
+	"github.com/moniquelin/monday-hr/internal/mailer"
+	"github.com/moniquelin/monday-hr/internal/oidc"
+	"github.com/moniquelin/monday-hr/internal/secretbox"
 )
 
 // Version number
@@ -13,11 +19,72 @@ const Version = "1.0.0"
 type Config struct {
 	Port int
 	Env  string
-	Db   struct {
+	// Whether the server runs behind a reverse proxy setting X-Forwarded-For
+	TrustProxy bool
+	// Default IANA time zone for employees who are not assigned to an office
+	Timezone string
+	Db       struct {
 		Dsn string
 	}
 	Jwt struct {
+		// Secret the private signing keys and TOTP secrets are encrypted with
 		Secret string
+		// Algorithm of new signing keys, EdDSA or RS256
+		Algorithm string
+		// How long a signing key signs tokens before the next one takes over
+		KeyRotation time.Duration
+		// Issuer (iss claim) of the tokens
+		Issuer string
+		// Lifetime of access tokens
+		AccessTTL time.Duration
+		// Lifetime of refresh tokens, renewed each time one is used
+		RefreshTTL time.Duration
+	}
+	TwoFactor struct {
+		// Whether admins and super admins must enable two-factor authentication
+		// to use the admin endpoints
+		RequireForAdmins bool
+		// Issuer shown in authenticator apps
+		Issuer string
+	}
+	OIDC struct {
+		// Issuer URL of the OpenID Connect identity provider, empty to disable
+		// single sign-on
+		Issuer       string
+		ClientID     string
+		ClientSecret string
+		// URL the identity provider sends users back to, which posts the code
+		// to the callback endpoint
+		RedirectURL string
+		// Whether users logging in for the first time get an employee account
+		AutoProvision bool
+	}
+	Kiosk struct {
+		// Secret used to sign the rotating QR tokens displayed by kiosks
+		Secret string
+	}
+	Attendance struct {
+		// Scheduled end of the workday (HH:MM) in the employee's local time
+		WorkdayEnd string
+		// Time of day (HH:MM, default time zone) at which attendances without a
+		// check-out are closed automatically, empty to disable
+		AutoCheckoutAt string
+	}
+	Roster struct {
+		// Minimum rest between two rostered working shifts of an employee
+		MinRest time.Duration
+	}
+	Smtp struct {
+		// SMTP server emails are sent through, empty to only log them
+		Host     string
+		Port     int
+		Username string
+		Password string
+		Sender   string
+	}
+	Sync struct {
+		// How long after it occurred an offline attendance event may be synced
+		MaxAge time.Duration
 	}
 }
 
@@ -27,4 +94,14 @@ type Application struct {
 	Config Config
 	Logger *log.Logger
 	Models data.Models
+	// Broadcasts attendance changes committed by any API instance
+	Live *live.Broker
+	// Sends the emails of the application
+	Mailer mailer.Mailer
+	// Signs and verifies the access tokens
+	Keys *keys.Keyring
+	// Identity provider users log in with, nil without single sign-on
+	OIDC *oidc.Provider
+	// Encrypts the TOTP secrets of two-factor authentication
+	TOTPSecrets *secretbox.Box
 }
@@ -2,106 +2,435 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
 )
 
-// checkInHandler enables employee to record check in
+const (
+	// How long before a rostered shift starts employees may check in
+	shiftCheckInWindow = time.Hour
+	// How long after a rostered shift ends punches still belong to it
+	shiftCheckOutWindow = 6 * time.Hour
+)
+
+// Action and success message of each kind of punch
+var (
+	punchActions = map[string]string{
+		data.PunchIn:         "check in",
+		data.PunchOut:        "check out",
+		data.PunchBreakStart: "start a break",
+		data.PunchBreakEnd:   "end a break",
+	}
+	punchMessages = map[string]string{
+		data.PunchIn:         "checked-in successfully",
+		data.PunchOut:        "checked-out successfully",
+		data.PunchBreakStart: "break started successfully",
+		data.PunchBreakEnd:   "break ended successfully",
+	}
+)
+
+// attendanceInput holds the optional body of punch requests.
+// The attendance type (office by default) is chosen at the first check-in of
+// the day. Coordinates and kiosk QR token are required when the employee works
+// at the office and is subject to its geofence or kiosk. The source of the
+// punch is set by the server from the request.
+type attendanceInput struct {
+	AttendanceType string   `json:"attendance_type"`
+	Latitude       *float64 `json:"latitude"`
+	Longitude      *float64 `json:"longitude"`
+	KioskToken     string   `json:"kiosk_token"`
+	IPAddress      string   `json:"-"`
+	UserAgent      string   `json:"-"`
+	DeviceID       string   `json:"-"`
+	// Offline event the punch was synced from, resolved along with it
+	SyncEvent *data.SyncEvent `json:"-"`
+}
+
+// attendanceError is returned when attendance cannot be recorded, and carries
+// the status code and message to report back to the employee
+type attendanceError struct {
+	status  int
+	message interface{}
+}
+
+func (e *attendanceError) Error() string {
+	return fmt.Sprint(e.message)
+}
+
+// checkInHandler enables employee to record check in. It is a shortcut for an
+// "in" punch.
 func (app *Application) checkInHandler(w http.ResponseWriter, r *http.Request) {
-	user := app.contextGetUser(r)
+	var input attendanceInput
+	err := app.readOptionalJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
-	// Determine attendance date in WIB
-	loc, _ := time.LoadLocation("Asia/Jakarta")
-	attDateWIB := time.Now().In(loc)
+	app.punch(w, r, data.PunchIn, input)
+}
 
-	// Validate if date is not weekend
-	if attDateWIB.Weekday() == time.Saturday || attDateWIB.Weekday() == time.Sunday {
-		app.errorResponse(w, r, 422, "cannot check in on the weekend")
+// checkOutHandler enables employee to record check out. It is a shortcut for an
+// "out" punch.
+func (app *Application) checkOutHandler(w http.ResponseWriter, r *http.Request) {
+	var input attendanceInput
+	err := app.readOptionalJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	att := &data.Attendance{
-		EmployeeID: user.ID,
-		AttDate:    attDateWIB.Format("2006-01-02"),
-		CheckInAt:  attDateWIB,
-		CreatedBy:  user.ID,
-		UpdatedBy:  user.ID,
+	app.punch(w, r, data.PunchOut, input)
+}
+
+// punchHandler enables employee to check in and out several times a day and to
+// take breaks
+func (app *Application) punchHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Type string `json:"type"`
+		attendanceInput
 	}
 
-	// Record check in
-	err := app.Models.Attendance.RecordCheckIn(att)
+	err := app.readJSON(w, r, &input)
 	if err != nil {
-		switch {
-		// If there is already check in for the date
-		case errors.Is(err, data.ErrDuplicateCheckIn):
-			app.errorResponse(w, r, 409, err)
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	app.writeJSON(w, http.StatusCreated, envelope{
-		"message":    "checked-in successfully",
-		"attendance": att,
-	}, nil)
+	v := validator.New()
+	v.Check(validator.In(input.Type, data.PunchIn, data.PunchOut, data.PunchBreakStart, data.PunchBreakEnd),
+		"type", "must be in, out, break_start or break_end")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	app.punch(w, r, input.Type, input.attendanceInput)
 }
 
-// checkOutHandler enables employee to record check out
-func (app *Application) checkOutHandler(w http.ResponseWriter, r *http.Request) {
+// showAttendanceHandler shows the attendance of the employee on a date (today by
+// default) with its punches, counting an open session or break up to now
+func (app *Application) showAttendanceHandler(w http.ResponseWriter, r *http.Request) {
 	user := app.contextGetUser(r)
 
-	// Determine attendance date in WIB
-	loc, _ := time.LoadLocation("Asia/Jakarta")
-	attDateWIB := time.Now().In(loc)
-
-	// Validate if date is not weekend
-	if attDateWIB.Weekday() == time.Saturday || attDateWIB.Weekday() == time.Sunday {
-		app.errorResponse(w, r, 422, "cannot check out on the weekend")
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		now, err := app.employeeNow(user)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		date = now.Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		app.failedValidationResponse(w, r, map[string]string{"date": "must be a valid date (YYYY-MM-DD)"})
 		return
 	}
 
-	// Check if there is already an attendance data
-	att, err := app.Models.Attendance.Get(user.ID, attDateWIB.Format("2006-01-02"))
+	att, err := app.Models.Attendance.Get(user.ID, date)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.errorResponse(w, r, 404, "no check-in data for the date")
+			app.notFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	if att.CheckOutAt != nil {
-		// If there is already check in for the date
-		app.errorResponse(w, r, 409, "already checked out for the date")
-		return
-	}
+	worked, breaks := data.WorkedTime(att.Punches, time.Now())
+	att.WorkedMinutes = int(worked / time.Minute)
+	att.BreakMinutes = int(breaks / time.Minute)
 
-	att = &data.Attendance{
-		EmployeeID: user.ID,
-		CheckOutAt: &attDateWIB,
-		AttDate:    attDateWIB.Format("2006-01-02"),
-		UpdatedBy:  user.ID,
-	}
+	app.writeJSON(w, http.StatusOK, envelope{"attendance": att}, nil)
+}
 
-	// Record check out
-	err = app.Models.Attendance.RecordCheckOut(att)
+// punch records a punch of the authenticated employee at the current time
+func (app *Application) punch(w http.ResponseWriter, r *http.Request, kind string, input attendanceInput) {
+	user := app.contextGetUser(r)
+
+	input.IPAddress = app.clientIP(r)
+	input.UserAgent = r.UserAgent()
+
+	att, err := app.recordAttendance(user, kind, time.Now(), input)
 	if err != nil {
+		var attErr *attendanceError
 		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.errorResponse(w, r, 404, "no check-in data for the date")
+		case errors.As(err, &attErr):
+			app.errorResponse(w, r, attErr.status, attErr.message)
 		default:
 			app.serverErrorResponse(w, r, err)
-
 		}
 		return
 	}
+
 	app.writeJSON(w, http.StatusCreated, envelope{
-		"message":    "checked-out successfully",
+		"message":    punchMessages[kind],
 		"attendance": att,
 	}, nil)
 }
+
+// recordAttendance validates and records a punch of the employee which happened
+// at the given time. Rule violations are reported as an *attendanceError,
+// anything else is an unexpected error.
+func (app *Application) recordAttendance(user *data.User, kind string, at time.Time, input attendanceInput) (*data.Attendance, error) {
+	action := punchActions[kind]
+
+	v := validator.New()
+	validator.ValidateCoordinates(v, input.Latitude, input.Longitude)
+	if !v.Valid() {
+		return nil, &attendanceError{http.StatusUnprocessableEntity, v.Errors}
+	}
+
+	office, err := app.employeeOffice(user)
+	if err != nil {
+		return nil, err
+	}
+
+	// Determine attendance date in the employee's time zone
+	loc, err := app.officeLocation(office)
+	if err != nil {
+		return nil, err
+	}
+	now := at.In(loc)
+	date := now.Format("2006-01-02")
+
+	// A rostered shift decides the attendance date, so that a night shift is a
+	// single attendance day, and allows attendance on weekends and holidays
+	shift, err := app.rosteredShift(user, kind, now, date)
+	if err != nil {
+		return nil, err
+	}
+	if shift != nil {
+		date = shift.ShiftDate
+	}
+
+	// Validate if date is not part of a processed payroll period
+	locked, err := app.Models.PayrollPeriod.IsLocked(date)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		return nil, &attendanceError{http.StatusUnprocessableEntity,
+			fmt.Sprintf("cannot %s on %s, the payroll period has already been processed", action, date)}
+	}
+
+	// Validate if date is not weekend or holiday, unless the employee is rostered
+	if shift == nil {
+		reason, err := app.nonWorkdayReason(user, now)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			return nil, &attendanceError{http.StatusUnprocessableEntity, fmt.Sprintf("cannot %s on %s", action, reason)}
+		}
+	}
+
+	// Validate if employee is not on approved leave
+	if kind == data.PunchIn {
+		onLeave, err := app.Models.LeaveRequests.HasApprovedLeave(user.ID, date)
+		if err != nil {
+			return nil, err
+		}
+		if onLeave {
+			return nil, &attendanceError{http.StatusUnprocessableEntity, "cannot check in on an approved leave day"}
+		}
+	}
+
+	// The attendance type is chosen at the first check-in of the day, later
+	// punches follow the type of the day
+	typeCode := input.AttendanceType
+	firstCheckIn := false
+	existing, err := app.Models.Attendance.Get(user.ID, date)
+	switch {
+	case err == nil:
+		typeCode = existing.Type
+	case errors.Is(err, data.ErrRecordNotFound):
+		firstCheckIn = kind == data.PunchIn
+	default:
+		return nil, err
+	}
+	if typeCode == "" {
+		typeCode = data.AttendanceOffice
+	}
+
+	attType, err := app.Models.AttTypes.GetByCode(typeCode)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, &attendanceError{http.StatusUnprocessableEntity,
+				map[string]string{"attendance_type": "must be a known attendance type"}}
+		}
+		return nil, err
+	}
+
+	// Validate if employee has WFH days left this month
+	if firstCheckIn && attType.Code == data.AttendanceWFH {
+		message, err := app.wfhQuotaError(user, now)
+		if err != nil {
+			return nil, err
+		}
+		if message != "" {
+			return nil, &attendanceError{http.StatusUnprocessableEntity, message}
+		}
+	}
+
+	var kioskID *int64
+	if attType.AtOffice {
+		// Validate if employee is within the office geofence
+		if errs := app.geofenceErrors(user, office, input.Latitude, input.Longitude); errs != nil {
+			return nil, &attendanceError{http.StatusUnprocessableEntity, errs}
+		}
+
+		// Validate if employee scanned the QR code of an office kiosk
+		var errs map[string]string
+		kioskID, errs, err = app.kioskErrors(user, office, input.KioskToken, at)
+		if err != nil {
+			return nil, err
+		}
+		if errs != nil {
+			return nil, &attendanceError{http.StatusUnprocessableEntity, errs}
+		}
+	}
+
+	att, err := app.Models.Attendance.RecordPunch(user.ID, date, attType.Code, &data.Punch{
+		Kind:      kind,
+		PunchedAt: now,
+		Latitude:  input.Latitude,
+		Longitude: input.Longitude,
+		KioskID:   kioskID,
+		IPAddress: nilIfEmpty(input.IPAddress),
+		UserAgent: nilIfEmpty(input.UserAgent),
+		DeviceID:  nilIfEmpty(input.DeviceID),
+		CreatedBy: &user.ID,
+	}, input.SyncEvent)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, &attendanceError{http.StatusNotFound, "no check-in data for the date"}
+		case errors.Is(err, data.ErrPunchOutOfOrder):
+			return nil, &attendanceError{http.StatusUnprocessableEntity, err.Error()}
+		case errors.Is(err, data.ErrDuplicateCheckIn),
+			errors.Is(err, data.ErrDuplicateCheckOut),
+			errors.Is(err, data.ErrNotCheckedIn),
+			errors.Is(err, data.ErrAlreadyOnBreak),
+			errors.Is(err, data.ErrNotOnBreak):
+			return nil, &attendanceError{http.StatusConflict, err.Error()}
+		default:
+			return nil, err
+		}
+	}
+
+	return att, nil
+}
+
+// rosteredShift returns the rostered shift a punch of the employee at their
+// local time now belongs to, or nil if they are not rostered at the time.
+// Employees rostered on a working shift on the date may only check in from
+// shiftCheckInWindow before it starts until it ends.
+func (app *Application) rosteredShift(user *data.User, kind string, now time.Time, date string) (*data.RosterEntry, error) {
+	late := shiftCheckOutWindow
+	if kind == data.PunchIn {
+		late = 0
+	}
+
+	shift, err := app.Models.Roster.Current(user.ID, now, shiftCheckInWindow, late)
+	switch {
+	case err == nil:
+		return shift, nil
+	case !errors.Is(err, data.ErrRecordNotFound):
+		return nil, err
+	case kind != data.PunchIn:
+		return nil, nil
+	}
+
+	entries, err := app.Models.Roster.GetAll(&user.ID, date, date)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.OnCall {
+			return nil, &attendanceError{http.StatusUnprocessableEntity,
+				fmt.Sprintf("you are rostered on the %s shift from %s to %s, check in from %s",
+					e.ShiftCode,
+					e.StartsAt.In(now.Location()).Format("2006-01-02 15:04"),
+					e.EndsAt.In(now.Location()).Format("2006-01-02 15:04"),
+					e.StartsAt.Add(-shiftCheckInWindow).In(now.Location()).Format("15:04"))}
+		}
+	}
+
+	return nil, nil
+}
+
+// wfhQuotaError describes why the employee may not work from home in the month
+// of their local time now, or returns an empty string if they may
+func (app *Application) wfhQuotaError(user *data.User, now time.Time) (string, error) {
+	quota, err := app.Models.WFHQuotas.Get(user.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return "you are not allowed to work from home", nil
+		}
+		return "", err
+	}
+
+	from, to := monthRange(now)
+	used, err := app.Models.Attendance.CountDays(user.ID, data.AttendanceWFH, from, to)
+	if err != nil {
+		return "", err
+	}
+	if used >= quota.DaysPerMonth {
+		return fmt.Sprintf("you have used all %d work from home days of this month", quota.DaysPerMonth), nil
+	}
+
+	return "", nil
+}
+
+// monthRange returns the first and last dates (YYYY-MM-DD) of the month of t
+func monthRange(t time.Time) (string, string) {
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return first.Format("2006-01-02"), first.AddDate(0, 1, -1).Format("2006-01-02")
+}
+
+// nonWorkdayReason describes why the given local time of the employee is not a
+// working day ("the weekend" or "a holiday (name)"), or returns an empty string
+// if it is one
+func (app *Application) nonWorkdayReason(user *data.User, now time.Time) (string, error) {
+	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+		return "the weekend", nil
+	}
+
+	holiday, err := app.Models.Holidays.GetOn(now.Format("2006-01-02"), user.OfficeID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return fmt.Sprintf("a holiday (%s)", holiday.Name), nil
+}
+
+// geofenceErrors returns validation errors when the employee is subject to their
+// office's geofence and the coordinates are missing or outside of it, or nil if
+// attendance may be recorded
+func (app *Application) geofenceErrors(user *data.User, office *data.Office, latitude, longitude *float64) map[string]string {
+	if user.RemoteAllowed || office == nil || !office.HasGeofence() {
+		return nil
+	}
+
+	if latitude == nil || longitude == nil {
+		return map[string]string{"location": "latitude and longitude must be provided"}
+	}
+
+	distance := office.DistanceM(*latitude, *longitude)
+	if distance > float64(*office.RadiusM) {
+		return map[string]string{
+			"location": fmt.Sprintf("you are %.0f m from the %s office, outside the allowed radius of %d m",
+				distance, office.Name, *office.RadiusM),
+		}
+	}
+
+	return nil
+}
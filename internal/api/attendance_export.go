@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+	"github.com/moniquelin/monday-hr/internal/xlsx"
+)
+
+const (
+	// Longest period which can be exported at once
+	maxExportDays = 366
+	// Time allowed to stream an export, beyond the server's write timeout
+	exportWriteTimeout = 5 * time.Minute
+)
+
+// Columns of the attendance export
+var exportHeader = []string{
+	"employee_id", "employee_name", "email", "date", "timezone",
+	"check_in", "check_out", "worked_hours", "status", "leave_type",
+}
+
+// exportAttendanceHandler lets admins download the attendance of all employees
+// between two dates as CSV (default) or XLSX. The rows are streamed from the
+// database to the client as they are read.
+func (app *Application) exportAttendanceHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	from, to, format := qs.Get("from"), qs.Get("to"), qs.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+
+	v := validator.New()
+	start, err := time.Parse("2006-01-02", from)
+	v.Check(err == nil, "from", "must be a valid date (YYYY-MM-DD)")
+	end, err := time.Parse("2006-01-02", to)
+	v.Check(err == nil, "to", "must be a valid date (YYYY-MM-DD)")
+	if v.Valid() {
+		v.Check(!end.Before(start), "to", "must not be before from")
+		v.Check(end.Sub(start) < maxExportDays*24*time.Hour, "to",
+			fmt.Sprintf("must not be more than %d days after from", maxExportDays-1))
+	}
+	v.Check(validator.In(format, "csv", "xlsx"), "format", "must be csv or xlsx")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Streaming a year of attendance takes longer than the server's write timeout
+	err = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("attendance_%s_%s.%s", from, to, format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	switch format {
+	case "xlsx":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		err = app.exportAttendanceXLSX(w, r, from, to)
+	default:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = app.exportAttendanceCSV(w, r, from, to)
+	}
+
+	if err != nil {
+		// The response has already started, so it can only be aborted, which
+		// tells the client the file is incomplete
+		app.logError(r, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func (app *Application) exportAttendanceCSV(w http.ResponseWriter, r *http.Request, from, to string) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(exportHeader)
+	if err != nil {
+		return err
+	}
+
+	err = app.Models.Attendance.Export(r.Context(), from, to, app.Config.Timezone, func(row *data.AttendanceExportRow) error {
+		return cw.Write([]string{
+			strconv.FormatInt(row.EmployeeID, 10),
+			row.EmployeeName,
+			row.Email,
+			row.Date,
+			row.Timezone,
+			stringOrEmpty(row.CheckIn),
+			stringOrEmpty(row.CheckOut),
+			strconv.FormatFloat(workedHours(row.WorkedMinutes), 'f', 2, 64),
+			row.Status,
+			stringOrEmpty(row.LeaveType),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func (app *Application) exportAttendanceXLSX(w http.ResponseWriter, r *http.Request, from, to string) error {
+	xw, err := xlsx.NewWriter(w, "Attendance")
+	if err != nil {
+		return err
+	}
+
+	header := make([]any, len(exportHeader))
+	for i, name := range exportHeader {
+		header[i] = name
+	}
+	err = xw.WriteRow(header...)
+	if err != nil {
+		return err
+	}
+
+	err = app.Models.Attendance.Export(r.Context(), from, to, app.Config.Timezone, func(row *data.AttendanceExportRow) error {
+		return xw.WriteRow(
+			row.EmployeeID,
+			row.EmployeeName,
+			row.Email,
+			row.Date,
+			row.Timezone,
+			row.CheckIn,
+			row.CheckOut,
+			workedHours(row.WorkedMinutes),
+			row.Status,
+			row.LeaveType,
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	return xw.Close()
+}
+
+// workedHours converts worked minutes to hours rounded to two decimals
+func workedHours(minutes int) float64 {
+	return math.Round(float64(minutes)/60*100) / 100
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
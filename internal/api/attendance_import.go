@@ -0,0 +1,476 @@
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+	"github.com/moniquelin/monday-hr/internal/xlsx"
+)
+
+const (
+	// Maximum size of an uploaded punch log
+	maxImportFileBytes = 10 << 20
+	// Maximum number of punches in one import
+	maxImportRows = 10_000
+)
+
+// importPunchKinds maps the punch types accepted in imported logs to punch
+// kinds. Fingerprint devices export their attendance states as numbers: 0 for
+// check-in, 1 for check-out, 2 for break start and 3 for break end.
+var importPunchKinds = map[string]string{
+	data.PunchIn:         data.PunchIn,
+	data.PunchOut:        data.PunchOut,
+	data.PunchBreakStart: data.PunchBreakStart,
+	data.PunchBreakEnd:   data.PunchBreakEnd,
+	"checkin":            data.PunchIn,
+	"checkout":           data.PunchOut,
+	"0":                  data.PunchIn,
+	"1":                  data.PunchOut,
+	"2":                  data.PunchBreakStart,
+	"3":                  data.PunchBreakEnd,
+}
+
+// Local time layouts accepted for punch times, besides RFC 3339
+var importTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05"}
+
+// importRowError reports why a row of an imported punch log was not imported
+type importRowError struct {
+	Row          int               `json:"row"`
+	DeviceUserID string            `json:"device_user_id"`
+	Errors       map[string]string `json:"errors"`
+}
+
+// importAttendanceHandler lets admins import the punch logs exported by
+// biometric attendance devices, as CSV or XLSX with the columns device_user_id,
+// punched_at and type. Device user IDs are mapped to employees through the
+// device user mappings. Every row is validated, and the punches are only
+// recorded if all rows are valid; otherwise, or with ?dry_run=true, nothing is
+// recorded and a report of the invalid rows is returned.
+func (app *Application) importAttendanceHandler(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	rows, err := app.readImportFile(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if len(rows) == 0 {
+		app.failedValidationResponse(w, r, map[string]string{"file": "must contain a header row"})
+		return
+	}
+
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"device_user_id", "punched_at", "type"} {
+		if _, ok := columns[name]; !ok {
+			app.failedValidationResponse(w, r, map[string]string{"file": "must have the columns device_user_id, punched_at and type"})
+			return
+		}
+	}
+
+	if len(rows)-1 > maxImportRows {
+		app.failedValidationResponse(w, r, map[string]string{"file": fmt.Sprintf("must not contain more than %d rows", maxImportRows)})
+		return
+	}
+
+	imp := &punchImporter{app: app, admin: app.contextGetUser(r), now: time.Now()}
+	err = imp.loadMappings()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	cell := func(row []string, name string) string {
+		if i := columns[name]; i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var rowErrors []importRowError
+	var punches []*data.PunchImport
+	punchRows := map[*data.PunchImport]importRowError{}
+
+	for i, row := range rows[1:] {
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+
+		rowErr := importRowError{Row: i + 2, DeviceUserID: cell(row, "device_user_id")}
+
+		punch, errs, err := imp.parseRow(rowErr.DeviceUserID, cell(row, "punched_at"), cell(row, "type"))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if errs != nil {
+			rowErr.Errors = errs
+			rowErrors = append(rowErrors, rowErr)
+			continue
+		}
+
+		punches = append(punches, punch)
+		punchRows[punch] = rowErr
+	}
+
+	// Record the punches of each employee in the order they happened
+	slices.SortStableFunc(punches, func(a, b *data.PunchImport) int {
+		if c := cmp.Compare(a.EmployeeID, b.EmployeeID); c != 0 {
+			return c
+		}
+		return a.Punch.PunchedAt.Compare(b.Punch.PunchedAt)
+	})
+
+	total := len(punches) + len(rowErrors)
+	commit := !dryRun && len(rowErrors) == 0
+	applied, err := app.Models.Attendance.ImportPunches(punches, commit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, punch := range punches {
+		if punch.Err == nil {
+			continue
+		}
+		rowErr := punchRows[punch]
+		message := punch.Err.Error()
+		if errors.Is(punch.Err, data.ErrRecordNotFound) {
+			message = "employee has not checked in on the date"
+		}
+		rowErr.Errors = map[string]string{"type": message}
+		rowErrors = append(rowErrors, rowErr)
+	}
+
+	slices.SortFunc(rowErrors, func(a, b importRowError) int { return a.Row - b.Row })
+	if rowErrors == nil {
+		rowErrors = []importRowError{}
+	}
+
+	report := envelope{
+		"dry_run":      dryRun,
+		"applied":      applied,
+		"rows":         total,
+		"invalid_rows": len(rowErrors),
+		"errors":       rowErrors,
+	}
+
+	switch {
+	case applied:
+		report["message"] = fmt.Sprintf("imported %d punches successfully", len(punches))
+		app.writeJSON(w, http.StatusCreated, report, nil)
+	case dryRun:
+		app.writeJSON(w, http.StatusOK, report, nil)
+	default:
+		report["error"] = "the file contains invalid rows, nothing was imported"
+		app.writeJSON(w, http.StatusUnprocessableEntity, report, nil)
+	}
+}
+
+// readImportFile reads the rows of the uploaded punch log, which is an XLSX
+// spreadsheet or a comma, semicolon or tab separated CSV file
+func (app *Application) readImportFile(w http.ResponseWriter, r *http.Request) ([][]string, error) {
+	// Leave some room for the multipart encoding on top of the file
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileBytes+1_048_576)
+
+	err := r.ParseMultipartForm(maxImportFileBytes)
+	if err != nil {
+		return nil, fmt.Errorf("body must be a valid multipart form not larger than %d bytes", maxImportFileBytes)
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, errors.New("file must be provided")
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	if header.Size > maxImportFileBytes {
+		return nil, fmt.Errorf("file must not be larger than %d bytes", maxImportFileBytes)
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// XLSX files are zip archives
+	if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		rows, err := xlsx.ReadRows(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return nil, fmt.Errorf("file: %w", err)
+		}
+		return rows, nil
+	}
+
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")) // UTF-8 byte order mark
+	if !utf8.Valid(content) {
+		return nil, errors.New("file must be a CSV or XLSX file")
+	}
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comma = csvDelimiter(content)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("file is not a valid CSV file: %w", err)
+	}
+	return rows, nil
+}
+
+// csvDelimiter guesses the delimiter of a CSV file from its header line
+func csvDelimiter(content []byte) rune {
+	header, _, _ := bytes.Cut(content, []byte("\n"))
+
+	delimiter, most := ',', bytes.Count(header, []byte(","))
+	for _, d := range []rune{';', '\t'} {
+		if n := bytes.Count(header, []byte(string(d))); n > most {
+			delimiter, most = d, n
+		}
+	}
+	return delimiter
+}
+
+// punchImporter validates the rows of a punch log, caching what the rows of the
+// same employees and dates have in common
+type punchImporter struct {
+	app       *Application
+	admin     *data.User
+	now       time.Time
+	mappings  map[string]int64
+	employees map[int64]*data.User
+	locations map[int64]*time.Location
+	locked    map[string]bool
+}
+
+func (imp *punchImporter) loadMappings() error {
+	mappings, err := imp.app.Models.DeviceUsers.GetAll()
+	if err != nil {
+		return err
+	}
+
+	imp.mappings = make(map[string]int64, len(mappings))
+	for _, m := range mappings {
+		imp.mappings[m.DeviceUserID] = m.UserID
+	}
+	imp.employees = map[int64]*data.User{}
+	imp.locations = map[int64]*time.Location{}
+	imp.locked = map[string]bool{}
+	return nil
+}
+
+// parseRow validates a row of the punch log. Punch times without a time zone
+// are in the time zone of the employee's office.
+func (imp *punchImporter) parseRow(deviceUserID, punchedAt, kind string) (*data.PunchImport, map[string]string, error) {
+	v := validator.New()
+
+	employeeID, ok := imp.mappings[deviceUserID]
+	v.Check(deviceUserID != "", "device_user_id", "must be provided")
+	v.Check(deviceUserID == "" || ok, "device_user_id", "is not mapped to an employee")
+
+	punchKind, ok := importPunchKinds[strings.ToLower(kind)]
+	v.Check(ok, "type", "must be in, out, break_start or break_end")
+
+	v.Check(punchedAt != "", "punched_at", "must be provided")
+	if !v.Valid() {
+		return nil, v.Errors, nil
+	}
+
+	employee, loc, err := imp.employee(employeeID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	at, ok := parseImportTime(punchedAt, loc)
+	v.Check(ok, "punched_at", "must be a valid date and time")
+	if !v.Valid() {
+		return nil, v.Errors, nil
+	}
+	v.Check(!at.After(imp.now), "punched_at", "must not be in the future")
+
+	at = at.In(loc)
+	date := at.Format("2006-01-02")
+
+	// Validate if date is not weekend or holiday
+	reason, err := imp.app.nonWorkdayReason(employee, at)
+	if err != nil {
+		return nil, nil, err
+	}
+	v.Check(reason == "", "punched_at", fmt.Sprintf("is on %s", reason))
+
+	// Validate if date is not part of a processed payroll period
+	locked, ok := imp.locked[date]
+	if !ok {
+		locked, err = imp.app.Models.PayrollPeriod.IsLocked(date)
+		if err != nil {
+			return nil, nil, err
+		}
+		imp.locked[date] = locked
+	}
+	v.Check(!locked, "punched_at", "is in a payroll period which has already been processed")
+
+	if !v.Valid() {
+		return nil, v.Errors, nil
+	}
+
+	return &data.PunchImport{
+		EmployeeID: employee.ID,
+		Date:       date,
+		Punch: &data.Punch{
+			Kind:      punchKind,
+			PunchedAt: at,
+			CreatedBy: &imp.admin.ID,
+		},
+	}, nil, nil
+}
+
+// employee returns the employee and their time zone
+func (imp *punchImporter) employee(id int64) (*data.User, *time.Location, error) {
+	if employee, ok := imp.employees[id]; ok {
+		return employee, imp.locations[id], nil
+	}
+
+	employee, err := imp.app.Models.Users.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc, err := imp.app.employeeLocation(employee)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	imp.employees[id] = employee
+	imp.locations[id] = loc
+	return employee, loc, nil
+}
+
+// parseImportTime parses a punch time given in RFC 3339, as a local date and
+// time, or as an Excel serial date-time
+func parseImportTime(value string, loc *time.Location) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, true
+		}
+	}
+	if t, err := xlsx.ParseSerial(value, loc); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// createDeviceUserMappingHandler lets admins map a user ID enrolled on the
+// biometric attendance devices to an employee
+func (app *Application) createDeviceUserMappingHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		DeviceUserID string `json:"device_user_id"`
+		UserID       int64  `json:"user_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	input.DeviceUserID = strings.TrimSpace(input.DeviceUserID)
+
+	v := validator.New()
+	v.Check(input.DeviceUserID != "", "device_user_id", "must be provided")
+	v.Check(len(input.DeviceUserID) <= 64, "device_user_id", "must not be more than 64 bytes long")
+	v.Check(input.UserID > 0, "user_id", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	employee, err := app.Models.Users.Get(input.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.failedValidationResponse(w, r, map[string]string{"user_id": "must be an existing employee"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if employee.Role != "employee" {
+		app.failedValidationResponse(w, r, map[string]string{"user_id": "must be an existing employee"})
+		return
+	}
+
+	mapping := &data.DeviceUserMapping{
+		DeviceUserID: input.DeviceUserID,
+		UserID:       employee.ID,
+		CreatedBy:    app.contextGetUser(r).ID,
+	}
+
+	err = app.Models.DeviceUsers.Insert(mapping)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateDeviceUserID):
+			app.failedValidationResponse(w, r, map[string]string{"device_user_id": err.Error()})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{
+		"message": "device user mapped successfully",
+		"mapping": mapping,
+	}, nil)
+}
+
+// listDeviceUserMappingsHandler lists the device user mappings
+func (app *Application) listDeviceUserMappingsHandler(w http.ResponseWriter, r *http.Request) {
+	mappings, err := app.Models.DeviceUsers.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"mappings": mappings}, nil)
+}
+
+// deleteDeviceUserMappingHandler removes a device user mapping
+func (app *Application) deleteDeviceUserMappingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.Models.DeviceUsers.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"message": "device user mapping deleted successfully"}, nil)
+}
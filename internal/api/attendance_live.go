@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/live"
+)
+
+// Interval between comments sent to keep idle live connections open through
+// proxies
+const liveKeepAlive = 15 * time.Second
+
+// liveAttendanceHandler streams the attendance of the day to admins as
+// Server-Sent Events. A snapshot event with the counts of present and absent
+// employees comes first, followed by a punch event for every check-in,
+// check-out or break recorded through any API instance. The snapshot is sent
+// again if events may have been missed. The stream ends when the access token it
+// was opened with expires, or once its session was revoked or its user may no
+// longer see it, which is checked along with each keep-alive.
+func (app *Application) liveAttendanceHandler(w http.ResponseWriter, r *http.Request) {
+	if app.Live == nil {
+		app.errorResponse(w, r, http.StatusServiceUnavailable, "live attendance is not available")
+		return
+	}
+
+	// The stream stays open for as long as the client is connected
+	rc := http.NewResponseController(w)
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Subscribe before taking the snapshot so no punch falls in between
+	events, unsubscribe := app.Live.Subscribe()
+	defer unsubscribe()
+
+	board, err := app.Models.Attendance.Board(app.Config.Timezone)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, payload []byte) error {
+		_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		if err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	sendSnapshot := func(board *data.LiveBoard) error {
+		js, err := json.Marshal(board)
+		if err != nil {
+			return err
+		}
+		return send("snapshot", js)
+	}
+
+	err = sendSnapshot(board)
+	keepAlive := time.NewTicker(liveKeepAlive)
+	defer keepAlive.Stop()
+
+	// The client reconnects with a fresh access token
+	expired := time.NewTimer(time.Until(app.contextGetAccessToken(r).Expiry))
+	defer expired.Stop()
+
+	for err == nil {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-expired.C:
+			err = send("end", []byte(`{"reason":"access token expired"}`))
+			if err == nil {
+				return
+			}
+		case e, ok := <-events:
+			if !ok {
+				// Dropped for falling behind, the client reconnects and
+				// starts over from a fresh snapshot
+				return
+			}
+			switch e.Kind {
+			case live.EventReset:
+				board, err = app.Models.Attendance.Board(app.Config.Timezone)
+				if err == nil {
+					err = sendSnapshot(board)
+				}
+			default:
+				err = send("punch", []byte(e.Payload))
+			}
+		case <-keepAlive.C:
+			var allowed bool
+			allowed, err = app.liveStillAllowed(r)
+			if err == nil && !allowed {
+				err = send("end", []byte(`{"reason":"session revoked or access denied"}`))
+				if err == nil {
+					return
+				}
+			}
+			if err == nil {
+				_, err = fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			if err == nil {
+				err = rc.Flush()
+			}
+		}
+	}
+
+	// Writes fail once the client has gone away, which is not an error
+	if r.Context().Err() == nil {
+		app.logError(r, err)
+	}
+}
+
+// liveStillAllowed checks again that the session of the access token a live
+// stream was opened with has not been revoked, and that its user may still
+// access admin resources, as they may have been logged out, deactivated or
+// demoted since
+func (app *Application) liveStillAllowed(r *http.Request) (bool, error) {
+	token := app.contextGetAccessToken(r)
+
+	revoked, err := app.Models.Sessions.CheckAccessToken(token.ID, token.SessionID, app.clientIP(r))
+	if err != nil || revoked {
+		return false, err
+	}
+
+	user, err := app.Models.Users.Get(app.contextGetUser(r).ID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if !user.Active || user.Role == "employee" {
+		return false, nil
+	}
+	if app.Config.TwoFactor.RequireForAdmins && !user.TwoFactor {
+		return false, nil
+	}
+
+	return true, nil
+}
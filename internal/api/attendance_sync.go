@@ -0,0 +1,239 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+const (
+	// Maximum number of events accepted in one sync request
+	maxSyncBatchSize = 100
+	// Tolerated clock skew of devices reporting events in the future
+	maxSyncClockSkew = 5 * time.Minute
+	// Result status of events which failed unexpectedly and should be retried
+	syncStatusRetry = "retry"
+	// How long an event may stay pending before a replay applies it again, well
+	// beyond how long applying an event takes
+	syncPendingTimeout = time.Minute
+)
+
+// syncPunchKinds maps the event types accepted by sync to punch kinds. Devices
+// synced before punches existed send checkin and checkout.
+var syncPunchKinds = map[string]string{
+	"checkin":            data.PunchIn,
+	"checkout":           data.PunchOut,
+	data.PunchIn:         data.PunchIn,
+	data.PunchOut:        data.PunchOut,
+	data.PunchBreakStart: data.PunchBreakStart,
+	data.PunchBreakEnd:   data.PunchBreakEnd,
+}
+
+// syncResult reports the outcome of one event of a sync batch
+type syncResult struct {
+	EventID      string      `json:"event_id"`
+	Status       string      `json:"status"`
+	Duplicate    bool        `json:"duplicate,omitempty"`
+	Error        interface{} `json:"error,omitempty"`
+	AttendanceID *int64      `json:"attendance_id,omitempty"`
+}
+
+// syncAttendanceHandler applies a batch of punches (check-ins, check-outs and
+// breaks) which the employee's device recorded while offline. Every event is
+// applied on its own, with the same rules as online punches evaluated at the
+// time it occurred, and the response reports a result per event instead of
+// failing the whole batch. Events are identified by a client-generated UUID, so
+// re-sending a batch does not record anything twice.
+func (app *Application) syncAttendanceHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Events []struct {
+			EventID        string   `json:"event_id"`
+			DeviceID       string   `json:"device_id"`
+			Type           string   `json:"type"`
+			OccurredAt     string   `json:"occurred_at"`
+			AttendanceType string   `json:"attendance_type"`
+			Latitude       *float64 `json:"latitude"`
+			Longitude      *float64 `json:"longitude"`
+			KioskToken     string   `json:"kiosk_token"`
+		} `json:"events"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(len(input.Events) > 0, "events", "must contain at least one event")
+	v.Check(len(input.Events) <= maxSyncBatchSize, "events", fmt.Sprintf("must not contain more than %d events", maxSyncBatchSize))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	now := time.Now()
+
+	results := make([]syncResult, len(input.Events))
+	occurredAt := make([]time.Time, len(input.Events))
+
+	// Validate every event up front, rejecting malformed ones
+	for i, e := range input.Events {
+		results[i].EventID = e.EventID
+
+		v := validator.New()
+		v.Check(validator.Matches(e.EventID, validator.UUIDRX), "event_id", "must be a valid UUID")
+		v.Check(e.DeviceID != "", "device_id", "must be provided")
+		v.Check(len(e.DeviceID) <= 255, "device_id", "must not be more than 255 bytes long")
+		_, ok := syncPunchKinds[e.Type]
+		v.Check(ok, "type", "must be in, out, break_start or break_end")
+
+		t, err := time.Parse(time.RFC3339, e.OccurredAt)
+		v.Check(err == nil, "occurred_at", "must be a valid RFC 3339 timestamp")
+		occurredAt[i] = t
+
+		if !v.Valid() {
+			results[i].Status = data.SyncEventRejected
+			results[i].Error = v.Errors
+		}
+	}
+
+	// Apply the events in the order they happened, so a check-out recorded after
+	// a check-in in the same batch finds it
+	order := make([]int, len(input.Events))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return occurredAt[a].Compare(occurredAt[b])
+	})
+
+	for _, i := range order {
+		if results[i].Status != "" {
+			continue
+		}
+		e := input.Events[i]
+
+		event := &data.SyncEvent{
+			ID:         e.EventID,
+			EmployeeID: user.ID,
+			DeviceID:   e.DeviceID,
+			Kind:       syncPunchKinds[e.Type],
+			OccurredAt: occurredAt[i],
+		}
+
+		results[i] = app.applySyncEvent(r, user, event, now, attendanceInput{
+			AttendanceType: e.AttendanceType,
+			Latitude:       e.Latitude,
+			Longitude:      e.Longitude,
+			KioskToken:     e.KioskToken,
+			IPAddress:      app.clientIP(r),
+			UserAgent:      r.UserAgent(),
+			DeviceID:       e.DeviceID,
+			SyncEvent:      event,
+		})
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"results": results}, nil)
+}
+
+// applySyncEvent records one offline event unless it was already received. An
+// event received before but left pending, as the server failed before storing
+// its outcome, is applied again once syncPendingTimeout has passed.
+func (app *Application) applySyncEvent(r *http.Request, user *data.User, event *data.SyncEvent, now time.Time, input attendanceInput) syncResult {
+	result := syncResult{EventID: event.ID}
+
+	claimed, err := app.Models.SyncEvents.Claim(event)
+	if err != nil {
+		app.logError(r, err)
+		result.Status = syncStatusRetry
+		result.Error = "the server could not process this event, please retry"
+		return result
+	}
+
+	if !claimed {
+		claimed, err = app.Models.SyncEvents.Reclaim(event, syncPendingTimeout)
+		if err != nil {
+			app.logError(r, err)
+			result.Status = syncStatusRetry
+			result.Error = "the server could not process this event, please retry"
+			return result
+		}
+	}
+
+	// The event was received before: report the stored outcome again
+	if !claimed {
+		existing, err := app.Models.SyncEvents.Get(event.ID)
+		if err != nil {
+			app.logError(r, err)
+			result.Status = syncStatusRetry
+			result.Error = "the server could not process this event, please retry"
+			return result
+		}
+
+		if existing.EmployeeID != user.ID {
+			result.Status = data.SyncEventRejected
+			result.Error = map[string]string{"event_id": "has already been used"}
+			return result
+		}
+
+		result.Status = existing.Status
+		result.Duplicate = true
+		result.AttendanceID = existing.AttendanceID
+		if len(existing.Error) > 0 {
+			result.Error = existing.Error
+		}
+		return result
+	}
+
+	err = app.checkSyncEventAge(event, now)
+	if err == nil {
+		_, err = app.recordAttendance(user, event.Kind, event.OccurredAt, input)
+	}
+
+	// A recorded punch resolved its event as applied along with it
+	var attErr *attendanceError
+	if errors.As(err, &attErr) {
+		event.Status = data.SyncEventRejected
+		// The message is a string or a map of strings, which always marshals
+		event.Error, _ = json.Marshal(attErr.message)
+		err = app.Models.SyncEvents.Resolve(event)
+	}
+	if err != nil {
+		// Unexpected failure: forget the event so the device can retry it
+		app.logError(r, err)
+		if err := app.Models.SyncEvents.Release(event); err != nil {
+			app.logError(r, err)
+		}
+		result.Status = syncStatusRetry
+		result.Error = "the server could not process this event, please retry"
+		return result
+	}
+
+	result.Status = event.Status
+	result.AttendanceID = event.AttendanceID
+	if attErr != nil {
+		result.Error = attErr.message
+	}
+	return result
+}
+
+// checkSyncEventAge rejects events which are too old to be synced, or which
+// claim to have happened in the future
+func (app *Application) checkSyncEventAge(event *data.SyncEvent, now time.Time) error {
+	if now.Sub(event.OccurredAt) > app.Config.Sync.MaxAge {
+		return &attendanceError{http.StatusUnprocessableEntity,
+			fmt.Sprintf("event is older than %s and can no longer be synced", app.Config.Sync.MaxAge)}
+	}
+	if event.OccurredAt.Sub(now) > maxSyncClockSkew {
+		return &attendanceError{http.StatusUnprocessableEntity, "event occurred in the future, check the device clock"}
+	}
+	return nil
+}
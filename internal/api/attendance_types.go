@@ -0,0 +1,168 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// listAttendanceTypesHandler lists the attendance types employees can choose
+// from when checking in
+func (app *Application) listAttendanceTypesHandler(w http.ResponseWriter, r *http.Request) {
+	types, err := app.Models.AttTypes.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"attendance_types": types}, nil)
+}
+
+// updateAttendanceTypeHandler lets admins change the meal allowance paid per day
+// of an attendance type
+func (app *Application) updateAttendanceTypeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MealAllowance *int64 `json:"meal_allowance"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.MealAllowance != nil, "meal_allowance", "must be provided")
+	if input.MealAllowance != nil {
+		v.Check(*input.MealAllowance >= 0, "meal_allowance", "must not be negative")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	code := httprouter.ParamsFromContext(r.Context()).ByName("code")
+	attType, err := app.Models.AttTypes.GetByCode(code)
+	if err == nil {
+		err = app.Models.AttTypes.UpdateMealAllowance(attType, *input.MealAllowance)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":         "attendance type updated successfully",
+		"attendance_type": attType,
+	}, nil)
+}
+
+// setUserWFHQuotaHandler lets admins set how many days per month an employee
+// may work from home. A quota of 0 forbids working from home.
+func (app *Application) setUserWFHQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		DaysPerMonth *int `json:"days_per_month"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.DaysPerMonth != nil, "days_per_month", "must be provided")
+	if input.DaysPerMonth != nil {
+		v.Check(*input.DaysPerMonth >= 0 && *input.DaysPerMonth <= 31, "days_per_month", "must be between 0 and 31")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	employee, ok := app.fetchUser(w, r)
+	if !ok {
+		return
+	}
+	if employee.Role != "employee" {
+		app.failedValidationResponse(w, r, map[string]string{"user": "must be an employee"})
+		return
+	}
+
+	quota := &data.WFHQuota{
+		EmployeeID:   employee.ID,
+		DaysPerMonth: *input.DaysPerMonth,
+		UpdatedBy:    app.contextGetUser(r).ID,
+	}
+
+	err = app.Models.WFHQuotas.Set(quota)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":   "work from home quota updated successfully",
+		"wfh_quota": quota,
+	}, nil)
+}
+
+// attendanceSummaryHandler breaks down the attendance of the employee in a month
+// (the current one by default) by attendance type, with the meal allowance
+// earned and the work from home days left
+func (app *Application) attendanceSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	now, err := app.employeeNow(user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	month := now
+	if qs := r.URL.Query().Get("month"); qs != "" {
+		month, err = time.Parse("2006-01", qs)
+		if err != nil {
+			app.failedValidationResponse(w, r, map[string]string{"month": "must be a valid month (YYYY-MM)"})
+			return
+		}
+	}
+	from, to := monthRange(month)
+
+	summary, err := app.Models.Attendance.Summary(user.ID, from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Employees without a quota may not work from home
+	var wfhQuota envelope
+	quota, err := app.Models.WFHQuotas.Get(user.ID)
+	switch {
+	case err == nil:
+		used := summary.DaysByType[data.AttendanceWFH]
+		wfhQuota = envelope{
+			"days_per_month": quota.DaysPerMonth,
+			"used":           used,
+			"remaining":      max(quota.DaysPerMonth-used, 0),
+		}
+	case !errors.Is(err, data.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"month":     month.Format("2006-01"),
+		"summary":   summary,
+		"wfh_quota": wfhQuota,
+	}, nil)
+}
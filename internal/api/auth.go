@@ -3,6 +3,8 @@ package api
 import (
 	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/moniquelin/monday-hr/internal/data"
 	"github.com/moniquelin/monday-hr/internal/validator"
@@ -13,6 +15,8 @@ func (app *Application) loginHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Email             string `json:"email"`
 		PlaintextPassword string `json:"password"`
+		// Name of the device logging in, shown in the user's sessions
+		Device string `json:"device"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -26,46 +30,243 @@ func (app *Application) loginHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate Email & Password
 	validator.ValidateEmail(v, input.Email)
 	validator.ValidatePasswordPlaintext(v, input.PlaintextPassword)
+	v.Check(len(input.Device) <= 100, "device", "must not be more than 100 bytes long")
 	if len(v.Errors) != 0 {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	// Get user by email from the database
-	var user *data.User
+	// Refuse to check passwords while the account or client is locked out
+	account := strings.ToLower(input.Email)
+	ip := app.clientIP(r)
 
-	user, err = app.Models.Users.GetByEmail(input.Email)
+	lockedUntil, err := app.Models.Lockouts.LockedUntil(account, ip)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if lockedUntil != nil {
+		app.lockedOutResponse(w, r, *lockedUntil)
+		return
+	}
+
+	// Get user by email from the database. An unknown email gets the same
+	// response, after the same time, as a wrong password.
+	user, err := app.Models.Users.GetByEmail(input.Email)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Match the input password with the user password. Invited users who have
+	// not activated their account and single sign-on users have none, which
+	// fails like a wrong password.
+	passwordMatch := false
+	if user == nil {
+		data.MatchNoPassword(input.PlaintextPassword)
+	} else {
+		passwordMatch, err = user.Password.Matches(input.PlaintextPassword)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if !passwordMatch {
+		err = app.recordFailedLogin(account, ip)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		// Invited users are told to use their invitation, but only while it is
+		// valid, and counting towards the lockout like any failed login
+		if user != nil && !user.Activated {
+			invited, err := app.Models.Tokens.HasValid(data.ScopeActivation, user.ID)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			if invited {
+				app.errorResponse(w, r, http.StatusForbidden,
+					"your account has not been activated yet, please use the invitation emailed to you to set your password")
+				return
+			}
+		}
+
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	// Only failures since the last successful login count towards a lockout
+	err = app.Models.Lockouts.Clear(data.LockoutAccount, account)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !user.Active {
+		app.errorResponse(w, r, http.StatusUnauthorized, "your account has been deactivated")
+		return
+	}
+
+	// Users with two-factor authentication finish logging in with a code
+	if user.TwoFactor {
+		app.twoFactorChallenge(w, r, user)
+		return
+	}
+
+	app.startSession(w, r, user, input.Device)
+}
+
+// startSession starts a session for the user who logged in from the device,
+// and sends its tokens
+func (app *Application) startSession(w http.ResponseWriter, r *http.Request, user *data.User, device string) {
+	// Keep oversized user agents from bloating the sessions table
+	userAgent := r.UserAgent()
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+
+	session := &data.Session{
+		UserID:    user.ID,
+		Device:    device,
+		UserAgent: userAgent,
+		IPAddress: app.clientIP(r),
+		Current:   true,
+	}
+
+	refreshToken, err := app.Models.Sessions.New(session, app.Config.Jwt.RefreshTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeTokens(w, r, http.StatusCreated, "logged-in successfully", user, session, refreshToken)
+}
+
+// Failed logins which lock out an account, whatever the client, or a client,
+// whatever the accounts it tries. Clients are allowed more failures as they
+// may be shared by many users, e.g. behind an office NAT.
+var (
+	accountLockoutPolicy = data.LockoutPolicy{
+		MaxFailures: 5,
+		Window:      15 * time.Minute,
+		Lockout:     time.Minute,
+		MaxLockout:  time.Hour,
+		ResetAfter:  24 * time.Hour,
+	}
+	ipLockoutPolicy = data.LockoutPolicy{
+		MaxFailures: 20,
+		Window:      15 * time.Minute,
+		Lockout:     time.Minute,
+		MaxLockout:  time.Hour,
+		ResetAfter:  24 * time.Hour,
+	}
+)
+
+// recordFailedLogin counts a failed login against both the account and the
+// client IP address
+func (app *Application) recordFailedLogin(account, ip string) error {
+	_, err := app.Models.Lockouts.RecordFailure(data.LockoutAccount, account, accountLockoutPolicy)
+	if err != nil {
+		return err
+	}
+
+	_, err = app.Models.Lockouts.RecordFailure(data.LockoutIP, ip, ipLockoutPolicy)
+	return err
+}
+
+// refreshHandler exchanges a refresh token for a new access token and the next
+// refresh token of the session. Reusing a refresh token revokes every session of
+// the user, as it means the token was stolen.
+func (app *Application) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.RefreshToken != "", "refresh_token", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	session, refreshToken, err := app.Models.Sessions.Refresh(input.RefreshToken, app.clientIP(r), app.Config.Jwt.RefreshTTL)
 	if err != nil {
 		switch {
-		// If no user is found
+		case errors.Is(err, data.ErrRefreshTokenReused):
+			app.errorResponse(w, r, http.StatusUnauthorized,
+				"this refresh token was already used, all your sessions have been revoked, please log in again")
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.errorResponse(w, r, 401, "user with this email does not exist")
+			app.errorResponse(w, r, http.StatusUnauthorized, "invalid or expired refresh token")
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	// Match the input password with the user password
-	var passwordMatch bool
-	passwordMatch, err = user.Password.Matches(input.PlaintextPassword)
+	user, err := app.Models.Users.Get(session.UserID)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+	if !user.Active {
+		err = app.Models.Sessions.Revoke(session.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.errorResponse(w, r, http.StatusUnauthorized, "your account has been deactivated")
+		return
+	}
 
-	if !passwordMatch {
-		app.errorResponse(w, r, 401, "wrong password!")
+	session.Current = true
+	app.writeTokens(w, r, http.StatusOK, "tokens refreshed successfully", user, session, refreshToken)
+}
+
+// logoutHandler ends the session of the access token, revoking its refresh
+// token family and the access token itself
+func (app *Application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	token := app.contextGetAccessToken(r)
+
+	err := app.Models.Sessions.Revoke(token.SessionID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	// Create token for user with correct email and password
-	tokenString, err := app.createToken(user.ID)
+	err = app.Models.Sessions.RevokeAccessToken(token.ID, token.Expiry)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
 	}
-	app.writeJSON(w, 201, envelope{
-		"message":              "logged-in successfully",
-		"user":                 user,
-		"authentication_token": tokenString}, nil)
+
+	app.writeJSON(w, http.StatusOK, envelope{"message": "logged out successfully"}, nil)
+}
+
+// writeTokens creates an access token for the session and sends it along with
+// the refresh token
+func (app *Application) writeTokens(w http.ResponseWriter, r *http.Request, status int, message string,
+	user *data.User, session *data.Session, refreshToken *data.RefreshToken) {
+	accessToken, expiry, err := app.createAccessToken(user.ID, session.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, status, envelope{
+		"message":                     message,
+		"user":                        user,
+		"authentication_token":        accessToken,
+		"authentication_token_expiry": expiry,
+		"refresh_token":               refreshToken,
+		"session":                     session,
+	}, nil)
 }
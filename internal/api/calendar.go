@@ -0,0 +1,165 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/ical"
+)
+
+// Number of days ahead covered by calendar feeds
+const calendarFeedDays = 365
+
+// calendarFeedURL returns the URL calendar apps subscribe to for the feed token
+func (app *Application) calendarFeedURL(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil || (app.Config.TrustProxy && r.Header.Get("X-Forwarded-Proto") == "https") {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/v1/calendar/%s.ics", scheme, r.Host, token)
+}
+
+// showCalendarFeedHandler returns the user's calendar feed, without its URL
+// which is only known when the feed is created or rotated
+func (app *Application) showCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	feed, err := app.Models.CalendarFeeds.Get(app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"calendar_feed": feed}, nil)
+}
+
+// rotateCalendarFeedHandler creates the user's calendar feed, or gives it a new
+// URL if it already exists so that the previous one stops working. The URL
+// is only returned in this response.
+func (app *Application) rotateCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	feed, token, err := app.Models.CalendarFeeds.Rotate(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{
+		"message":       "calendar feed created successfully",
+		"calendar_feed": feed,
+		"url":           app.calendarFeedURL(r, token),
+	}, nil)
+}
+
+// revokeCalendarFeedHandler disables the user's calendar feed
+func (app *Application) revokeCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.Models.CalendarFeeds.Delete(app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"message": "calendar feed revoked successfully"}, nil)
+}
+
+// calendarFeedHandler serves the calendar feed of the user the token in the URL
+// belongs to, as iCalendar. Calendar apps cannot log in, so the token is the
+// only credential. The feed covers the upcoming holidays of the user's office,
+// their approved leave and rostered shifts, and payroll pay dates.
+func (app *Application) calendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	file := httprouter.ParamsFromContext(r.Context()).ByName("file")
+	token, ok := strings.CutSuffix(file, ".ics")
+	if !ok || token == "" {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	userID, err := app.Models.CalendarFeeds.GetUserIDForToken(token)
+	var user *data.User
+	if err == nil {
+		user, err = app.Models.Users.Get(userID)
+	}
+	if err == nil && !user.Active {
+		err = data.ErrRecordNotFound
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	now, err := app.employeeNow(user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	from := now.Format("2006-01-02")
+	to := now.AddDate(0, 0, calendarFeedDays).Format("2006-01-02")
+
+	events, err := app.Models.CalendarFeeds.Events(user, from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	cal := &ical.Calendar{
+		ProdID: "-//monday-hr//Calendar Feed " + Version + "//EN",
+		Name:   "monday-hr",
+		Events: make([]*ical.Event, 0, len(events)),
+	}
+	for _, e := range events {
+		event := &ical.Event{
+			UID:     fmt.Sprintf("%s-%d@monday-hr", e.Kind, e.ID),
+			Summary: e.Summary,
+		}
+
+		switch e.Kind {
+		case data.CalendarShift:
+			event.Summary = "Shift: " + e.Summary
+			event.Start, event.End = *e.StartsAt, *e.EndsAt
+		default:
+			switch e.Kind {
+			case data.CalendarHoliday:
+				event.Summary = "Holiday: " + e.Summary
+			case data.CalendarLeave:
+				event.Summary = "Leave: " + e.Summary
+			}
+			event.AllDay = true
+			event.Start, err = time.Parse("2006-01-02", e.StartDate)
+			if err == nil {
+				event.End, err = time.Parse("2006-01-02", e.EndDate)
+			}
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+
+		cal.Events = append(cal.Events, event)
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=900")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = cal.WriteTo(w)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
@@ -9,7 +9,11 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey        = contextKey("user")
+	accessTokenContextKey = contextKey("accessToken")
+	kioskContextKey       = contextKey("kiosk")
+)
 
 // The contextSetUser() method returns a new copy of the request with the provided
 // User struct added to the context
@@ -26,3 +30,36 @@ func (app *Application) contextGetUser(r *http.Request) *data.User {
 	}
 	return user
 }
+
+// The contextSetAccessToken() method returns a new copy of the request with the
+// claims of the access token it was authenticated with added to the context
+func (app *Application) contextSetAccessToken(r *http.Request, token *accessToken) *http.Request {
+	ctx := context.WithValue(r.Context(), accessTokenContextKey, token)
+	return r.WithContext(ctx)
+}
+
+// The contextGetAccessToken() retrieves the access token claims from the request
+// context
+func (app *Application) contextGetAccessToken(r *http.Request) *accessToken {
+	token, ok := r.Context().Value(accessTokenContextKey).(*accessToken)
+	if !ok {
+		panic("missing access token value in request context")
+	}
+	return token
+}
+
+// The contextSetKiosk() method returns a new copy of the request with the provided
+// KioskDevice struct added to the context
+func (app *Application) contextSetKiosk(r *http.Request, kiosk *data.KioskDevice) *http.Request {
+	ctx := context.WithValue(r.Context(), kioskContextKey, kiosk)
+	return r.WithContext(ctx)
+}
+
+// The contextGetKiosk() retrieves the KioskDevice struct from the request context
+func (app *Application) contextGetKiosk(r *http.Request) *data.KioskDevice {
+	kiosk, ok := r.Context().Value(kioskContextKey).(*data.KioskDevice)
+	if !ok {
+		panic("missing kiosk value in request context")
+	}
+	return kiosk
+}
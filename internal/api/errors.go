@@ -2,7 +2,10 @@ package api
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // The logError() method is a generic helper for logging an error message.
@@ -38,6 +41,23 @@ func (app *Application) invalidAuthenticationTokenResponse(w http.ResponseWriter
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
 }
 
+// The invalidCredentialsResponse() method will be used to send a 401 Unauthorized
+// status code and JSON response to the client, whether the email or the password
+// was wrong.
+func (app *Application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid authentication credentials"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// The lockedOutResponse() method will be used to send a 429 Too Many Requests
+// status code and JSON response to the client, telling it when to try again.
+func (app *Application) lockedOutResponse(w http.ResponseWriter, r *http.Request, until time.Time) {
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	message := "too many failed login attempts, please try again later"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
 // The notFoundResponse() method will be used to send a 404 Not Found status code and
 // JSON response to the client.
 func (app *Application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
@@ -5,8 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/moniquelin/monday-hr/internal/validator"
 )
 
 // envelope is a lightweight wrapper used to create JSON responses
@@ -111,3 +117,114 @@ func (app *Application) readJSON(w http.ResponseWriter, r *http.Request, dst int
 
 	return nil
 }
+
+// readOptionalJSON behaves like readJSON, but accepts a request without a body,
+// in which case dst is left untouched.
+func (app *Application) readOptionalJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	if r.ContentLength == 0 {
+		return nil
+	}
+	return app.readJSON(w, r, dst)
+}
+
+// readIDParam retrieves the "id" URL parameter from the current request context,
+// then converts it to an integer and returns it. If the operation isn't successful,
+// return 0 and an error.
+func (app *Application) readIDParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid id parameter")
+	}
+
+	return id, nil
+}
+
+// clientIP returns the IP address of the client. Behind a trusted reverse proxy
+// it is the last address of the X-Forwarded-For header, the one the proxy added.
+// It returns an empty string if the remote address is not an IP address, e.g.
+// over a Unix socket, which is stored as NULL in the IP address columns.
+func (app *Application) clientIP(r *http.Request) string {
+	if app.Config.TrustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			parts := strings.Split(xff, ",")
+			if ip := net.ParseIP(strings.TrimSpace(parts[len(parts)-1])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// nilIfEmpty returns a pointer to s, or nil if s is empty, for optional columns
+func nilIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// readString returns the query string value of key, or defaultValue if it is
+// missing
+func (app *Application) readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+// readInt returns the query string value of key as an integer, or defaultValue
+// if it is missing. An invalid integer is recorded in the validator.
+func (app *Application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddError(key, "must be an integer value")
+		return defaultValue
+	}
+	return i
+}
+
+// readBool returns the query string value of key as a boolean, or nil if it is
+// missing. An invalid boolean is recorded in the validator.
+func (app *Application) readBool(qs url.Values, key string, v *validator.Validator) *bool {
+	s := qs.Get(key)
+	if s == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be true or false")
+		return nil
+	}
+	return &b
+}
+
+// background runs fn in a goroutine, logging instead of crashing the server if
+// it panics
+func (app *Application) background(fn func()) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				app.Logger.Println(fmt.Errorf("%s", err))
+			}
+		}()
+
+		fn()
+	}()
+}
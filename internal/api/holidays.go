@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// createHolidayHandler lets admins add a national holiday, or an office-specific
+// one when office_id is provided
+func (app *Application) createHolidayHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Date     string `json:"date"`
+		Name     string `json:"name"`
+		OfficeID *int64 `json:"office_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	_, err = time.Parse("2006-01-02", input.Date)
+	v.Check(err == nil, "date", "must be a valid date (YYYY-MM-DD)")
+	v.Check(input.Name != "", "name", "must be provided")
+	v.Check(len(input.Name) <= 255, "name", "must not be more than 255 bytes long")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if input.OfficeID != nil {
+		_, err = app.Models.Offices.Get(*input.OfficeID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.failedValidationResponse(w, r, map[string]string{"office_id": "must be an existing office"})
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	user := app.contextGetUser(r)
+
+	holiday := &data.Holiday{
+		Date:      input.Date,
+		Name:      input.Name,
+		OfficeID:  input.OfficeID,
+		CreatedBy: user.ID,
+		UpdatedBy: user.ID,
+	}
+
+	err = app.Models.Holidays.Insert(holiday)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateHoliday):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{
+		"message": "holiday created successfully",
+		"holiday": holiday,
+	}, nil)
+}
+
+// listHolidaysHandler lists the holidays of a year (defaults to the current
+// year). Employees only see the holidays which apply to their office.
+func (app *Application) listHolidaysHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	year := time.Now().Year()
+	if qs := r.URL.Query().Get("year"); qs != "" {
+		y, err := strconv.Atoi(qs)
+		if err != nil || y < 2000 || y > 9999 {
+			app.failedValidationResponse(w, r, map[string]string{"year": "must be a valid year"})
+			return
+		}
+		year = y
+	}
+
+	from, to := fmt.Sprintf("%d-01-01", year), fmt.Sprintf("%d-12-31", year)
+
+	var holidays []*data.Holiday
+	var err error
+	if user.Role == "employee" {
+		holidays, err = app.Models.Holidays.GetForOffice(from, to, user.OfficeID)
+	} else {
+		holidays, err = app.Models.Holidays.GetAll(from, to)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"holidays": holidays}, nil)
+}
+
+// deleteHolidayHandler lets admins remove a holiday
+func (app *Application) deleteHolidayHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.Models.Holidays.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"message": "holiday deleted successfully"}, nil)
+}
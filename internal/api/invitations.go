@@ -0,0 +1,151 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// How long an invitation to activate an account stays valid
+const invitationTTL = 72 * time.Hour
+
+// sendInvitation issues an activation token to a pending user, replacing any
+// previous one, and emails it to them in the background
+func (app *Application) sendInvitation(user, admin *data.User) (*data.Token, error) {
+	err := app.Models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := app.Models.Tokens.New(user.ID, invitationTTL, data.ScopeActivation)
+	if err != nil {
+		return nil, err
+	}
+
+	app.background(func() {
+		emailData := map[string]any{
+			"Name":      user.Name,
+			"InvitedBy": admin.Name,
+			"Token":     token.Plaintext,
+			"Expiry":    token.Expiry.UTC().Format(time.RFC1123),
+		}
+
+		err := app.Mailer.Send(user.Email, "user_invitation.tmpl", emailData)
+		if err != nil {
+			app.Logger.Println(err)
+		}
+	})
+
+	return token, nil
+}
+
+// resendInvitationHandler lets admins email a new invitation to a user who has
+// not activated their account yet, e.g. because the previous one expired
+func (app *Application) resendInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.fetchUser(w, r)
+	if !ok {
+		return
+	}
+
+	admin := app.contextGetUser(r)
+
+	if !canManageUser(admin, user) {
+		app.errorResponse(w, r, http.StatusForbidden, "you must be a super admin to manage admins")
+		return
+	}
+	if user.Activated {
+		app.errorResponse(w, r, http.StatusConflict, "the user has already activated their account")
+		return
+	}
+	if !user.Active {
+		app.errorResponse(w, r, http.StatusConflict, "the user has been deactivated")
+		return
+	}
+
+	token, err := app.sendInvitation(user, admin)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, envelope{
+		"message":           "invitation sent successfully",
+		"invitation_expiry": token.Expiry,
+	}, nil)
+}
+
+// activateAccountHandler lets an invited user activate their account by
+// choosing their password with the token from their invitation
+func (app *Application) activateAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password string `json:"password"`
+		Token    string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validator.ValidatePasswordPlaintext(v, input.Password)
+	v.Check(input.Token != "", "token", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	userID, err := app.Models.Tokens.Consume(data.ScopeActivation, input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired activation token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.Models.Users.Get(userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !user.Active {
+		app.errorResponse(w, r, http.StatusForbidden, "your account has been deactivated")
+		return
+	}
+
+	err = user.Password.Set(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.Models.Users.Activate(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrActivated):
+			app.errorResponse(w, r, http.StatusConflict, "your account is already activated, please log in")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.Models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message": "your account was activated successfully, you can now log in",
+		"user":    user,
+	}, nil)
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"net/http"
+)
+
+// jwksHandler publishes the public keys verifying access tokens as a JSON Web
+// Key Set, so that other services can verify them. Clients may cache it for a
+// few minutes, as new keys are published well before they sign tokens.
+func (app *Application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "public, max-age=300")
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"keys": app.Keys.JWKS()}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
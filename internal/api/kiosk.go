@@ -0,0 +1,156 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// createKioskHandler lets admins register a kiosk device for an office. The
+// device token is only returned in this response and must be stored on the kiosk.
+func (app *Application) createKioskHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name     string `json:"name"`
+		OfficeID int64  `json:"office_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Name != "", "name", "must be provided")
+	v.Check(len(input.Name) <= 255, "name", "must not be more than 255 bytes long")
+	v.Check(input.OfficeID > 0, "office_id", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.Models.Offices.Get(input.OfficeID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.failedValidationResponse(w, r, map[string]string{"office_id": "must be an existing office"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	kiosk := &data.KioskDevice{
+		Name:      input.Name,
+		OfficeID:  input.OfficeID,
+		CreatedBy: user.ID,
+		UpdatedBy: user.ID,
+	}
+
+	token, err := app.Models.KioskDevices.Insert(kiosk)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{
+		"message":      "kiosk registered successfully",
+		"kiosk":        kiosk,
+		"device_token": token,
+	}, nil)
+}
+
+// listKiosksHandler lists all registered kiosk devices
+func (app *Application) listKiosksHandler(w http.ResponseWriter, r *http.Request) {
+	kiosks, err := app.Models.KioskDevices.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"kiosks": kiosks}, nil)
+}
+
+// revokeKioskHandler lets admins disable a lost or retired kiosk device
+func (app *Application) revokeKioskHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	kiosk, err := app.Models.KioskDevices.Get(id)
+	if err == nil {
+		err = app.Models.KioskDevices.Revoke(kiosk, app.contextGetUser(r).ID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message": "kiosk revoked successfully",
+		"kiosk":   kiosk,
+	}, nil)
+}
+
+// kioskQRHandler returns the QR token the authenticated kiosk should currently
+// display. Kiosks poll it and re-render the QR code when the token rotates.
+func (app *Application) kioskQRHandler(w http.ResponseWriter, r *http.Request) {
+	kiosk := app.contextGetKiosk(r)
+
+	now := time.Now()
+	token, rotatesAt := app.createKioskQRToken(kiosk.ID, now)
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"qr_token":   token,
+		"rotates_at": rotatesAt,
+		"kiosk":      kiosk,
+	}, nil)
+}
+
+// kioskErrors verifies the kiosk QR token submitted with a check-in or check-out
+// which happened at the given time. It returns the ID of the kiosk which
+// displayed the token (nil if no token was submitted), or validation errors when
+// the token is required but missing, or is invalid, expired, or from a kiosk of
+// another office.
+func (app *Application) kioskErrors(user *data.User, office *data.Office, token string, at time.Time) (*int64, map[string]string, error) {
+	if token == "" {
+		if office != nil && office.KioskRequired && !user.RemoteAllowed {
+			return nil, map[string]string{"kiosk_token": "must be provided, scan the QR code on the office kiosk"}, nil
+		}
+		return nil, nil, nil
+	}
+
+	kioskID, err := app.verifyKioskQRToken(token, at)
+	if err != nil {
+		return nil, map[string]string{"kiosk_token": err.Error()}, nil
+	}
+
+	kiosk, err := app.Models.KioskDevices.Get(kioskID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, map[string]string{"kiosk_token": errInvalidKioskQRToken.Error()}, nil
+		}
+		return nil, nil, err
+	}
+
+	if kiosk.RevokedAt != nil {
+		return nil, map[string]string{"kiosk_token": errInvalidKioskQRToken.Error()}, nil
+	}
+	if office == nil || kiosk.OfficeID != office.ID {
+		return nil, map[string]string{"kiosk_token": "must be scanned at a kiosk of your office"}, nil
+	}
+
+	return &kiosk.ID, nil, nil
+}
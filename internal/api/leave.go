@@ -0,0 +1,459 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// Maximum size of a supporting document uploaded with a leave request
+const maxLeaveAttachmentBytes = 5 << 20
+
+// listLeaveTypesHandler lists the kinds of leave which can be requested
+func (app *Application) listLeaveTypesHandler(w http.ResponseWriter, r *http.Request) {
+	leaveTypes, err := app.Models.LeaveTypes.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"leave_types": leaveTypes}, nil)
+}
+
+// listLeaveBalancesHandler shows the employee's remaining leave for a year
+// (defaults to the current year)
+func (app *Application) listLeaveBalancesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	now, err := app.employeeNow(user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	today := now.Format("2006-01-02")
+	year := now.Year()
+	if qs := r.URL.Query().Get("year"); qs != "" {
+		y, err := strconv.Atoi(qs)
+		if err != nil || y < 2000 || y > 9999 {
+			app.failedValidationResponse(w, r, map[string]string{"year": "must be a valid year"})
+			return
+		}
+		year = y
+	}
+
+	leaveTypes, err := app.Models.LeaveTypes.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	type balanceResponse struct {
+		LeaveType string `json:"leave_type"`
+		*data.LeaveBalance
+		AvailableDays int `json:"available_days"`
+	}
+
+	balances := []balanceResponse{}
+	for _, leaveType := range leaveTypes {
+		if !leaveType.TracksBalance {
+			continue
+		}
+
+		balance, err := app.Models.LeaveBalances.GetForYear(user.ID, leaveType, year)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		// Count availability as of today for the current year, otherwise as of
+		// the first day of the requested year
+		asOf := today
+		if year != now.Year() {
+			asOf = fmt.Sprintf("%d-01-01", year)
+		}
+
+		balances = append(balances, balanceResponse{
+			LeaveType:     leaveType.Code,
+			LeaveBalance:  balance,
+			AvailableDays: balance.Available(asOf),
+		})
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"leave_balances": balances}, nil)
+}
+
+// createLeaveRequestHandler enables employee to request leave. The request body
+// is either JSON or, when a supporting document is attached, multipart/form-data
+// with the document in the "attachment" field.
+func (app *Application) createLeaveRequestHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		LeaveType string `json:"leave_type"`
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+		Reason    string `json:"reason"`
+	}
+	var attachment *data.LeaveAttachment
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		var err error
+		attachment, err = app.readLeaveMultipart(w, r)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		input.LeaveType = r.FormValue("leave_type")
+		input.StartDate = r.FormValue("start_date")
+		input.EndDate = r.FormValue("end_date")
+		input.Reason = r.FormValue("reason")
+	} else {
+		err := app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	v := validator.New()
+
+	v.Check(input.LeaveType != "", "leave_type", "must be provided")
+	v.Check(len(input.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+
+	startDate, err := time.Parse("2006-01-02", input.StartDate)
+	if err != nil {
+		v.AddError("start_date", "must be a valid date (YYYY-MM-DD)")
+	}
+	endDate, err := time.Parse("2006-01-02", input.EndDate)
+	if err != nil {
+		v.AddError("end_date", "must be a valid date (YYYY-MM-DD)")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Domain rules: end_date >= start_date and within one year so a single
+	// balance applies
+	v.Check(!endDate.Before(startDate), "end_date", "must be on or after start_date")
+	v.Check(endDate.Year() == startDate.Year(), "end_date", "must be in the same year as start_date")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	// Only working days are taken from the balance: weekends and the holidays of
+	// the employee's office are skipped
+	holidays, err := app.Models.Holidays.CountWeekdays(input.StartDate, input.EndDate, user.OfficeID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	days := data.WorkingDays(startDate, endDate) - holidays
+	if days <= 0 {
+		app.failedValidationResponse(w, r, map[string]string{"end_date": "leave must include at least one working day"})
+		return
+	}
+
+	leaveType, err := app.Models.LeaveTypes.GetByCode(input.LeaveType)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.failedValidationResponse(w, r, map[string]string{"leave_type": "must be a known leave type"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if leaveType.RequiresAttachment && attachment == nil {
+		app.failedValidationResponse(w, r, map[string]string{
+			"attachment": fmt.Sprintf("must be provided for %s", strings.ToLower(leaveType.Name)),
+		})
+		return
+	}
+
+	// Check the balance up front so the employee gets early feedback; it is
+	// checked again when the request is approved
+	if leaveType.TracksBalance {
+		balance, err := app.Models.LeaveBalances.GetForYear(user.ID, leaveType, startDate.Year())
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if balance.Available(input.StartDate) < days {
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, data.ErrInsufficientLeaveBalance.Error())
+			return
+		}
+	}
+
+	lr := &data.LeaveRequest{
+		EmployeeID:  user.ID,
+		LeaveTypeID: leaveType.ID,
+		LeaveType:   leaveType.Code,
+		StartDate:   input.StartDate,
+		EndDate:     input.EndDate,
+		Days:        days,
+		Reason:      input.Reason,
+		Attachment:  attachment,
+		CreatedBy:   user.ID,
+		UpdatedBy:   user.ID,
+	}
+
+	err = app.Models.LeaveRequests.Insert(lr)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrLeaveOverlap):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{
+		"message":       "leave requested successfully",
+		"leave_request": lr,
+	}, nil)
+}
+
+// readLeaveMultipart parses a multipart leave request and returns the uploaded
+// attachment, if any
+func (app *Application) readLeaveMultipart(w http.ResponseWriter, r *http.Request) (*data.LeaveAttachment, error) {
+	// Leave some room for the other form fields on top of the attachment
+	r.Body = http.MaxBytesReader(w, r.Body, maxLeaveAttachmentBytes+1_048_576)
+
+	err := r.ParseMultipartForm(maxLeaveAttachmentBytes)
+	if err != nil {
+		return nil, fmt.Errorf("body must be a valid multipart form not larger than %d bytes", maxLeaveAttachmentBytes)
+	}
+
+	file, header, err := r.FormFile("attachment")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	if header.Size > maxLeaveAttachmentBytes {
+		return nil, fmt.Errorf("attachment must not be larger than %d bytes", maxLeaveAttachmentBytes)
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't trust the client's content type, sniff it instead
+	contentType := http.DetectContentType(content)
+	if !validator.In(contentType, "application/pdf", "image/jpeg", "image/png") {
+		return nil, errors.New("attachment must be a PDF, JPEG or PNG file")
+	}
+
+	return &data.LeaveAttachment{
+		Name:        header.Filename,
+		ContentType: contentType,
+		Content:     content,
+	}, nil
+}
+
+// listMyLeaveRequestsHandler lists the employee's own leave requests
+func (app *Application) listMyLeaveRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	requests, err := app.Models.LeaveRequests.GetAll(user.ID, r.URL.Query().Get("status"))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"leave_requests": requests}, nil)
+}
+
+// cancelLeaveRequestHandler enables employee to withdraw a pending leave request
+func (app *Application) cancelLeaveRequestHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	lr, ok := app.fetchLeaveRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if lr.EmployeeID != user.ID {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err := app.Models.LeaveRequests.Cancel(lr, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrLeaveNotPending):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":       "leave request cancelled successfully",
+		"leave_request": lr,
+	}, nil)
+}
+
+// showLeaveAttachmentHandler downloads the supporting document of a leave
+// request. Only the requesting employee and admins may access it.
+func (app *Application) showLeaveAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	lr, ok := app.fetchLeaveRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if user.Role == "employee" && lr.EmployeeID != user.ID {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	attachment, err := app.Models.LeaveRequests.GetAttachment(lr.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.Name))
+	w.Header().Set("Content-Length", strconv.Itoa(len(attachment.Content)))
+	w.Write(attachment.Content)
+}
+
+// listLeaveRequestsHandler lets admins list leave requests, optionally filtered
+// by status (e.g. ?status=pending)
+func (app *Application) listLeaveRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+
+	v := validator.New()
+	v.Check(status == "" || validator.In(status,
+		data.LeaveStatusPending, data.LeaveStatusApproved, data.LeaveStatusRejected, data.LeaveStatusCancelled),
+		"status", "must be one of pending, approved, rejected or cancelled")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	requests, err := app.Models.LeaveRequests.GetAll(0, status)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"leave_requests": requests}, nil)
+}
+
+// approveLeaveRequestHandler lets admins approve a pending leave request
+func (app *Application) approveLeaveRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.reviewLeaveRequest(w, r, data.LeaveStatusApproved)
+}
+
+// rejectLeaveRequestHandler lets admins reject a pending leave request
+func (app *Application) rejectLeaveRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.reviewLeaveRequest(w, r, data.LeaveStatusRejected)
+}
+
+// reviewLeaveRequest approves or rejects a leave request with an optional note
+func (app *Application) reviewLeaveRequest(w http.ResponseWriter, r *http.Request, status string) {
+	var input struct {
+		Note string `json:"note"`
+	}
+
+	err := app.readOptionalJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	lr, ok := app.fetchLeaveRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	if status == data.LeaveStatusApproved {
+		leaveType, err := app.Models.LeaveTypes.Get(lr.LeaveTypeID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		// Make sure the year's balance exists before deducting from it
+		if leaveType.TracksBalance {
+			year, _ := strconv.Atoi(lr.StartDate[:4])
+			_, err = app.Models.LeaveBalances.GetForYear(lr.EmployeeID, leaveType, year)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+
+		err = app.Models.LeaveRequests.Approve(lr, leaveType, user.ID, input.Note)
+	} else {
+		err = app.Models.LeaveRequests.Reject(lr, user.ID, input.Note)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrLeaveNotPending):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, data.ErrInsufficientLeaveBalance):
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":       fmt.Sprintf("leave request %s successfully", status),
+		"leave_request": lr,
+	}, nil)
+}
+
+// fetchLeaveRequest loads the leave request identified by the "id" URL parameter,
+// writing the error response itself when it cannot
+func (app *Application) fetchLeaveRequest(w http.ResponseWriter, r *http.Request) (*data.LeaveRequest, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	lr, err := app.Models.LeaveRequests.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return lr, true
+}
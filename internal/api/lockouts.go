@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// listLockoutsHandler lets admins list the accounts and client IP addresses
+// locked out of logging in, filtered by kind. With all=true, those which only
+// have failed logins are listed too.
+func (app *Application) listLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	kind := app.readString(qs, "kind", "")
+	all := app.readBool(qs, "all", v)
+
+	if kind != "" {
+		v.Check(validator.In(kind, data.LockoutAccount, data.LockoutIP), "kind", "must be account or ip")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	lockouts, err := app.Models.Lockouts.GetAll(kind, all != nil && *all)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"lockouts": lockouts}, nil)
+}
+
+// clearLockoutHandler lets admins lift a lockout, e.g. for a user who forgot
+// their password and has since reset it, and forget its failed logins
+func (app *Application) clearLockoutHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	lockout, err := app.Models.Lockouts.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message": "lockout cleared successfully",
+		"lockout": lockout,
+	}, nil)
+}
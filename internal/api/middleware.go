@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/keys"
 )
 
 // authenticate checks whether a user is logged in
@@ -22,10 +24,13 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 		}
 		tokenString = tokenString[len("Bearer "):]
 
-		// 2nd check: verify the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(app.Config.Jwt.Secret), nil
-		})
+		// 2nd check: verify the token with the key of its kid, only accepting
+		// the algorithm of that key
+		token, err := jwt.Parse(tokenString, app.Keys.Keyfunc,
+			jwt.WithValidMethods(keys.Algorithms),
+			jwt.WithIssuer(app.Config.Jwt.Issuer),
+			jwt.WithExpirationRequired(),
+		)
 
 		if err != nil || !token.Valid {
 			app.invalidAuthenticationTokenResponse(w, r)
@@ -47,6 +52,34 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		// Get the token ID and session, which are checked against the revocation
+		// list, and the expiry, kept on the list until then
+		jti, ok := claims["jti"].(string)
+		if !ok {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+		sessionIDFloat, ok := claims["sid"].(float64)
+		if !ok {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+		expiry, err := claims.GetExpirationTime()
+		if err != nil || expiry == nil {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		revoked, err := app.Models.Sessions.CheckAccessToken(jti, int64(sessionIDFloat), app.clientIP(r))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if revoked {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
 		// Retrieve the details of the user associated with the authentication token
 		user, err := app.Models.Users.Get(int64(userIDFloat))
 		if err != nil {
@@ -61,14 +94,55 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		// Tokens issued before the user was deactivated stop working
+		if !user.Active {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
 		// Call contextSetUser() to add the user information to the request context.
 		r = app.contextSetUser(r, user)
+		r = app.contextSetAccessToken(r, &accessToken{
+			ID:        jti,
+			SessionID: int64(sessionIDFloat),
+			Expiry:    expiry.Time,
+		})
 		// Call the next handler in the chain.
 		next.ServeHTTP(w, r)
 	})
 }
 
-// requireAdmin checks whether the given user has the "admin" role
+// authenticateKiosk checks whether the request comes from a registered kiosk
+// device. Kiosks authenticate with their device token ("Authorization: Kiosk
+// <token>"), which is separate from user JWTs.
+func (app *Application) authenticateKiosk(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Kiosk ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", "Kiosk")
+			app.errorResponse(w, r, http.StatusUnauthorized, "invalid or missing kiosk token")
+			return
+		}
+
+		kiosk, err := app.Models.KioskDevices.GetForToken(token)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				w.Header().Set("WWW-Authenticate", "Kiosk")
+				app.errorResponse(w, r, http.StatusUnauthorized, "invalid or missing kiosk token")
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		r = app.contextSetKiosk(r, kiosk)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAdmin checks whether the given user has the "admin" role, and has
+// two-factor authentication enabled if the policy requires it of admins
 func (app *Application) requireAdmin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := app.contextGetUser(r)
@@ -76,6 +150,11 @@ func (app *Application) requireAdmin(next http.Handler) http.Handler {
 			app.errorResponse(w, r, http.StatusUnauthorized, "you must be an admin to access this resource")
 			return
 		}
+		if app.Config.TwoFactor.RequireForAdmins && !user.TwoFactor {
+			app.errorResponse(w, r, http.StatusForbidden,
+				"you must enable two-factor authentication to access this resource (POST /v1/me/2fa/enrol)")
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+)
+
+// Maximum number of notifications returned in one listing
+const notificationsLimit = 50
+
+// listNotificationsHandler lists the latest notifications of the authenticated
+// user. Pass ?unread=true to only list unread ones.
+func (app *Application) listNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, err := app.Models.Notifications.GetAllForUser(user.ID, unreadOnly, notificationsLimit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"notifications": notifications}, nil)
+}
+
+// readNotificationHandler marks a notification of the authenticated user as read
+func (app *Application) readNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	notification, err := app.Models.Notifications.MarkRead(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"notification": notification}, nil)
+}
+
+// notify sends an in-app notification to the user, logging failures since the
+// notification is not essential to the request
+func (app *Application) notify(r *http.Request, userID int64, kind, title, message string) {
+	err := app.Models.Notifications.Insert(&data.Notification{
+		UserID:  userID,
+		Kind:    kind,
+		Title:   title,
+		Message: message,
+	})
+	if err != nil {
+		app.logError(r, err)
+	}
+}
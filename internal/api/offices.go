@@ -0,0 +1,319 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// employeeOffice returns the office the user is assigned to, or nil if they
+// have none
+func (app *Application) employeeOffice(user *data.User) (*data.Office, error) {
+	if user.OfficeID == nil {
+		return nil, nil
+	}
+	return app.Models.Offices.Get(*user.OfficeID)
+}
+
+// employeeLocation returns the time zone attendance of the user is recorded in:
+// the zone of their office, or the default time zone if they have none
+func (app *Application) employeeLocation(user *data.User) (*time.Location, error) {
+	office, err := app.employeeOffice(user)
+	if err != nil {
+		return nil, err
+	}
+	return app.officeLocation(office)
+}
+
+// officeLocation returns the time zone of the office, or the default time zone
+// if office is nil
+func (app *Application) officeLocation(office *data.Office) (*time.Location, error) {
+	if office == nil {
+		return time.LoadLocation(app.Config.Timezone)
+	}
+	return office.Location()
+}
+
+// employeeNow returns the current time in the user's time zone
+func (app *Application) employeeNow(user *data.User) (time.Time, error) {
+	loc, err := app.employeeLocation(user)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().In(loc), nil
+}
+
+// createOfficeHandler lets admins add an office location
+func (app *Application) createOfficeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name          string   `json:"name"`
+		Timezone      string   `json:"timezone"`
+		Latitude      *float64 `json:"latitude"`
+		Longitude     *float64 `json:"longitude"`
+		RadiusM       *int     `json:"radius_m"`
+		KioskRequired bool     `json:"kiosk_required"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validator.ValidateOffice(v, input.Name, input.Timezone)
+	validator.ValidateGeofence(v, input.Latitude, input.Longitude, input.RadiusM)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	office := &data.Office{
+		Name:          input.Name,
+		Timezone:      input.Timezone,
+		Latitude:      input.Latitude,
+		Longitude:     input.Longitude,
+		RadiusM:       input.RadiusM,
+		KioskRequired: input.KioskRequired,
+		CreatedBy:     user.ID,
+		UpdatedBy:     user.ID,
+	}
+
+	err = app.Models.Offices.Insert(office)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateOfficeName):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{
+		"message": "office created successfully",
+		"office":  office,
+	}, nil)
+}
+
+// listOfficesHandler lists all office locations
+func (app *Application) listOfficesHandler(w http.ResponseWriter, r *http.Request) {
+	offices, err := app.Models.Offices.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"offices": offices}, nil)
+}
+
+// updateOfficeHandler lets admins rename an office or change its time zone,
+// geofence or kiosk requirement. Fields which are not provided are left unchanged, except that the
+// geofence coordinates and radius must be sent together. Sending clear_geofence
+// removes the geofence.
+func (app *Application) updateOfficeHandler(w http.ResponseWriter, r *http.Request) {
+	office, ok := app.fetchOffice(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name          *string  `json:"name"`
+		Timezone      *string  `json:"timezone"`
+		Latitude      *float64 `json:"latitude"`
+		Longitude     *float64 `json:"longitude"`
+		RadiusM       *int     `json:"radius_m"`
+		ClearGeofence bool     `json:"clear_geofence"`
+		KioskRequired *bool    `json:"kiosk_required"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		office.Name = *input.Name
+	}
+	if input.Timezone != nil {
+		office.Timezone = *input.Timezone
+	}
+	geofenceSent := input.Latitude != nil || input.Longitude != nil || input.RadiusM != nil
+	if geofenceSent || input.ClearGeofence {
+		office.Latitude = input.Latitude
+		office.Longitude = input.Longitude
+		office.RadiusM = input.RadiusM
+	}
+	if input.KioskRequired != nil {
+		office.KioskRequired = *input.KioskRequired
+	}
+
+	v := validator.New()
+	v.Check(!(geofenceSent && input.ClearGeofence), "clear_geofence", "must not be set along with latitude, longitude or radius_m")
+	validator.ValidateOffice(v, office.Name, office.Timezone)
+	validator.ValidateGeofence(v, office.Latitude, office.Longitude, office.RadiusM)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	office.UpdatedBy = app.contextGetUser(r).ID
+
+	err = app.Models.Offices.Update(office)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateOfficeName):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message": "office updated successfully",
+		"office":  office,
+	}, nil)
+}
+
+// assignUserOfficeHandler lets admins assign an employee to an office. Sending
+// a null office_id removes the assignment.
+func (app *Application) assignUserOfficeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		OfficeID *int64 `json:"office_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	employee, ok := app.fetchUser(w, r)
+	if !ok {
+		return
+	}
+
+	if input.OfficeID != nil {
+		_, err = app.Models.Offices.Get(*input.OfficeID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.failedValidationResponse(w, r, map[string]string{"office_id": "must be an existing office"})
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	err = app.Models.Users.SetOffice(employee, input.OfficeID, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message": "office assigned successfully",
+		"user":    employee,
+	}, nil)
+}
+
+// setUserRemoteAllowedHandler lets admins exempt an employee from their office's
+// geofence, or subject them to it again
+func (app *Application) setUserRemoteAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RemoteAllowed *bool `json:"remote_allowed"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.RemoteAllowed == nil {
+		app.failedValidationResponse(w, r, map[string]string{"remote_allowed": "must be provided"})
+		return
+	}
+
+	employee, ok := app.fetchUser(w, r)
+	if !ok {
+		return
+	}
+
+	err = app.Models.Users.SetRemoteAllowed(employee, *input.RemoteAllowed, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message": "remote access updated successfully",
+		"user":    employee,
+	}, nil)
+}
+
+// fetchOffice loads the office identified by the "id" URL parameter, writing the
+// error response itself when it cannot
+func (app *Application) fetchOffice(w http.ResponseWriter, r *http.Request) (*data.Office, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	office, err := app.Models.Offices.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return office, true
+}
+
+// fetchUser loads the user identified by the "id" URL parameter, writing the
+// error response itself when it cannot
+func (app *Application) fetchUser(w http.ResponseWriter, r *http.Request) (*data.User, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	user, err := app.Models.Users.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return user, true
+}
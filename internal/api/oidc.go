@@ -0,0 +1,208 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/oidc"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// How long users have to log in with the identity provider and come back
+const oidcLoginTTL = 10 * time.Minute
+
+// oidcLoginHandler starts a single sign-on login. The client sends the user to
+// the returned authorization URL, and the identity provider sends them back to
+// the configured redirect URL with a code and the state, which the client
+// posts to oidcCallbackHandler. The client should check that the state it gets
+// back is the one it started with.
+func (app *Application) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if app.OIDC == nil {
+		app.errorResponse(w, r, http.StatusNotFound, "single sign-on is not configured")
+		return
+	}
+
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	nonce, err := oidc.GenerateNonce()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	login, err := app.Models.OIDCLogins.New(verifier, nonce, oidcLoginTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	authorizationURL, err := app.OIDC.AuthCodeURL(r.Context(), login.State, login.Nonce, login.CodeVerifier)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"authorization_url": authorizationURL,
+		"state":             login.State,
+		"expiry":            login.Expiry,
+	}, nil)
+}
+
+// oidcCallbackHandler finishes a single sign-on login with the code and state
+// the identity provider sent the user back with. The user is found by the
+// verified email address of the ID token, and created as an employee if they
+// have no account and auto-provisioning is enabled. They then get the same
+// tokens as with a password, or a two-factor challenge if they enabled it.
+func (app *Application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if app.OIDC == nil {
+		app.errorResponse(w, r, http.StatusNotFound, "single sign-on is not configured")
+		return
+	}
+
+	var input struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+		// Name of the device logging in, shown in the user's sessions
+		Device string `json:"device"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Code != "", "code", "must be provided")
+	v.Check(input.State != "", "state", "must be provided")
+	v.Check(len(input.Device) <= 100, "device", "must not be more than 100 bytes long")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	login, err := app.Models.OIDCLogins.Consume(input.State)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusUnauthorized, "invalid or expired single sign-on state, please log in again")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	claims, err := app.OIDC.Exchange(r.Context(), input.Code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrRejected), errors.Is(err, oidc.ErrInvalidIDToken):
+			app.Logger.Printf("single sign-on: %v", err)
+			app.errorResponse(w, r, http.StatusUnauthorized, "single sign-on failed, please log in again")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		app.errorResponse(w, r, http.StatusForbidden,
+			"the identity provider did not share a verified email address for your account")
+		return
+	}
+
+	user, err := app.Models.Users.GetByEmail(claims.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			user, err = app.provisionOIDCUser(claims)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			if user == nil {
+				app.errorResponse(w, r, http.StatusForbidden,
+					"there is no account for your email address, please ask an admin to create one")
+				return
+			}
+		default:
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if !user.Active {
+		app.errorResponse(w, r, http.StatusUnauthorized, "your account has been deactivated")
+		return
+	}
+
+	// The identity provider verified the email address, as the invitation
+	// would have, so invited users need not set a password
+	if !user.Activated {
+		err = app.Models.Users.Activate(user)
+		if err != nil && !errors.Is(err, data.ErrActivated) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		err = app.Models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// Users with two-factor authentication finish logging in with a code
+	if user.TwoFactor {
+		app.twoFactorChallenge(w, r, user)
+		return
+	}
+
+	app.startSession(w, r, user, input.Device)
+}
+
+// provisionOIDCUser creates an activated employee without a password for the
+// claims of a user logging in with single sign-on, if auto-provisioning is
+// enabled. It returns nil if it is not, or if the claims do not make a valid
+// user.
+func (app *Application) provisionOIDCUser(claims *oidc.Claims) (*data.User, error) {
+	if !app.Config.OIDC.AutoProvision {
+		return nil, nil
+	}
+
+	name := strings.TrimSpace(claims.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	v := validator.New()
+	validator.ValidateUser(v, name, claims.Email, "employee", 0)
+	if !v.Valid() {
+		app.Logger.Printf("single sign-on: cannot provision a user for %s: %v", claims.Email, v.Errors)
+		return nil, nil
+	}
+
+	user := &data.User{
+		Role:      "employee",
+		Name:      name,
+		Email:     claims.Email,
+		Activated: true,
+	}
+
+	err := app.Models.Users.Insert(user)
+	if err != nil {
+		// Another login of the same user may have just created them
+		if errors.Is(err, data.ErrDuplicateEmail) {
+			return app.Models.Users.GetByEmail(claims.Email)
+		}
+		return nil, err
+	}
+
+	app.Logger.Printf("single sign-on: provisioned user %d for %s", user.ID, user.Email)
+	return user, nil
+}
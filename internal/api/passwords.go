@@ -0,0 +1,218 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// How long a password reset token stays valid
+const passwordResetTTL = 45 * time.Minute
+
+// changePasswordHandler lets users change their own password, which requires
+// their current password. Outstanding password reset tokens are discarded, and
+// every session but the current one is logged out.
+func (app *Application) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		CurrentPassword string `json:"current_password"`
+		Password        string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.CurrentPassword != "", "current_password", "must be provided")
+	validator.ValidatePasswordPlaintext(v, input.Password)
+	v.Check(input.Password != input.CurrentPassword, "password", "must be different from the current password")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	match, err := user.Password.Matches(input.CurrentPassword)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !match {
+		v.AddError("current_password", "is incorrect")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = user.Password.Set(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	user.UpdatedBy = user.ID
+
+	err = app.Models.Users.UpdatePassword(user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.Models.Tokens.DeleteAllForUser(data.ScopePasswordReset, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Log out every other session, in case the password was changed because
+	// someone else knew it
+	revoked, err := app.Models.Sessions.RevokeOthersForUser(user.ID, app.contextGetAccessToken(r).SessionID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":          "password changed successfully",
+		"sessions_revoked": revoked,
+	}, nil)
+}
+
+// createPasswordResetTokenHandler emails a password reset token to the user
+// with the email. The response is the same whether or not there is such a user,
+// so that it cannot be used to find out which emails have an account.
+func (app *Application) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validator.ValidateEmail(v, input.Email)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	env := envelope{"message": "if an account with this email exists, you will receive an email with instructions to reset your password"}
+
+	user, err := app.Models.Users.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.writeJSON(w, http.StatusAccepted, env, nil)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// Pending users activate their account with their invitation instead
+	if !user.Active || !user.Activated {
+		app.writeJSON(w, http.StatusAccepted, env, nil)
+		return
+	}
+
+	token, err := app.Models.Tokens.New(user.ID, passwordResetTTL, data.ScopePasswordReset)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		emailData := map[string]any{
+			"Name":   user.Name,
+			"Token":  token.Plaintext,
+			"Expiry": token.Expiry.UTC().Format(time.RFC1123),
+		}
+
+		err := app.Mailer.Send(user.Email, "password_reset.tmpl", emailData)
+		if err != nil {
+			app.Logger.Println(err)
+		}
+	})
+
+	app.writeJSON(w, http.StatusAccepted, env, nil)
+}
+
+// resetPasswordHandler sets a new password for the user a password reset token
+// was issued to. The token can only be used once, and every other reset token
+// of the user is discarded.
+func (app *Application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password string `json:"password"`
+		Token    string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validator.ValidatePasswordPlaintext(v, input.Password)
+	v.Check(input.Token != "", "token", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	userID, err := app.Models.Tokens.Consume(data.ScopePasswordReset, input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired password reset token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.Models.Users.Get(userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !user.Active {
+		app.errorResponse(w, r, http.StatusForbidden, "your account has been deactivated")
+		return
+	}
+
+	err = user.Password.Set(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	user.UpdatedBy = user.ID
+
+	err = app.Models.Users.UpdatePassword(user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.Models.Tokens.DeleteAllForUser(data.ScopePasswordReset, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Whoever knew the old password must not stay logged in
+	err = app.Models.Sessions.RevokeAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"message": "your password was reset successfully, please log in again"}, nil)
+}
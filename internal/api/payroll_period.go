@@ -11,8 +11,9 @@ import (
 
 func (app *Application) createPayrollPeriodHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		StartDate string `json:"start_date"`
-		EndDate   string `json:"end_date"`
+		StartDate string  `json:"start_date"`
+		EndDate   string  `json:"end_date"`
+		PayDate   *string `json:"pay_date"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -47,6 +48,12 @@ func (app *Application) createPayrollPeriodHandler(w http.ResponseWriter, r *htt
 	// Domain rule: end_date >= start_date
 	v.Check(!endDate.Before(startDate), "end_date", "must be on or after start_date")
 
+	if input.PayDate != nil {
+		payDate, err := time.Parse("2006-01-02", *input.PayDate)
+		v.Check(err == nil, "pay_date", "must be a valid date (YYYY-MM-DD)")
+		v.Check(err != nil || !payDate.Before(startDate), "pay_date", "must be on or after start_date")
+	}
+
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
@@ -59,6 +66,7 @@ func (app *Application) createPayrollPeriodHandler(w http.ResponseWriter, r *htt
 	payrollPeriod := data.PayrollPeriod{
 		StartDate: startDate.Format("2006-01-02"),
 		EndDate:   endDate.Format("2006-01-02"),
+		PayDate:   input.PayDate,
 		CreatedBy: user.ID,
 		UpdatedBy: user.ID,
 	}
@@ -81,3 +89,130 @@ func (app *Application) createPayrollPeriodHandler(w http.ResponseWriter, r *htt
 		"message": "payroll period created successfully",
 	}, nil)
 }
+
+// showPayrollInputsHandler returns the per-employee attendance figures which feed
+// into payroll for a period
+func (app *Application) showPayrollInputsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	payrollPeriod, err := app.Models.PayrollPeriod.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	inputs, err := app.Models.PayrollPeriod.Inputs(payrollPeriod)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"payroll_period": payrollPeriod,
+		"inputs":         inputs,
+	}, nil)
+}
+
+// setPayrollPayDateHandler lets admins schedule the date the salaries of a
+// period are paid out, shown in the employees' calendar feeds. A null pay_date
+// unschedules it.
+func (app *Application) setPayrollPayDateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		PayDate *string `json:"pay_date"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	payrollPeriod, err := app.Models.PayrollPeriod.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+	if input.PayDate != nil {
+		payDate, err := time.Parse("2006-01-02", *input.PayDate)
+		v.Check(err == nil, "pay_date", "must be a valid date (YYYY-MM-DD)")
+		v.Check(err != nil || payDate.Format("2006-01-02") >= payrollPeriod.StartDate, "pay_date",
+			"must be on or after the start of the period")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.Models.PayrollPeriod.SetPayDate(payrollPeriod, input.PayDate, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"payroll_period": payrollPeriod}, nil)
+}
+
+// processPayrollPeriodHandler lets admins mark a payroll period as processed
+// once salaries were computed from its inputs. Attendance, timesheets and
+// rostered attendance of its dates can no longer change afterwards.
+func (app *Application) processPayrollPeriodHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	payrollPeriod, err := app.Models.PayrollPeriod.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.Models.PayrollPeriod.Process(payrollPeriod, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrPayrollPeriodProcessed):
+			app.errorResponse(w, r, http.StatusConflict, "the payroll period has already been processed")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":        "payroll period processed successfully, its attendance is now locked",
+		"payroll_period": payrollPeriod,
+	}, nil)
+}
@@ -0,0 +1,307 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// showMeHandler returns the authenticated user's own profile, along with their
+// pending profile change request if they have one
+func (app *Application) showMeHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	pending, err := app.Models.ProfileChanges.GetPending(user.ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"user":           user,
+		"profile_change": pending,
+	}, nil)
+}
+
+// updateMeHandler lets users update their own profile. Contact details are
+// changed right away, while changes to their name or bank account create a
+// profile change request which an admin must approve. Only the fields sent are
+// changed.
+func (app *Application) updateMeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Phone                 *string `json:"phone"`
+		Address               *string `json:"address"`
+		EmergencyContactName  *string `json:"emergency_contact_name"`
+		EmergencyContactPhone *string `json:"emergency_contact_phone"`
+		Name                  *string `json:"name"`
+		BankName              *string `json:"bank_name"`
+		BankAccountNumber     *string `json:"bank_account_number"`
+		BankAccountHolder     *string `json:"bank_account_holder"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	contact := user.Profile
+
+	if input.Phone != nil {
+		contact.Phone = *input.Phone
+	}
+	if input.Address != nil {
+		contact.Address = *input.Address
+	}
+	if input.EmergencyContactName != nil {
+		contact.EmergencyContactName = *input.EmergencyContactName
+	}
+	if input.EmergencyContactPhone != nil {
+		contact.EmergencyContactPhone = *input.EmergencyContactPhone
+	}
+
+	v := validator.New()
+	validator.ValidatePhone(v, contact.Phone, "phone")
+	v.Check(len(contact.Address) <= 1000, "address", "must not be more than 1000 bytes long")
+	v.Check(len(contact.EmergencyContactName) <= 255, "emergency_contact_name", "must not be more than 255 bytes long")
+	validator.ValidatePhone(v, contact.EmergencyContactPhone, "emergency_contact_phone")
+
+	// Sensitive fields which differ from the profile go into a change request
+	change := &data.ProfileChange{UserID: user.ID}
+	if input.Name != nil && *input.Name != user.Name {
+		v.Check(*input.Name != "", "name", "must be provided")
+		v.Check(len(*input.Name) <= 255, "name", "must not be more than 255 bytes long")
+		change.Name = input.Name
+	}
+
+	bank := user.Profile
+	if input.BankName != nil {
+		bank.BankName = *input.BankName
+	}
+	if input.BankAccountNumber != nil {
+		bank.BankAccountNumber = *input.BankAccountNumber
+	}
+	if input.BankAccountHolder != nil {
+		bank.BankAccountHolder = *input.BankAccountHolder
+	}
+	if bank.BankName != user.Profile.BankName ||
+		bank.BankAccountNumber != user.Profile.BankAccountNumber ||
+		bank.BankAccountHolder != user.Profile.BankAccountHolder {
+		validator.ValidateBankAccount(v, bank.BankName, bank.BankAccountNumber, bank.BankAccountHolder)
+		change.BankName = &bank.BankName
+		change.BankAccountNumber = &bank.BankAccountNumber
+		change.BankAccountHolder = &bank.BankAccountHolder
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	requested := change.Name != nil || change.BankName != nil
+	if requested {
+		err = app.Models.ProfileChanges.Insert(change)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrProfileChangeExists):
+				app.errorResponse(w, r, http.StatusConflict,
+					"you already have a pending profile change request, cancel it to request another")
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	if contact != user.Profile {
+		user.Profile = contact
+		user.UpdatedBy = user.ID
+
+		err = app.Models.Users.UpdateContact(user)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	env := envelope{
+		"message": "profile updated successfully",
+		"user":    user,
+	}
+	if requested {
+		env["message"] = "profile updated successfully, the changes to your name and bank account await approval"
+		env["profile_change"] = change
+	}
+
+	app.writeJSON(w, http.StatusOK, env, nil)
+}
+
+// listMyProfileChangesHandler lists the authenticated user's own profile change
+// requests, newest first
+func (app *Application) listMyProfileChangesHandler(w http.ResponseWriter, r *http.Request) {
+	changes, err := app.Models.ProfileChanges.GetAll(app.contextGetUser(r).ID, "")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"profile_changes": changes}, nil)
+}
+
+// cancelProfileChangeHandler lets users withdraw their own pending profile
+// change request
+func (app *Application) cancelProfileChangeHandler(w http.ResponseWriter, r *http.Request) {
+	pc, ok := app.fetchProfileChange(w, r)
+	if !ok {
+		return
+	}
+
+	// Requests of other users are reported as not found
+	if pc.UserID != app.contextGetUser(r).ID {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err := app.Models.ProfileChanges.Cancel(pc)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrProfileChangeNotPending):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":        "profile change request cancelled successfully",
+		"profile_change": pc,
+	}, nil)
+}
+
+// listProfileChangesHandler lets admins list profile change requests, filtered
+// by status
+func (app *Application) listProfileChangesHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+
+	v := validator.New()
+	if status != "" {
+		v.Check(validator.In(status,
+			data.ProfileChangePending,
+			data.ProfileChangeApproved,
+			data.ProfileChangeRejected,
+			data.ProfileChangeCancelled,
+		), "status", "must be pending, approved, rejected or cancelled")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	changes, err := app.Models.ProfileChanges.GetAll(0, status)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"profile_changes": changes}, nil)
+}
+
+// approveProfileChangeHandler lets admins approve a pending profile change
+// request, which applies the changes to the user
+func (app *Application) approveProfileChangeHandler(w http.ResponseWriter, r *http.Request) {
+	app.reviewProfileChange(w, r, data.ProfileChangeApproved)
+}
+
+// rejectProfileChangeHandler lets admins reject a pending profile change request
+func (app *Application) rejectProfileChangeHandler(w http.ResponseWriter, r *http.Request) {
+	app.reviewProfileChange(w, r, data.ProfileChangeRejected)
+}
+
+// reviewProfileChange approves or rejects a profile change request with an
+// optional note, and notifies the user who requested it
+func (app *Application) reviewProfileChange(w http.ResponseWriter, r *http.Request, status string) {
+	var input struct {
+		Note string `json:"note"`
+	}
+
+	err := app.readOptionalJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	pc, ok := app.fetchProfileChange(w, r)
+	if !ok {
+		return
+	}
+
+	admin := app.contextGetUser(r)
+
+	requester, err := app.Models.Users.Get(pc.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if requester.ID == admin.ID {
+		app.errorResponse(w, r, http.StatusForbidden, "you cannot review your own profile change request")
+		return
+	}
+	if !canManageUser(admin, requester) {
+		app.errorResponse(w, r, http.StatusForbidden, "you must be a super admin to manage admins")
+		return
+	}
+
+	if status == data.ProfileChangeApproved {
+		err = app.Models.ProfileChanges.Approve(pc, admin.ID, input.Note)
+	} else {
+		err = app.Models.ProfileChanges.Reject(pc, admin.ID, input.Note)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrProfileChangeNotPending):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	message := fmt.Sprintf("Your profile change request was %s.", status)
+	if input.Note != "" {
+		message += " Note: " + input.Note
+	}
+	app.notify(r, pc.UserID, "profile_change_"+status, "Profile change "+status, message)
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":        fmt.Sprintf("profile change request %s successfully", status),
+		"profile_change": pc,
+	}, nil)
+}
+
+// fetchProfileChange loads the profile change request identified by the "id"
+// URL parameter, writing the error response itself when it cannot
+func (app *Application) fetchProfileChange(w http.ResponseWriter, r *http.Request) (*data.ProfileChange, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	pc, err := app.Models.ProfileChanges.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return pc, true
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// createCostCenterHandler lets admins add a cost center projects are billed to
+func (app *Application) createCostCenterHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+		Name string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validator.ValidateCode(v, input.Code)
+	v.Check(input.Name != "", "name", "must be provided")
+	v.Check(len(input.Name) <= 255, "name", "must not be more than 255 bytes long")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	costCenter := &data.CostCenter{
+		Code:      input.Code,
+		Name:      input.Name,
+		CreatedBy: app.contextGetUser(r).ID,
+	}
+
+	err = app.Models.CostCenters.Insert(costCenter)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateCostCenterCode):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{
+		"message":     "cost center created successfully",
+		"cost_center": costCenter,
+	}, nil)
+}
+
+// listCostCentersHandler lists all cost centers
+func (app *Application) listCostCentersHandler(w http.ResponseWriter, r *http.Request) {
+	costCenters, err := app.Models.CostCenters.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"cost_centers": costCenters}, nil)
+}
+
+// createProjectHandler lets admins add a client project under a cost center
+func (app *Application) createProjectHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code         string  `json:"code"`
+		Name         string  `json:"name"`
+		Client       *string `json:"client"`
+		CostCenterID int64   `json:"cost_center_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validator.ValidateProject(v, input.Code, input.Name, input.Client)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.checkCostCenter(w, r, input.CostCenterID) {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	project := &data.Project{
+		Code:         input.Code,
+		Name:         input.Name,
+		Client:       input.Client,
+		CostCenterID: input.CostCenterID,
+		Active:       true,
+		CreatedBy:    user.ID,
+		UpdatedBy:    user.ID,
+	}
+
+	err = app.Models.Projects.Insert(project)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateProjectCode):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{
+		"message": "project created successfully",
+		"project": project,
+	}, nil)
+}
+
+// listProjectsHandler lists all projects, including inactive ones
+func (app *Application) listProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	projects, err := app.Models.Projects.GetAll(false)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"projects": projects}, nil)
+}
+
+// listActiveProjectsHandler lists the projects employees can allocate hours to
+func (app *Application) listActiveProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	projects, err := app.Models.Projects.GetAll(true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"projects": projects}, nil)
+}
+
+// updateProjectHandler lets admins change a project's details or deactivate it.
+// Fields which are not provided are left unchanged.
+func (app *Application) updateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	project, err := app.Models.Projects.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Code         *string `json:"code"`
+		Name         *string `json:"name"`
+		Client       *string `json:"client"`
+		CostCenterID *int64  `json:"cost_center_id"`
+		Active       *bool   `json:"active"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Code != nil {
+		project.Code = *input.Code
+	}
+	if input.Name != nil {
+		project.Name = *input.Name
+	}
+	if input.Client != nil {
+		project.Client = input.Client
+	}
+	if input.Active != nil {
+		project.Active = *input.Active
+	}
+
+	v := validator.New()
+	validator.ValidateProject(v, project.Code, project.Name, project.Client)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if input.CostCenterID != nil {
+		if !app.checkCostCenter(w, r, *input.CostCenterID) {
+			return
+		}
+		project.CostCenterID = *input.CostCenterID
+	}
+
+	project.UpdatedBy = app.contextGetUser(r).ID
+
+	err = app.Models.Projects.Update(project)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateProjectCode):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message": "project updated successfully",
+		"project": project,
+	}, nil)
+}
+
+// projectCostsHandler reports the hours employees allocated to each project
+// between two dates and their salary cost
+func (app *Application) projectCostsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	from, to := qs.Get("from"), qs.Get("to")
+
+	v := validator.New()
+	start, err := time.Parse("2006-01-02", from)
+	v.Check(err == nil, "from", "must be a valid date (YYYY-MM-DD)")
+	end, err := time.Parse("2006-01-02", to)
+	v.Check(err == nil, "to", "must be a valid date (YYYY-MM-DD)")
+	if v.Valid() {
+		v.Check(!end.Before(start), "to", "must not be before from")
+		v.Check(end.Sub(start) < maxTimesheetDays*24*time.Hour, "to",
+			fmt.Sprintf("must not be more than %d days after from", maxTimesheetDays-1))
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	costs, err := app.Models.Timesheets.ProjectCosts(from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"from":     from,
+		"to":       to,
+		"projects": costs,
+	}, nil)
+}
+
+// checkCostCenter reports whether the cost center exists, writing the error
+// response itself when it does not
+func (app *Application) checkCostCenter(w http.ResponseWriter, r *http.Request, id int64) bool {
+	_, err := app.Models.CostCenters.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.failedValidationResponse(w, r, map[string]string{"cost_center_id": "must be an existing cost center"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return false
+	}
+	return true
+}
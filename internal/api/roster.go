@@ -0,0 +1,640 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// Longest period of roster which can be listed at once
+const maxRosterDays = 92
+
+// createShiftHandler lets admins define a shift, e.g. a night shift from 22:00
+// to 06:00 or a paid on-call standby
+func (app *Application) createShiftHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code      string `json:"code"`
+		Name      string `json:"name"`
+		StartTime string `json:"start_time"`
+		EndTime   string `json:"end_time"`
+		OnCall    bool   `json:"on_call"`
+		Allowance int64  `json:"allowance"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validator.ValidateCode(v, input.Code)
+	v.Check(input.Name != "", "name", "must be provided")
+	v.Check(len(input.Name) <= 255, "name", "must not be more than 255 bytes long")
+	_, err = time.Parse("15:04", input.StartTime)
+	v.Check(err == nil, "start_time", "must be a valid time (HH:MM)")
+	_, err = time.Parse("15:04", input.EndTime)
+	v.Check(err == nil, "end_time", "must be a valid time (HH:MM)")
+	v.Check(input.Allowance >= 0, "allowance", "must not be negative")
+	v.Check(input.OnCall || input.Allowance == 0, "allowance", "can only be paid for on-call shifts")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	shift := &data.Shift{
+		Code:      input.Code,
+		Name:      input.Name,
+		StartTime: input.StartTime,
+		EndTime:   input.EndTime,
+		OnCall:    input.OnCall,
+		Allowance: input.Allowance,
+		CreatedBy: user.ID,
+		UpdatedBy: user.ID,
+	}
+
+	err = app.Models.Shifts.Insert(shift)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateShiftCode):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{
+		"message": "shift created successfully",
+		"shift":   shift,
+	}, nil)
+}
+
+// listShiftsHandler lists all shifts
+func (app *Application) listShiftsHandler(w http.ResponseWriter, r *http.Request) {
+	shifts, err := app.Models.Shifts.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"shifts": shifts}, nil)
+}
+
+// createRosterEntryHandler lets admins roster a shift to an employee on a date.
+// The shift is rejected if it overlaps another of the employee's shifts or
+// leaves them less than the minimum rest between working shifts.
+func (app *Application) createRosterEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		EmployeeID int64  `json:"employee_id"`
+		ShiftID    int64  `json:"shift_id"`
+		Date       string `json:"date"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	_, err = time.Parse("2006-01-02", input.Date)
+	v.Check(err == nil, "date", "must be a valid date (YYYY-MM-DD)")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	employee, err := app.Models.Users.Get(input.EmployeeID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v.Check(employee != nil && employee.Role == "employee" && employee.Active, "employee_id", "must be an active employee")
+
+	shift, err := app.Models.Shifts.Get(input.ShiftID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v.Check(shift != nil, "shift_id", "must be an existing shift")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// The shift starts and ends at the local time of the employee's office
+	loc, err := app.employeeLocation(employee)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	startsAt, endsAt, err := shift.Window(input.Date, loc)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	entry := &data.RosterEntry{
+		EmployeeID: employee.ID,
+		ShiftID:    shift.ID,
+		ShiftCode:  shift.Code,
+		OnCall:     shift.OnCall,
+		ShiftDate:  input.Date,
+		StartsAt:   startsAt,
+		EndsAt:     endsAt,
+		CreatedBy:  user.ID,
+		UpdatedBy:  user.ID,
+	}
+
+	conflicts, err := app.Models.Roster.Insert(entry, app.Config.Roster.MinRest)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRosterOverlap):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if len(conflicts) > 0 {
+		app.rosterConflictResponse(w, r, conflicts)
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{
+		"message":      "shift rostered successfully",
+		"roster_entry": entry,
+	}, nil)
+}
+
+// listRosterHandler lets admins view the roster between two dates, of one
+// employee if employee_id is provided
+func (app *Application) listRosterHandler(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := app.readRosterRange(w, r, time.Now())
+	if !ok {
+		return
+	}
+
+	var employeeID *int64
+	if qs := r.URL.Query().Get("employee_id"); qs != "" {
+		id, err := strconv.ParseInt(qs, 10, 64)
+		if err != nil || id < 1 {
+			app.failedValidationResponse(w, r, map[string]string{"employee_id": "must be a positive integer"})
+			return
+		}
+		employeeID = &id
+	}
+
+	entries, err := app.Models.Roster.GetAll(employeeID, from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"roster": entries}, nil)
+}
+
+// deleteRosterEntryHandler lets admins remove a shift which has not started yet
+// from the roster
+func (app *Application) deleteRosterEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	entry, err := app.Models.Roster.Get(id)
+	if err == nil {
+		if entry.StartsAt.Before(time.Now()) {
+			app.errorResponse(w, r, http.StatusConflict, "cannot remove a shift which has already started")
+			return
+		}
+		err = app.Models.Roster.Delete(id)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"message": "roster entry deleted successfully"}, nil)
+}
+
+// listMyRosterHandler lists the employee's shifts between two dates (the
+// current month by default)
+func (app *Application) listMyRosterHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	now, err := app.employeeNow(user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	from, to, ok := app.readRosterRange(w, r, now)
+	if !ok {
+		return
+	}
+
+	entries, err := app.Models.Roster.GetAll(&user.ID, from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"roster": entries}, nil)
+}
+
+// createShiftSwapHandler lets employees offer one of their upcoming shifts to a
+// peer, optionally taking one of the peer's upcoming shifts in exchange. The
+// peer must accept, then an admin must approve the swap.
+func (app *Application) createShiftSwapHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		EntryID     int64  `json:"entry_id"`
+		PeerID      int64  `json:"peer_id"`
+		PeerEntryID *int64 `json:"peer_entry_id"`
+		Reason      string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	v := validator.New()
+	v.Check(input.PeerID != user.ID, "peer_id", "must not be yourself")
+	v.Check(len(input.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+
+	peer, err := app.Models.Users.Get(input.PeerID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v.Check(peer != nil && peer.Role == "employee" && peer.Active, "peer_id", "must be an active employee")
+
+	given, err := app.upcomingRosterEntry(input.EntryID, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v.Check(given != nil, "entry_id", "must be one of your upcoming shifts")
+
+	var taken *data.RosterEntry
+	if input.PeerEntryID != nil {
+		taken, err = app.upcomingRosterEntry(*input.PeerEntryID, input.PeerID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		v.Check(taken != nil, "peer_entry_id", "must be one of the peer's upcoming shifts")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	swap := &data.ShiftSwap{
+		RequesterID:      user.ID,
+		RequesterEntryID: given.ID,
+		PeerID:           peer.ID,
+		PeerEntryID:      input.PeerEntryID,
+		Reason:           input.Reason,
+	}
+
+	// Report conflicts now rather than after the peer and admin have answered
+	conflicts, err := app.shiftSwapConflicts(swap, given, taken)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if len(conflicts) > 0 {
+		app.rosterConflictResponse(w, r, conflicts)
+		return
+	}
+
+	err = app.Models.ShiftSwaps.Insert(swap)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrShiftSwapExists):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notify(r, peer.ID, "shift_swap_requested", "Shift swap request",
+		fmt.Sprintf("%s asked you to take their %s shift on %s.", user.Name, given.ShiftCode, given.ShiftDate))
+
+	app.writeJSON(w, http.StatusCreated, envelope{
+		"message":    "shift swap requested successfully",
+		"shift_swap": swap,
+	}, nil)
+}
+
+// listMyShiftSwapsHandler lists the swap requests the employee made or received
+func (app *Application) listMyShiftSwapsHandler(w http.ResponseWriter, r *http.Request) {
+	swaps, err := app.Models.ShiftSwaps.GetAllForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"shift_swaps": swaps}, nil)
+}
+
+// acceptShiftSwapHandler lets the peer agree to a swap, which then awaits review
+func (app *Application) acceptShiftSwapHandler(w http.ResponseWriter, r *http.Request) {
+	app.respondShiftSwap(w, r, true)
+}
+
+// declineShiftSwapHandler lets the peer turn a swap down
+func (app *Application) declineShiftSwapHandler(w http.ResponseWriter, r *http.Request) {
+	app.respondShiftSwap(w, r, false)
+}
+
+// respondShiftSwap records the peer's answer to a swap request addressed to them
+func (app *Application) respondShiftSwap(w http.ResponseWriter, r *http.Request, accept bool) {
+	swap, ok := app.fetchShiftSwap(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if swap.PeerID != user.ID {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if accept {
+		conflicts, err := app.shiftSwapConflicts(swap, nil, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if len(conflicts) > 0 {
+			app.rosterConflictResponse(w, r, conflicts)
+			return
+		}
+	}
+
+	err := app.Models.ShiftSwaps.Respond(swap, accept)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrShiftSwapNotPending):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notify(r, swap.RequesterID, "shift_swap_"+swap.Status, "Shift swap "+swap.Status,
+		fmt.Sprintf("%s %s your shift swap request.", user.Name, swap.Status))
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":    fmt.Sprintf("shift swap %s successfully", swap.Status),
+		"shift_swap": swap,
+	}, nil)
+}
+
+// cancelShiftSwapHandler lets the requester withdraw a swap request until it is
+// reviewed
+func (app *Application) cancelShiftSwapHandler(w http.ResponseWriter, r *http.Request) {
+	swap, ok := app.fetchShiftSwap(w, r)
+	if !ok {
+		return
+	}
+
+	if swap.RequesterID != app.contextGetUser(r).ID {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err := app.Models.ShiftSwaps.Cancel(swap)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrShiftSwapNotOpen):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":    "shift swap cancelled successfully",
+		"shift_swap": swap,
+	}, nil)
+}
+
+// listShiftSwapsHandler lets admins list swap requests, filtered by status
+func (app *Application) listShiftSwapsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+
+	v := validator.New()
+	v.Check(status == "" || validator.In(status, data.SwapStatusPending, data.SwapStatusAccepted, data.SwapStatusDeclined,
+		data.SwapStatusApproved, data.SwapStatusRejected, data.SwapStatusCancelled),
+		"status", "must be pending, accepted, declined, approved, rejected or cancelled")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	swaps, err := app.Models.ShiftSwaps.GetAll(status)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"shift_swaps": swaps}, nil)
+}
+
+// approveShiftSwapHandler lets admins approve an accepted swap, exchanging the
+// shifts on the roster
+func (app *Application) approveShiftSwapHandler(w http.ResponseWriter, r *http.Request) {
+	app.reviewShiftSwap(w, r, data.SwapStatusApproved)
+}
+
+// rejectShiftSwapHandler lets admins reject an accepted swap
+func (app *Application) rejectShiftSwapHandler(w http.ResponseWriter, r *http.Request) {
+	app.reviewShiftSwap(w, r, data.SwapStatusRejected)
+}
+
+// reviewShiftSwap approves or rejects a shift swap with an optional note
+func (app *Application) reviewShiftSwap(w http.ResponseWriter, r *http.Request, status string) {
+	var input struct {
+		Note string `json:"note"`
+	}
+
+	err := app.readOptionalJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	swap, ok := app.fetchShiftSwap(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	var conflicts []*data.RosterConflict
+	if status == data.SwapStatusApproved {
+		conflicts, err = app.Models.ShiftSwaps.Approve(swap, user.ID, input.Note, app.Config.Roster.MinRest)
+	} else {
+		err = app.Models.ShiftSwaps.Reject(swap, user.ID, input.Note)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrShiftSwapNotAccepted),
+			errors.Is(err, data.ErrShiftSwapStale),
+			errors.Is(err, data.ErrShiftStarted),
+			errors.Is(err, data.ErrRosterOverlap):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if len(conflicts) > 0 {
+		app.rosterConflictResponse(w, r, conflicts)
+		return
+	}
+
+	for _, id := range []int64{swap.RequesterID, swap.PeerID} {
+		app.notify(r, id, "shift_swap_"+status, "Shift swap "+status,
+			fmt.Sprintf("The shift swap request #%d was %s.", swap.ID, status))
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":    fmt.Sprintf("shift swap %s successfully", status),
+		"shift_swap": swap,
+	}, nil)
+}
+
+// shiftSwapConflicts returns the conflicts the swap would cause on the rosters
+// of the requester and the peer. The entries are loaded when nil.
+func (app *Application) shiftSwapConflicts(swap *data.ShiftSwap, given, taken *data.RosterEntry) ([]*data.RosterConflict, error) {
+	var err error
+	if given == nil {
+		given, err = app.Models.Roster.Get(swap.RequesterEntryID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	exclude := []int64{given.ID}
+
+	if taken == nil && swap.PeerEntryID != nil {
+		taken, err = app.Models.Roster.Get(*swap.PeerEntryID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if taken != nil {
+		exclude = append(exclude, taken.ID)
+	}
+
+	minRest := app.Config.Roster.MinRest
+	conflicts, err := app.Models.Roster.Conflicts(given, swap.PeerID, minRest, exclude...)
+	if err != nil || taken == nil {
+		return conflicts, err
+	}
+
+	more, err := app.Models.Roster.Conflicts(taken, swap.RequesterID, minRest, exclude...)
+	return append(conflicts, more...), err
+}
+
+// upcomingRosterEntry returns the roster entry if it belongs to the employee and
+// has not started yet, or nil otherwise
+func (app *Application) upcomingRosterEntry(id, employeeID int64) (*data.RosterEntry, error) {
+	entry, err := app.Models.Roster.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if entry.EmployeeID != employeeID || !entry.StartsAt.After(time.Now()) {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// readRosterRange reads the from and to dates of the query string, defaulting
+// to the month of now, writing the error response itself when they are invalid
+func (app *Application) readRosterRange(w http.ResponseWriter, r *http.Request, now time.Time) (string, string, bool) {
+	qs := r.URL.Query()
+	from, to := qs.Get("from"), qs.Get("to")
+	if from == "" && to == "" {
+		from, to = monthRange(now)
+	}
+
+	v := validator.New()
+	start, err := time.Parse("2006-01-02", from)
+	v.Check(err == nil, "from", "must be a valid date (YYYY-MM-DD)")
+	end, err := time.Parse("2006-01-02", to)
+	v.Check(err == nil, "to", "must be a valid date (YYYY-MM-DD)")
+	if v.Valid() {
+		v.Check(!end.Before(start), "to", "must not be before from")
+		v.Check(end.Sub(start) < maxRosterDays*24*time.Hour, "to",
+			fmt.Sprintf("must not be more than %d days after from", maxRosterDays-1))
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return "", "", false
+	}
+
+	return from, to, true
+}
+
+// fetchShiftSwap loads the shift swap identified by the "id" URL parameter,
+// writing the error response itself when it cannot
+func (app *Application) fetchShiftSwap(w http.ResponseWriter, r *http.Request) (*data.ShiftSwap, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	swap, err := app.Models.ShiftSwaps.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return swap, true
+}
+
+// rosterConflictResponse reports the conflicts which prevent a roster change
+func (app *Application) rosterConflictResponse(w http.ResponseWriter, r *http.Request, conflicts []*data.RosterConflict) {
+	app.errorResponse(w, r, http.StatusConflict, envelope{
+		"message":   "the roster change conflicts with other shifts",
+		"conflicts": conflicts,
+	})
+}
@@ -11,17 +11,221 @@ func (app *Application) Routes() *httprouter.Router {
 
 	// Public routes
 	router.HandlerFunc(http.MethodGet, "/v1/health", app.healthHandler)
+	router.HandlerFunc(http.MethodGet, "/.well-known/jwks.json", app.jwksHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/auth/login", app.loginHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/auth/login/2fa", app.twoFactorLoginHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/auth/oidc/login", app.oidcLoginHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/auth/oidc/callback", app.oidcCallbackHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/auth/refresh", app.refreshHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/auth/password-reset", app.createPasswordResetTokenHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/auth/password", app.resetPasswordHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/auth/activate", app.activateAccountHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/calendar/:file", app.calendarFeedHandler)
 
 	// Protected routes (Employee Only)
 	router.Handler(http.MethodPost, "/v1/attendance/checkin",
 		app.authenticate(app.requireEmployee(http.HandlerFunc(app.checkInHandler))))
 	router.Handler(http.MethodPost, "/v1/attendance/checkout",
 		app.authenticate(app.requireEmployee(http.HandlerFunc(app.checkOutHandler))))
+	router.Handler(http.MethodPost, "/v1/attendance/punches",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.punchHandler))))
+	router.Handler(http.MethodGet, "/v1/attendance",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.showAttendanceHandler))))
+	router.Handler(http.MethodPost, "/v1/attendance/sync",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.syncAttendanceHandler))))
+	router.Handler(http.MethodGet, "/v1/attendance/summary",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.attendanceSummaryHandler))))
+	router.Handler(http.MethodGet, "/v1/timesheets",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.listTimesheetsHandler))))
+	router.Handler(http.MethodPut, "/v1/timesheets/:date",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.updateTimesheetHandler))))
+
+	router.Handler(http.MethodGet, "/v1/roster",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.listMyRosterHandler))))
+	router.Handler(http.MethodPost, "/v1/roster/swaps",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.createShiftSwapHandler))))
+	router.Handler(http.MethodGet, "/v1/roster/swaps",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.listMyShiftSwapsHandler))))
+	router.Handler(http.MethodPut, "/v1/roster/swaps/:id/accept",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.acceptShiftSwapHandler))))
+	router.Handler(http.MethodPut, "/v1/roster/swaps/:id/decline",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.declineShiftSwapHandler))))
+	router.Handler(http.MethodPut, "/v1/roster/swaps/:id/cancel",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.cancelShiftSwapHandler))))
+
+	router.Handler(http.MethodGet, "/v1/leave/balances",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.listLeaveBalancesHandler))))
+	router.Handler(http.MethodPost, "/v1/leave/requests",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.createLeaveRequestHandler))))
+	router.Handler(http.MethodGet, "/v1/leave/requests",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.listMyLeaveRequestsHandler))))
+	router.Handler(http.MethodPut, "/v1/leave/requests/:id/cancel",
+		app.authenticate(app.requireEmployee(http.HandlerFunc(app.cancelLeaveRequestHandler))))
+
+	// Protected routes (Kiosk devices)
+	router.Handler(http.MethodGet, "/v1/kiosk/qr",
+		app.authenticateKiosk(http.HandlerFunc(app.kioskQRHandler)))
+
+	// Protected routes (Any authenticated user)
+	router.Handler(http.MethodGet, "/v1/leave/types",
+		app.authenticate(http.HandlerFunc(app.listLeaveTypesHandler)))
+	router.Handler(http.MethodGet, "/v1/leave/requests/:id/attachment",
+		app.authenticate(http.HandlerFunc(app.showLeaveAttachmentHandler)))
+	router.Handler(http.MethodGet, "/v1/holidays",
+		app.authenticate(http.HandlerFunc(app.listHolidaysHandler)))
+	router.Handler(http.MethodGet, "/v1/attendance/types",
+		app.authenticate(http.HandlerFunc(app.listAttendanceTypesHandler)))
+	router.Handler(http.MethodGet, "/v1/projects",
+		app.authenticate(http.HandlerFunc(app.listActiveProjectsHandler)))
+	router.Handler(http.MethodGet, "/v1/notifications",
+		app.authenticate(http.HandlerFunc(app.listNotificationsHandler)))
+	router.Handler(http.MethodPut, "/v1/notifications/:id/read",
+		app.authenticate(http.HandlerFunc(app.readNotificationHandler)))
+	router.Handler(http.MethodPost, "/v1/auth/logout",
+		app.authenticate(http.HandlerFunc(app.logoutHandler)))
+	router.Handler(http.MethodGet, "/v1/me",
+		app.authenticate(http.HandlerFunc(app.showMeHandler)))
+	router.Handler(http.MethodPatch, "/v1/me",
+		app.authenticate(http.HandlerFunc(app.updateMeHandler)))
+	router.Handler(http.MethodGet, "/v1/me/2fa",
+		app.authenticate(http.HandlerFunc(app.showTwoFactorHandler)))
+	router.Handler(http.MethodPost, "/v1/me/2fa/enrol",
+		app.authenticate(http.HandlerFunc(app.enrolTwoFactorHandler)))
+	router.Handler(http.MethodPost, "/v1/me/2fa/confirm",
+		app.authenticate(http.HandlerFunc(app.confirmTwoFactorHandler)))
+	router.Handler(http.MethodPost, "/v1/me/2fa/recovery-codes",
+		app.authenticate(http.HandlerFunc(app.regenerateRecoveryCodesHandler)))
+	router.Handler(http.MethodDelete, "/v1/me/2fa",
+		app.authenticate(http.HandlerFunc(app.disableTwoFactorHandler)))
+	router.Handler(http.MethodGet, "/v1/me/sessions",
+		app.authenticate(http.HandlerFunc(app.listMySessionsHandler)))
+	router.Handler(http.MethodDelete, "/v1/me/sessions/:id",
+		app.authenticate(http.HandlerFunc(app.revokeMySessionHandler)))
+	router.Handler(http.MethodPut, "/v1/me/password",
+		app.authenticate(http.HandlerFunc(app.changePasswordHandler)))
+	router.Handler(http.MethodGet, "/v1/me/profile-changes",
+		app.authenticate(http.HandlerFunc(app.listMyProfileChangesHandler)))
+	router.Handler(http.MethodPut, "/v1/me/profile-changes/:id/cancel",
+		app.authenticate(http.HandlerFunc(app.cancelProfileChangeHandler)))
+	router.Handler(http.MethodGet, "/v1/calendar-feed",
+		app.authenticate(http.HandlerFunc(app.showCalendarFeedHandler)))
+	router.Handler(http.MethodPost, "/v1/calendar-feed",
+		app.authenticate(http.HandlerFunc(app.rotateCalendarFeedHandler)))
+	router.Handler(http.MethodDelete, "/v1/calendar-feed",
+		app.authenticate(http.HandlerFunc(app.revokeCalendarFeedHandler)))
 
 	// Protected routes (Admin Only)
 	router.Handler(http.MethodPost, "/v1/payroll/period",
 		app.authenticate(app.requireAdmin(http.HandlerFunc(app.createPayrollPeriodHandler))))
+	router.Handler(http.MethodGet, "/v1/payroll/period/:id/inputs",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.showPayrollInputsHandler))))
+	router.Handler(http.MethodPut, "/v1/payroll/period/:id/pay-date",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.setPayrollPayDateHandler))))
+	router.Handler(http.MethodPut, "/v1/payroll/period/:id/process",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.processPayrollPeriodHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/leave/requests",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.listLeaveRequestsHandler))))
+	router.Handler(http.MethodPut, "/v1/admin/leave/requests/:id/approve",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.approveLeaveRequestHandler))))
+	router.Handler(http.MethodPut, "/v1/admin/leave/requests/:id/reject",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.rejectLeaveRequestHandler))))
+
+	router.Handler(http.MethodPost, "/v1/admin/offices",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.createOfficeHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/offices",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.listOfficesHandler))))
+	router.Handler(http.MethodPatch, "/v1/admin/offices/:id",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.updateOfficeHandler))))
+	router.Handler(http.MethodPost, "/v1/admin/users",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.createUserHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/users",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.listUsersHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/users/:id",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.showUserHandler))))
+	router.Handler(http.MethodPatch, "/v1/admin/users/:id",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.updateUserHandler))))
+	router.Handler(http.MethodPut, "/v1/admin/users/:id/deactivate",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.deactivateUserHandler))))
+	router.Handler(http.MethodPut, "/v1/admin/users/:id/activate",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.activateUserHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/login-lockouts",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.listLockoutsHandler))))
+	router.Handler(http.MethodDelete, "/v1/admin/login-lockouts/:id",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.clearLockoutHandler))))
+	router.Handler(http.MethodDelete, "/v1/admin/users/:id/sessions",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.revokeUserSessionsHandler))))
+	router.Handler(http.MethodPost, "/v1/admin/users/:id/invitation",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.resendInvitationHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/profile-changes",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.listProfileChangesHandler))))
+	router.Handler(http.MethodPut, "/v1/admin/profile-changes/:id/approve",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.approveProfileChangeHandler))))
+	router.Handler(http.MethodPut, "/v1/admin/profile-changes/:id/reject",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.rejectProfileChangeHandler))))
+	router.Handler(http.MethodPut, "/v1/admin/users/:id/office",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.assignUserOfficeHandler))))
+	router.Handler(http.MethodPut, "/v1/admin/users/:id/remote",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.setUserRemoteAllowedHandler))))
+	router.Handler(http.MethodPut, "/v1/admin/users/:id/wfh-quota",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.setUserWFHQuotaHandler))))
+	router.Handler(http.MethodPatch, "/v1/admin/attendance/types/:code",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.updateAttendanceTypeHandler))))
+	router.Handler(http.MethodPost, "/v1/admin/holidays",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.createHolidayHandler))))
+	router.Handler(http.MethodDelete, "/v1/admin/holidays/:id",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.deleteHolidayHandler))))
+
+	router.Handler(http.MethodPost, "/v1/admin/kiosks",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.createKioskHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/kiosks",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.listKiosksHandler))))
+	router.Handler(http.MethodDelete, "/v1/admin/kiosks/:id",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.revokeKioskHandler))))
+
+	router.Handler(http.MethodGet, "/v1/admin/attendance/anomalies",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.attendanceAnomaliesHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/attendance/live",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.liveAttendanceHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/attendance/export",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.exportAttendanceHandler))))
+	router.Handler(http.MethodPost, "/v1/admin/attendance/import",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.importAttendanceHandler))))
+	router.Handler(http.MethodPost, "/v1/admin/attendance/device-users",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.createDeviceUserMappingHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/attendance/device-users",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.listDeviceUserMappingsHandler))))
+	router.Handler(http.MethodDelete, "/v1/admin/attendance/device-users/:id",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.deleteDeviceUserMappingHandler))))
+
+	router.Handler(http.MethodPost, "/v1/admin/cost-centers",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.createCostCenterHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/cost-centers",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.listCostCentersHandler))))
+	router.Handler(http.MethodPost, "/v1/admin/projects",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.createProjectHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/projects",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.listProjectsHandler))))
+	router.Handler(http.MethodPatch, "/v1/admin/projects/:id",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.updateProjectHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/reports/project-costs",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.projectCostsHandler))))
+
+	router.Handler(http.MethodPost, "/v1/admin/shifts",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.createShiftHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/shifts",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.listShiftsHandler))))
+	router.Handler(http.MethodPost, "/v1/admin/roster",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.createRosterEntryHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/roster",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.listRosterHandler))))
+	router.Handler(http.MethodDelete, "/v1/admin/roster/:id",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.deleteRosterEntryHandler))))
+	router.Handler(http.MethodGet, "/v1/admin/roster/swaps",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.listShiftSwapsHandler))))
+	router.Handler(http.MethodPut, "/v1/admin/roster/swaps/:id/approve",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.approveShiftSwapHandler))))
+	router.Handler(http.MethodPut, "/v1/admin/roster/swaps/:id/reject",
+		app.authenticate(app.requireAdmin(http.HandlerFunc(app.rejectShiftSwapHandler))))
 
 	return router
 }
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+)
+
+// listMySessionsHandler lists the sessions the authenticated user is logged in
+// with, flagging the one of the request
+func (app *Application) listMySessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	token := app.contextGetAccessToken(r)
+
+	sessions, err := app.Models.Sessions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == token.SessionID
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"sessions": sessions}, nil)
+}
+
+// revokeMySessionHandler lets users log out one of their sessions, e.g. on a
+// lost device. Revoking the session of the request logs it out.
+func (app *Application) revokeMySessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.Models.Sessions.RevokeForUser(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"message": "session logged out successfully"}, nil)
+}
+
+// revokeUserSessionsHandler lets admins log a user out of every session, e.g.
+// after their employment was terminated
+func (app *Application) revokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.fetchUser(w, r)
+	if !ok {
+		return
+	}
+
+	admin := app.contextGetUser(r)
+	if !canManageUser(admin, user) {
+		app.errorResponse(w, r, http.StatusForbidden, "you must be a super admin to manage admins")
+		return
+	}
+
+	err := app.Models.Sessions.RevokeAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"message": "user logged out of every session successfully"}, nil)
+}
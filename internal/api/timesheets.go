@@ -0,0 +1,176 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+const (
+	// Longest period of timesheets which can be listed or reported at once
+	maxTimesheetDays = 366
+	// Most projects one attendance day can be split across
+	maxTimesheetEntries = 20
+)
+
+// updateTimesheetHandler lets employees split the hours they worked on an
+// attendance day across projects, replacing the previous allocation of the day.
+// The allocated hours may not exceed the hours worked between check-in and
+// check-out; sending no entries clears the day.
+func (app *Application) updateTimesheetHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Entries []struct {
+			ProjectID int64   `json:"project_id"`
+			Hours     float64 `json:"hours"`
+			Note      *string `json:"note"`
+		} `json:"entries"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	date := httprouter.ParamsFromContext(r.Context()).ByName("date")
+
+	v := validator.New()
+	_, err = time.Parse("2006-01-02", date)
+	v.Check(err == nil, "date", "must be a valid date (YYYY-MM-DD)")
+	v.Check(len(input.Entries) <= maxTimesheetEntries, "entries",
+		fmt.Sprintf("must not contain more than %d entries", maxTimesheetEntries))
+
+	seen := map[int64]bool{}
+	for i, e := range input.Entries {
+		key := fmt.Sprintf("entries[%d]", i)
+		v.Check(!seen[e.ProjectID], key+".project_id", "must not be repeated")
+		v.Check(e.Hours > 0 && e.Hours <= 24, key+".hours", "must be more than 0 and at most 24")
+		v.Check(math.Round(e.Hours*60) >= 1, key+".hours", "must be at least one minute")
+		if e.Note != nil {
+			v.Check(len(*e.Note) <= 1000, key+".note", "must not be more than 1000 bytes long")
+		}
+		seen[e.ProjectID] = true
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Hours can only be allocated to active projects
+	for i, e := range input.Entries {
+		project, err := app.Models.Projects.Get(e.ProjectID)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if project == nil || !project.Active {
+			v.AddError(fmt.Sprintf("entries[%d].project_id", i), "must be an active project")
+		}
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	locked, err := app.Models.PayrollPeriod.IsLocked(date)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if locked {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity,
+			fmt.Sprintf("cannot change the timesheet of %s, the payroll period has already been processed", date))
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	att, err := app.Models.Attendance.Get(user.ID, date)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusNotFound, "no attendance for the date")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	entries := make([]*data.TimesheetEntry, len(input.Entries))
+	for i, e := range input.Entries {
+		entries[i] = &data.TimesheetEntry{
+			ProjectID: e.ProjectID,
+			Minutes:   int(math.Round(e.Hours * 60)),
+			Note:      e.Note,
+			CreatedBy: user.ID,
+		}
+	}
+
+	timesheet, err := app.Models.Timesheets.Replace(att.ID, entries)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusNotFound, "no attendance for the date")
+		case errors.Is(err, data.ErrNotCheckedOut):
+			app.errorResponse(w, r, http.StatusConflict, "check out before allocating the hours of the day")
+		case errors.Is(err, data.ErrTimesheetOverAllocated):
+			app.failedValidationResponse(w, r, map[string]string{
+				"entries": fmt.Sprintf("must not allocate more than the %.2f hours worked", workedHours(att.WorkedMinutes)),
+			})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":   "timesheet updated successfully",
+		"timesheet": timesheet,
+	}, nil)
+}
+
+// listTimesheetsHandler lists the timesheets of the employee's attendance days
+// between two dates (the current month by default)
+func (app *Application) listTimesheetsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	qs := r.URL.Query()
+	from, to := qs.Get("from"), qs.Get("to")
+	if from == "" && to == "" {
+		now, err := app.employeeNow(user)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		from, to = monthRange(now)
+	}
+
+	v := validator.New()
+	start, err := time.Parse("2006-01-02", from)
+	v.Check(err == nil, "from", "must be a valid date (YYYY-MM-DD)")
+	end, err := time.Parse("2006-01-02", to)
+	v.Check(err == nil, "to", "must be a valid date (YYYY-MM-DD)")
+	if v.Valid() {
+		v.Check(!end.Before(start), "to", "must not be before from")
+		v.Check(end.Sub(start) < maxTimesheetDays*24*time.Hour, "to",
+			fmt.Sprintf("must not be more than %d days after from", maxTimesheetDays-1))
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	timesheets, err := app.Models.Timesheets.GetAll(user.ID, from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"timesheets": timesheets}, nil)
+}
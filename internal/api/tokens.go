@@ -1,25 +1,108 @@
 package api
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// createToken creates JWT tokens for user login
-func (app *Application) createToken(userId int64) (string, error) {
+// accessToken struct holds the claims of the access token a request was
+// authenticated with
+type accessToken struct {
+	ID        string
+	SessionID int64
+	Expiry    time.Time
+}
+
+// createAccessToken creates a short-lived JWT access token for the session of
+// the user, signed with the current signing key. Its jti claim identifies it on
+// the revocation list.
+func (app *Application) createAccessToken(userID, sessionID int64) (string, time.Time, error) {
+	jti := make([]byte, 16)
+	_, err := rand.Read(jti)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiry := now.Add(app.Config.Jwt.AccessTTL)
+
 	claims := jwt.MapClaims{
-		"sub": userId,
-		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
+		"iss": app.Config.Jwt.Issuer,
+		"sub": userID,
+		"sid": sessionID,
+		"jti": hex.EncodeToString(jti),
+		"iat": now.Unix(),
+		"exp": expiry.Unix(),
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		claims)
+	tokenString, err := app.Keys.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiry, nil
+}
+
+// Kiosk QR tokens rotate every kioskQRInterval. A token is still accepted during
+// the following interval, to allow for the time between scanning and submitting.
+const kioskQRInterval = 30 * time.Second
+
+var (
+	errInvalidKioskQRToken = errors.New("invalid kiosk QR code")
+	errExpiredKioskQRToken = errors.New("kiosk QR code has expired, please scan it again")
+)
 
-	tokenString, err := token.SignedString([]byte(app.Config.Jwt.Secret))
+// kioskQRSignature signs the kiosk ID and time window of a QR token
+func (app *Application) kioskQRSignature(kioskID, window int64) string {
+	mac := hmac.New(sha256.New, []byte(app.Config.Kiosk.Secret))
+	fmt.Fprintf(mac, "kiosk-qr:%d:%d", kioskID, window)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// createKioskQRToken creates the signed token a kiosk displays as a QR code for
+// the current time window, and returns when it stops being displayed
+func (app *Application) createKioskQRToken(kioskID int64, now time.Time) (string, time.Time) {
+	window := now.Unix() / int64(kioskQRInterval.Seconds())
+	token := fmt.Sprintf("%d.%d.%s", kioskID, window, app.kioskQRSignature(kioskID, window))
+	rotatesAt := time.Unix((window+1)*int64(kioskQRInterval.Seconds()), 0)
+	return token, rotatesAt
+}
+
+// verifyKioskQRToken checks the signature and freshness of a scanned kiosk QR
+// token and returns the ID of the kiosk which displayed it
+func (app *Application) verifyKioskQRToken(token string, now time.Time) (int64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, errInvalidKioskQRToken
+	}
+
+	kioskID, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		return "Unable to sign token", err
+		return 0, errInvalidKioskQRToken
+	}
+	window, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, errInvalidKioskQRToken
+	}
+
+	expected := app.kioskQRSignature(kioskID, window)
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return 0, errInvalidKioskQRToken
+	}
+
+	current := now.Unix() / int64(kioskQRInterval.Seconds())
+	if window != current && window != current-1 {
+		return 0, errExpiredKioskQRToken
 	}
 
-	return tokenString, nil
+	return kioskID, nil
 }
@@ -0,0 +1,506 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/totp"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+const (
+	// How long users have to enter their code after their password
+	twoFactorChallengeTTL = 5 * time.Minute
+	// Codes of this many steps before or after the current one are accepted,
+	// to allow for the clock of the user's phone being off
+	totpSkew = 1
+	// How recently users must have logged in to manage their two-factor
+	// authentication without entering their password
+	reauthWindow = 10 * time.Minute
+)
+
+// twoFactorRequired reports whether the policy requires the user to use
+// two-factor authentication
+func (app *Application) twoFactorRequired(user *data.User) bool {
+	return app.Config.TwoFactor.RequireForAdmins && user.Role != "employee"
+}
+
+// verifyTOTP checks a code from the user's authenticator app. A code is only
+// accepted once.
+func (app *Application) verifyTOTP(user *data.User, code string) (bool, error) {
+	t, err := app.Models.TwoFactor.Get(user.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	secret, err := app.TOTPSecrets.Open(t.Secret, totpAdditionalData(user.ID))
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := totp.Validate(string(secret), code, time.Now(), totpSkew)
+	if !ok {
+		return false, nil
+	}
+
+	return app.Models.TwoFactor.UseStep(user.ID, step)
+}
+
+// totpAdditionalData binds an encrypted TOTP secret to its user, so that it
+// cannot be copied to another user's row
+func totpAdditionalData(userID int64) []byte {
+	return []byte(strconv.FormatInt(userID, 10))
+}
+
+// reauthenticate checks that the user proved again who they are before
+// changing their two-factor authentication: with their password, or else by
+// having logged in within the reauthWindow. Users without a password, who log
+// in with single sign-on, can only do the latter. A wrong password counts as a
+// failed login.
+func (app *Application) reauthenticate(r *http.Request, v *validator.Validator, user *data.User, password string) error {
+	if password != "" {
+		match, err := user.Password.Matches(password)
+		if err != nil {
+			return err
+		}
+		if !match {
+			v.AddError("password", "is incorrect")
+			return app.recordFailedTwoFactorCheck(r, user)
+		}
+		return nil
+	}
+
+	session, err := app.Models.Sessions.Get(app.contextGetAccessToken(r).SessionID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		return err
+	}
+	if session == nil || time.Since(session.CreatedAt) > reauthWindow {
+		v.AddError("password", fmt.Sprintf("must be provided, or log in again as it has been over %d minutes", int(reauthWindow.Minutes())))
+	}
+
+	return nil
+}
+
+// twoFactorLockedOut sends the locked out response and reports true if the
+// authenticated user's account or their client is locked out of logging in, as
+// a stolen access token must not allow guessing their password or codes either
+func (app *Application) twoFactorLockedOut(w http.ResponseWriter, r *http.Request, user *data.User) bool {
+	lockedUntil, err := app.Models.Lockouts.LockedUntil(strings.ToLower(user.Email), app.clientIP(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return true
+	}
+	if lockedUntil != nil {
+		app.lockedOutResponse(w, r, *lockedUntil)
+		return true
+	}
+
+	return false
+}
+
+// recordFailedTwoFactorCheck counts a wrong password or code sent by the
+// authenticated user as a failed login
+func (app *Application) recordFailedTwoFactorCheck(r *http.Request, user *data.User) error {
+	return app.recordFailedLogin(strings.ToLower(user.Email), app.clientIP(r))
+}
+
+// twoFactorChallenge sends the challenge token a user who entered their
+// password exchanges, along with a code, for their tokens
+func (app *Application) twoFactorChallenge(w http.ResponseWriter, r *http.Request, user *data.User) {
+	token, err := app.Models.Tokens.New(user.ID, twoFactorChallengeTTL, data.ScopeTwoFactor)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":             "enter the code from your authenticator app, or a recovery code, to finish logging in",
+		"two_factor_required": true,
+		"challenge_token":     token,
+	}, nil)
+}
+
+// twoFactorLoginHandler finishes the login of a user with two-factor
+// authentication, given the challenge token from loginHandler and a code from
+// their authenticator app or one of their recovery codes. Wrong codes count as
+// failed logins.
+func (app *Application) twoFactorLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+		Device         string `json:"device"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.ChallengeToken != "", "challenge_token", "must be provided")
+	v.Check(input.Code != "" || input.RecoveryCode != "", "code", "must be provided, or a recovery_code")
+	v.Check(input.Code == "" || input.RecoveryCode == "", "recovery_code", "must not be provided along with a code")
+	v.Check(len(input.Device) <= 100, "device", "must not be more than 100 bytes long")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	userID, err := app.Models.Tokens.GetUserID(data.ScopeTwoFactor, input.ChallengeToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusUnauthorized, "invalid or expired challenge token, please log in again")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.Models.Users.Get(userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Refuse to check codes while the account or client is locked out
+	account := strings.ToLower(user.Email)
+	ip := app.clientIP(r)
+
+	lockedUntil, err := app.Models.Lockouts.LockedUntil(account, ip)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if lockedUntil != nil {
+		app.lockedOutResponse(w, r, *lockedUntil)
+		return
+	}
+
+	var ok bool
+	if input.Code != "" {
+		ok, err = app.verifyTOTP(user, input.Code)
+	} else {
+		ok, err = app.Models.TwoFactor.UseRecoveryCode(user.ID, input.RecoveryCode)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !ok {
+		err = app.recordFailedLogin(account, ip)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.errorResponse(w, r, http.StatusUnauthorized, "invalid two-factor authentication code")
+		return
+	}
+
+	// The challenge can only be used once, even by concurrent requests
+	_, err = app.Models.Tokens.Consume(data.ScopeTwoFactor, input.ChallengeToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusUnauthorized, "invalid or expired challenge token, please log in again")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.Models.Lockouts.Clear(data.LockoutAccount, account)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !user.Active {
+		app.errorResponse(w, r, http.StatusUnauthorized, "your account has been deactivated")
+		return
+	}
+
+	app.startSession(w, r, user, input.Device)
+}
+
+// showTwoFactorHandler returns whether the authenticated user has two-factor
+// authentication enabled, and whether they must
+func (app *Application) showTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	left := 0
+	if user.TwoFactor {
+		var err error
+		left, err = app.Models.TwoFactor.RecoveryCodesLeft(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"two_factor": envelope{
+		"enabled":             user.TwoFactor,
+		"required":            app.twoFactorRequired(user),
+		"recovery_codes_left": left,
+	}}, nil)
+}
+
+// enrolTwoFactorHandler starts enabling two-factor authentication for the
+// authenticated user, which requires their password or a recent login. It
+// returns the secret and its provisioning URI, which the client shows as a QR
+// code to scan with an authenticator app.
+func (app *Application) enrolTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if app.twoFactorLockedOut(w, r, user) {
+		return
+	}
+
+	v := validator.New()
+	err = app.reauthenticate(r, v, user, input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	encrypted, err := app.TOTPSecrets.Seal([]byte(secret), totpAdditionalData(user.ID))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.Models.TwoFactor.Enrol(user.ID, encrypted)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTwoFactorEnabled):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":          "scan the QR code with your authenticator app, then confirm a code to enable two-factor authentication",
+		"secret":           secret,
+		"provisioning_uri": totp.URI(app.Config.TwoFactor.Issuer, user.Email, secret),
+	}, nil)
+}
+
+// confirmTwoFactorHandler enables two-factor authentication for the
+// authenticated user once they entered a code from their authenticator app,
+// and returns their recovery codes
+func (app *Application) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Code != "", "code", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if user.TwoFactor {
+		app.errorResponse(w, r, http.StatusConflict, data.ErrTwoFactorEnabled.Error())
+		return
+	}
+	if app.twoFactorLockedOut(w, r, user) {
+		return
+	}
+
+	ok, err := app.verifyTOTP(user, input.Code)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !ok {
+		err = app.recordFailedTwoFactorCheck(r, user)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		v.AddError("code", "is invalid, or you have not started enrolling (POST /v1/me/2fa/enrol)")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	codes, err := app.Models.TwoFactor.Enable(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTwoFactorEnabled):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":        "two-factor authentication enabled successfully, store your recovery codes somewhere safe as they are only shown once",
+		"recovery_codes": codes,
+	}, nil)
+}
+
+// regenerateRecoveryCodesHandler replaces the recovery codes of the
+// authenticated user, given a code from their authenticator app
+func (app *Application) regenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Code != "", "code", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if !user.TwoFactor {
+		app.errorResponse(w, r, http.StatusConflict, "two-factor authentication is not enabled")
+		return
+	}
+	if app.twoFactorLockedOut(w, r, user) {
+		return
+	}
+
+	ok, err := app.verifyTOTP(user, input.Code)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !ok {
+		err = app.recordFailedTwoFactorCheck(r, user)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		v.AddError("code", "is invalid")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	codes, err := app.Models.TwoFactor.ReplaceRecoveryCodes(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message":        "recovery codes replaced successfully, the previous ones no longer work",
+		"recovery_codes": codes,
+	}, nil)
+}
+
+// disableTwoFactorHandler turns off two-factor authentication for the
+// authenticated user, given a code from their authenticator app and their
+// password or a recent login, unless the policy requires it of them
+func (app *Application) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Code != "", "code", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if !user.TwoFactor {
+		app.errorResponse(w, r, http.StatusConflict, "two-factor authentication is not enabled")
+		return
+	}
+	if app.twoFactorRequired(user) {
+		app.errorResponse(w, r, http.StatusForbidden, "admins must use two-factor authentication")
+		return
+	}
+	if app.twoFactorLockedOut(w, r, user) {
+		return
+	}
+
+	err = app.reauthenticate(r, v, user, input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	ok, err := app.verifyTOTP(user, input.Code)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !ok {
+		err = app.recordFailedTwoFactorCheck(r, user)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		v.AddError("code", "is invalid")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.Models.TwoFactor.Disable(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"message": "two-factor authentication disabled successfully"}, nil)
+}
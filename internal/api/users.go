@@ -0,0 +1,305 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/validator"
+)
+
+// canManageUser reports whether the admin may change the target user. Admins
+// manage employees, while other admins and super admins can only be managed by
+// a super admin.
+func canManageUser(admin, target *data.User) bool {
+	return target.Role == "employee" || admin.Role == "super_admin"
+}
+
+// createUserHandler lets admins create an employee, or an admin or super admin
+// if they are a super admin. The user is pending until they choose their
+// password with the invitation emailed to them.
+func (app *Application) createUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name          string `json:"name"`
+		Email         string `json:"email"`
+		Role          string `json:"role"`
+		Salary        int64  `json:"salary"`
+		OfficeID      *int64 `json:"office_id"`
+		RemoteAllowed bool   `json:"remote_allowed"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Role == "" {
+		input.Role = "employee"
+	}
+
+	v := validator.New()
+	validator.ValidateUser(v, input.Name, input.Email, input.Role, input.Salary)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	admin := app.contextGetUser(r)
+
+	user := &data.User{
+		Role:          input.Role,
+		Name:          input.Name,
+		Email:         input.Email,
+		Salary:        input.Salary,
+		OfficeID:      input.OfficeID,
+		RemoteAllowed: input.RemoteAllowed,
+		CreatedBy:     admin.ID,
+		UpdatedBy:     admin.ID,
+	}
+
+	if !canManageUser(admin, user) {
+		app.errorResponse(w, r, http.StatusForbidden, "you must be a super admin to manage admins")
+		return
+	}
+
+	if input.OfficeID != nil {
+		_, err = app.Models.Offices.Get(*input.OfficeID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.failedValidationResponse(w, r, map[string]string{"office_id": "must be an existing office"})
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	err = app.Models.Users.Insert(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			app.failedValidationResponse(w, r, map[string]string{"email": "a user with this email address already exists"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	token, err := app.sendInvitation(user, admin)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{
+		"message":           "user created successfully, an invitation to activate their account was emailed to them",
+		"user":              user,
+		"invitation_expiry": token.Expiry,
+	}, nil)
+}
+
+// listUsersHandler lists users, filtered by role, name, email and whether they
+// are active, one page at a time
+func (app *Application) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	filter := data.UserFilter{
+		Role:   app.readString(qs, "role", ""),
+		Name:   app.readString(qs, "name", ""),
+		Email:  app.readString(qs, "email", ""),
+		Active: app.readBool(qs, "active", v),
+	}
+
+	filters := data.Filters{
+		Page:     app.readInt(qs, "page", 1, v),
+		PageSize: app.readInt(qs, "page_size", 20, v),
+		Sort:     app.readString(qs, "sort", "id"),
+		SortSafelist: []string{
+			"id", "name", "email", "role", "created_at",
+			"-id", "-name", "-email", "-role", "-created_at",
+		},
+	}
+
+	if filter.Role != "" {
+		v.Check(validator.In(filter.Role, "employee", "admin", "super_admin"), "role",
+			"must be employee, admin or super_admin")
+	}
+	data.ValidateFilters(v, filters)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	users, metadata, err := app.Models.Users.GetAll(filter, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"users":    users,
+		"metadata": metadata,
+	}, nil)
+}
+
+// showUserHandler returns a user
+func (app *Application) showUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.fetchUser(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+}
+
+// updateUserHandler lets admins change the name, email, password, role and
+// salary of a user. Only the fields sent are changed.
+func (app *Application) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name     *string `json:"name"`
+		Email    *string `json:"email"`
+		Password *string `json:"password"`
+		Role     *string `json:"role"`
+		Salary   *int64  `json:"salary"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, ok := app.fetchUser(w, r)
+	if !ok {
+		return
+	}
+
+	admin := app.contextGetUser(r)
+	if !canManageUser(admin, user) {
+		app.errorResponse(w, r, http.StatusForbidden, "you must be a super admin to manage admins")
+		return
+	}
+
+	if input.Name != nil {
+		user.Name = *input.Name
+	}
+	if input.Email != nil {
+		user.Email = *input.Email
+	}
+	if input.Role != nil {
+		user.Role = *input.Role
+	}
+	if input.Salary != nil {
+		user.Salary = *input.Salary
+	}
+
+	v := validator.New()
+	validator.ValidateUser(v, user.Name, user.Email, user.Role, user.Salary)
+	if input.Password != nil {
+		validator.ValidatePasswordPlaintext(v, *input.Password)
+		v.Check(user.Activated, "password", "cannot be set before the user activates their account")
+	}
+	if user.ID == admin.ID && input.Role != nil && *input.Role != admin.Role {
+		v.AddError("role", "cannot change your own role")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Promoting an employee makes them an admin, and only super admins may
+	// grant the admin and super admin roles
+	if !canManageUser(admin, user) {
+		app.errorResponse(w, r, http.StatusForbidden, "you must be a super admin to manage admins")
+		return
+	}
+
+	if input.Password != nil {
+		err = user.Password.Set(*input.Password)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	user.UpdatedBy = admin.ID
+
+	err = app.Models.Users.Update(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			app.failedValidationResponse(w, r, map[string]string{"email": "a user with this email address already exists"})
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message": "user updated successfully",
+		"user":    user,
+	}, nil)
+}
+
+// deactivateUserHandler lets admins deactivate a user who left, so they can no
+// longer log in. Their records are kept.
+func (app *Application) deactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.setUserActive(w, r, false)
+}
+
+// activateUserHandler lets admins reactivate a deactivated user
+func (app *Application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.setUserActive(w, r, true)
+}
+
+func (app *Application) setUserActive(w http.ResponseWriter, r *http.Request, active bool) {
+	user, ok := app.fetchUser(w, r)
+	if !ok {
+		return
+	}
+
+	admin := app.contextGetUser(r)
+	if !canManageUser(admin, user) {
+		app.errorResponse(w, r, http.StatusForbidden, "you must be a super admin to manage admins")
+		return
+	}
+	if user.ID == admin.ID {
+		app.errorResponse(w, r, http.StatusConflict, "cannot deactivate or reactivate yourself")
+		return
+	}
+
+	err := app.Models.Users.SetActive(user, active, admin.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// A user who left must not stay logged in
+	if !active {
+		err = app.Models.Sessions.RevokeAllForUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	message := "user deactivated successfully"
+	if active {
+		message = "user activated successfully"
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"message": message,
+		"user":    user,
+	}, nil)
+}
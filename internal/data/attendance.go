@@ -3,29 +3,198 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 
 	"github.com/lib/pq"
 )
 
-// Error for duplicate emails
+// Attendance punch kinds
+const (
+	PunchIn         = "in"
+	PunchOut        = "out"
+	PunchBreakStart = "break_start"
+	PunchBreakEnd   = "break_end"
+)
+
+// Errors for punches which do not follow the previous punch of the day
 var (
-	ErrDuplicateCheckIn  = errors.New("employee have already checked in on the date")
-	ErrDuplicateCheckOut = errors.New("employee have already checked out on the date")
+	ErrDuplicateCheckIn  = errors.New("employee is already checked in")
+	ErrDuplicateCheckOut = errors.New("employee has already checked out")
+	ErrNotCheckedIn      = errors.New("employee is not checked in")
+	ErrAlreadyOnBreak    = errors.New("employee is already on a break")
+	ErrNotOnBreak        = errors.New("employee is not on a break")
+	ErrPunchOutOfOrder   = errors.New("punch cannot be earlier than the previous punch of the day")
+	ErrDuplicatePunch    = errors.New("punch has already been recorded")
 )
 
-// Attendance struct represents attendance data of one date
+// ErrUnknownEmployee is returned for imported punches of an employee who no
+// longer exists
+var ErrUnknownEmployee = errors.New("employee does not exist")
+
+// Attendance struct represents attendance data of one date and where the
+// employee worked (see AttendanceType), summarizing its punches: CheckInAt is
+// the first check-in of the day, CheckOutAt the last check-out (nil while the
+// employee is checked in), and WorkedMinutes the net time worked between
+// check-ins and check-outs, without breaks. AutoClosed is set when the employee
+// forgot to check out and the check-out was recorded by the auto-checkout job
+// at the scheduled end of the workday.
 type Attendance struct {
-	ID         int64      `json:"id"`
-	EmployeeID int64      `json:"employee_id"`
-	AttDate    string     `json:"att_date"`
-	CheckInAt  time.Time  `json:"checkin_at"`
-	CheckOutAt *time.Time `json:"checkout_at,omitempty"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
-	CreatedBy  int64      `json:"created_by"`
-	UpdatedBy  int64      `json:"updated_by"`
+	ID            int64      `json:"id"`
+	EmployeeID    int64      `json:"employee_id"`
+	AttDate       string     `json:"att_date"`
+	Type          string     `json:"attendance_type"`
+	CheckInAt     time.Time  `json:"checkin_at"`
+	CheckOutAt    *time.Time `json:"checkout_at,omitempty"`
+	WorkedMinutes int        `json:"worked_minutes"`
+	BreakMinutes  int        `json:"break_minutes"`
+	AutoClosed    bool       `json:"auto_closed"`
+	Punches       []*Punch   `json:"punches,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	CreatedBy     int64      `json:"created_by"`
+	UpdatedBy     int64      `json:"updated_by"`
+}
+
+// Punch struct represents one check-in, check-out, or start or end of a break.
+// The coordinates and kiosk are those reported with the punch, and the IP
+// address, user agent and device ID those it was sent from, if any. Punches
+// recorded by the system have no CreatedBy.
+type Punch struct {
+	ID           int64     `json:"id"`
+	AttendanceID int64     `json:"attendance_id"`
+	Kind         string    `json:"type"`
+	PunchedAt    time.Time `json:"punched_at"`
+	Latitude     *float64  `json:"latitude,omitempty"`
+	Longitude    *float64  `json:"longitude,omitempty"`
+	KioskID      *int64    `json:"kiosk_id,omitempty"`
+	IPAddress    *string   `json:"ip_address,omitempty"`
+	UserAgent    *string   `json:"user_agent,omitempty"`
+	DeviceID     *string   `json:"device_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	CreatedBy    *int64    `json:"created_by,omitempty"`
+}
+
+// punchState returns the state the punches leave the employee in: PunchIn while
+// working, PunchBreakStart while on a break, PunchOut once checked out, or an
+// empty string if there are no punches
+func punchState(punches []*Punch) string {
+	if len(punches) == 0 {
+		return ""
+	}
+	switch kind := punches[len(punches)-1].Kind; kind {
+	case PunchBreakEnd:
+		return PunchIn
+	default:
+		return kind
+	}
+}
+
+// checkPunch returns an error if the punch cannot follow the previous punches of
+// the day. Checking out during a break also ends the break.
+func checkPunch(punches []*Punch, p *Punch) error {
+	for _, existing := range punches {
+		if existing.Kind == p.Kind && existing.PunchedAt.Equal(p.PunchedAt) {
+			return ErrDuplicatePunch
+		}
+	}
+
+	if len(punches) > 0 && p.PunchedAt.Before(punches[len(punches)-1].PunchedAt) {
+		return ErrPunchOutOfOrder
+	}
+
+	state := punchState(punches)
+	switch p.Kind {
+	case PunchIn:
+		if state == PunchIn || state == PunchBreakStart {
+			return ErrDuplicateCheckIn
+		}
+	case PunchOut:
+		if state == PunchOut {
+			return ErrDuplicateCheckOut
+		}
+		if state == "" {
+			return ErrNotCheckedIn
+		}
+	case PunchBreakStart:
+		if state == PunchBreakStart {
+			return ErrAlreadyOnBreak
+		}
+		if state != PunchIn {
+			return ErrNotCheckedIn
+		}
+	case PunchBreakEnd:
+		if state != PunchBreakStart {
+			return ErrNotOnBreak
+		}
+	}
+
+	return nil
+}
+
+// WorkedTime returns the net time worked and the time spent on breaks according
+// to the punches. A session or break which is still open is counted up to the
+// given time, or not at all if it is zero.
+func WorkedTime(punches []*Punch, until time.Time) (worked, breaks time.Duration) {
+	var workStart, breakStart *time.Time
+	for _, p := range punches {
+		at := p.PunchedAt
+		switch p.Kind {
+		case PunchIn, PunchBreakEnd:
+			if breakStart != nil {
+				breaks += at.Sub(*breakStart)
+				breakStart = nil
+			}
+			workStart = &at
+		case PunchBreakStart:
+			if workStart != nil {
+				worked += at.Sub(*workStart)
+				workStart = nil
+			}
+			breakStart = &at
+		case PunchOut:
+			if workStart != nil {
+				worked += at.Sub(*workStart)
+				workStart = nil
+			}
+			if breakStart != nil {
+				breaks += at.Sub(*breakStart)
+				breakStart = nil
+			}
+		}
+	}
+
+	if !until.IsZero() {
+		if workStart != nil && until.After(*workStart) {
+			worked += until.Sub(*workStart)
+		}
+		if breakStart != nil && until.After(*breakStart) {
+			breaks += until.Sub(*breakStart)
+		}
+	}
+
+	return worked, breaks
+}
+
+// summarize updates the attendance summary from its punches
+func (a *Attendance) summarize() {
+	for _, p := range a.Punches {
+		if p.Kind == PunchIn {
+			a.CheckInAt = p.PunchedAt
+			break
+		}
+	}
+
+	a.CheckOutAt = nil
+	if punchState(a.Punches) == PunchOut {
+		checkOutAt := a.Punches[len(a.Punches)-1].PunchedAt
+		a.CheckOutAt = &checkOutAt
+	}
+
+	worked, breaks := WorkedTime(a.Punches, time.Time{})
+	a.WorkedMinutes = int(worked / time.Minute)
+	a.BreakMinutes = int(breaks / time.Minute)
 }
 
 // AttendanceModel struct wraps the connection pool
@@ -33,101 +202,629 @@ type AttendanceModel struct {
 	DB *sql.DB
 }
 
-// Record new employee check-in in the database
-func (m AttendanceModel) RecordCheckIn(attendance *Attendance) error {
+const attendanceColumns = `id, employee_id, att_date::text, attendance_type, checkin_at, checkout_at, worked_minutes, break_minutes,
+	auto_closed, created_at, created_by, updated_at, updated_by`
+
+func scanAttendance(row interface{ Scan(...any) error }, a *Attendance) error {
+	return row.Scan(
+		&a.ID,
+		&a.EmployeeID,
+		&a.AttDate,
+		&a.Type,
+		&a.CheckInAt,
+		&a.CheckOutAt,
+		&a.WorkedMinutes,
+		&a.BreakMinutes,
+		&a.AutoClosed,
+		&a.CreatedAt,
+		&a.CreatedBy,
+		&a.UpdatedAt,
+		&a.UpdatedBy,
+	)
+}
+
+// Get attendance data of a date, with its punches, from the database
+func (m AttendanceModel) Get(employeeId int64, date string) (*Attendance, error) {
 	query := `
-		INSERT INTO attendance (employee_id, att_date, checkin_at, created_by, updated_by)
-		VALUES ($1, $2, $3, $4, $5)`
+		SELECT ` + attendanceColumns + `
+		FROM attendance
+		WHERE employee_id = $1 AND att_date = $2`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	// If the table already contains a check in record for this employee at the check in date,
-	// there will be a violation of the UNIQUE constraint, since attendance on the same day should
-	// count as one
-	_, err := m.DB.ExecContext(ctx, query,
-		attendance.EmployeeID,
-		attendance.AttDate,
-		&attendance.CheckInAt,
-		attendance.CreatedBy,
-		attendance.UpdatedBy)
+	var attendance Attendance
+
+	err := scanAttendance(m.DB.QueryRowContext(ctx, query, employeeId, date), &attendance)
 	if err != nil {
-		var pqErr *pq.Error
-		if errors.As(err, &pqErr) {
-			if pqErr.Code == "23505" { // unique_violation
-				return ErrDuplicateCheckIn
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	attendance.Punches, err = getPunches(ctx, m.DB, attendance.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attendance, nil
+}
+
+// EmployeeAttendance struct holds the attendance of one employee over a range
+// of dates, in date order, with the time zone of their office
+type EmployeeAttendance struct {
+	EmployeeID   int64
+	EmployeeName string
+	Timezone     string
+	Days         []*Attendance
+}
+
+// ForEachEmployee calls fn with the attendance, including punches, of every
+// employee who attended between two dates (inclusive), one employee at a time
+// so that only their attendance is held in memory. defaultTZ is the time zone
+// of employees without an office.
+func (m AttendanceModel) ForEachEmployee(ctx context.Context, from, to, defaultTZ string, fn func(*EmployeeAttendance) error) error {
+	query := `
+		SELECT u.id, u.name, COALESCE(o.timezone, $3),
+			a.id, a.employee_id, a.att_date::text, a.attendance_type, a.checkin_at, a.checkout_at, a.worked_minutes, a.break_minutes,
+			a.auto_closed, a.created_at, a.created_by, a.updated_at, a.updated_by,
+			p.id, p.attendance_id, p.kind, p.punched_at, p.latitude, p.longitude, p.kiosk_id, host(p.ip_address),
+			p.user_agent, p.device_id, p.created_at, p.created_by
+		FROM attendance a
+		JOIN users u ON u.id = a.employee_id
+		LEFT JOIN offices o ON o.id = u.office_id
+		JOIN attendance_punches p ON p.attendance_id = a.id
+		WHERE a.att_date BETWEEN $1 AND $2
+		ORDER BY u.id, a.att_date, p.punched_at, p.id`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, from, to, defaultTZ)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var employee *EmployeeAttendance
+	var day *Attendance
+	for rows.Next() {
+		var e EmployeeAttendance
+		var a Attendance
+		var p Punch
+		err := rows.Scan(
+			&e.EmployeeID,
+			&e.EmployeeName,
+			&e.Timezone,
+			&a.ID,
+			&a.EmployeeID,
+			&a.AttDate,
+			&a.Type,
+			&a.CheckInAt,
+			&a.CheckOutAt,
+			&a.WorkedMinutes,
+			&a.BreakMinutes,
+			&a.AutoClosed,
+			&a.CreatedAt,
+			&a.CreatedBy,
+			&a.UpdatedAt,
+			&a.UpdatedBy,
+			&p.ID,
+			&p.AttendanceID,
+			&p.Kind,
+			&p.PunchedAt,
+			&p.Latitude,
+			&p.Longitude,
+			&p.KioskID,
+			&p.IPAddress,
+			&p.UserAgent,
+			&p.DeviceID,
+			&p.CreatedAt,
+			&p.CreatedBy,
+		)
+		if err != nil {
+			return err
+		}
+
+		if employee == nil || employee.EmployeeID != e.EmployeeID {
+			if employee != nil {
+				if err := fn(employee); err != nil {
+					return err
+				}
 			}
+			employee = &e
+			day = nil
+		}
+		if day == nil || day.ID != a.ID {
+			day = &a
+			employee.Days = append(employee.Days, day)
 		}
+		day.Punches = append(day.Punches, &p)
+	}
+	if err := rows.Err(); err != nil {
 		return err
 	}
 
+	if employee != nil {
+		return fn(employee)
+	}
 	return nil
 }
 
-// Get attendance data from the database
-func (m AttendanceModel) Get(employeeId int64, date string) (*Attendance, error) {
-	query := `
-        SELECT id, employee_id, att_date, checkin_at, checkout_at, created_at, created_by, updated_at, updated_by
-        FROM attendance
-        WHERE employee_id = $1 AND att_date = $2`
-
+// RecordPunch records a punch of the employee on the given date. The first
+// check-in of the day creates the attendance, of the given attendance type;
+// other punches must follow the previous punches of the day (ErrRecordNotFound
+// if there are none). The updated attendance is returned with all its punches.
+// A punch synced from an offline event is committed along with the event
+// resolved as applied, so the event cannot be left pending once it is recorded.
+func (m AttendanceModel) RecordPunch(employeeID int64, date, attendanceType string, p *Punch, event *SyncEvent) (*Attendance, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	var attendance Attendance
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-	err := m.DB.QueryRowContext(ctx, query, employeeId, date).Scan(
-		&attendance.ID,
-		&attendance.EmployeeID,
-		&attendance.AttDate,
-		&attendance.CheckInAt,
-		&attendance.CheckOutAt,
-		&attendance.CreatedAt,
-		&attendance.CreatedBy,
-		&attendance.UpdatedAt,
-		&attendance.UpdatedBy,
-	)
+	attendance, err := recordPunch(ctx, tx, employeeID, date, attendanceType, p)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if event != nil {
+		event.Status = SyncEventApplied
+		event.AttendanceID = &attendance.ID
+		err = resolveSyncEvent(ctx, tx, event)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return attendance, tx.Commit()
+}
+
+// PunchImport is one punch of an imported punch log, with the error which
+// prevented recording it, if any
+type PunchImport struct {
+	EmployeeID int64
+	Date       string
+	Punch      *Punch
+	Err        error
+}
+
+// ImportPunches records the punches as office attendance, in the given order
+// within one transaction, setting the Err of those which cannot be recorded.
+// The punches are only committed if commit is true and all of them were
+// recorded, otherwise the transaction is rolled back, which makes a dry run
+// with commit set to false. Each punch is recorded within a savepoint, so that
+// a punch failing in the database does not abort the transaction for the
+// following ones. It returns whether the punches were committed.
+func (m AttendanceModel) ImportPunches(imports []*PunchImport, commit bool) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	valid := true
+	for _, imp := range imports {
+		_, err = tx.ExecContext(ctx, `SAVEPOINT import_punch`)
+		if err != nil {
+			return false, err
+		}
+
+		_, err := recordPunch(ctx, tx, imp.EmployeeID, imp.Date, AttendanceOffice, imp.Punch)
+
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" { // foreign_key_violation
+			err = ErrUnknownEmployee
+		}
+
+		if err == nil {
+			_, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT import_punch`)
+			if err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		_, rollbackErr := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT import_punch`)
+		if rollbackErr != nil {
+			return false, rollbackErr
+		}
+
+		switch {
+		case errors.Is(err, ErrUnknownEmployee),
+			errors.Is(err, ErrRecordNotFound),
+			errors.Is(err, ErrDuplicateCheckIn),
+			errors.Is(err, ErrDuplicateCheckOut),
+			errors.Is(err, ErrNotCheckedIn),
+			errors.Is(err, ErrAlreadyOnBreak),
+			errors.Is(err, ErrNotOnBreak),
+			errors.Is(err, ErrPunchOutOfOrder),
+			errors.Is(err, ErrDuplicatePunch):
+			imp.Err = err
+			valid = false
+		default:
+			return false, err
+		}
+	}
+
+	if !commit || !valid {
+		return false, nil
+	}
+
+	return true, tx.Commit()
+}
+
+// recordPunch records a punch within the transaction, see RecordPunch
+func recordPunch(ctx context.Context, tx *sql.Tx, employeeID int64, date, attendanceType string, p *Punch) (*Attendance, error) {
+	query := `
+		SELECT ` + attendanceColumns + `
+		FROM attendance
+		WHERE employee_id = $1 AND att_date = $2
+		FOR UPDATE`
+
+	var attendance Attendance
+
+	err := scanAttendance(tx.QueryRowContext(ctx, query, employeeID, date), &attendance)
+	switch {
+	case err == nil:
+		attendance.Punches, err = getPunches(ctx, tx, attendance.ID)
+		if err != nil {
+			return nil, err
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		if p.Kind != PunchIn {
 			return nil, ErrRecordNotFound
 		}
+
+		query := `
+			INSERT INTO attendance (employee_id, att_date, attendance_type, checkin_at, created_by, updated_by)
+			VALUES ($1, $2, $3, $4, $5, $5)
+			RETURNING ` + attendanceColumns
+
+		// A concurrent first check-in of the same day violates the UNIQUE
+		// constraint, since attendance on the same day should count as one
+		err = scanAttendance(tx.QueryRowContext(ctx, query, employeeID, date, attendanceType, p.PunchedAt, p.CreatedBy), &attendance)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+				return nil, ErrDuplicateCheckIn
+			}
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	err = checkPunch(attendance.Punches, p)
+	if err != nil {
+		return nil, err
+	}
+
+	p.AttendanceID = attendance.ID
+	err = insertPunch(ctx, tx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	attendance.Punches = append(attendance.Punches, p)
+	attendance.summarize()
+	if p.CreatedBy != nil {
+		attendance.UpdatedBy = *p.CreatedBy
+	}
+
+	err = updateAttendanceSummary(ctx, tx, &attendance)
+	if err != nil {
 		return nil, err
 	}
 
 	return &attendance, nil
 }
 
-// Record new employee check-out in the database
-func (m AttendanceModel) RecordCheckOut(attendance *Attendance) error {
+// CloseOpen records a check-out for every attendance on or before the given date
+// (YYYY-MM-DD) whose employee is still checked in or on a break, at the
+// scheduled end of the workday (HH:MM) in the time zone of the employee's
+// office, or defaultTZ for employees without an office. Days with a rostered
+// working shift end with the shift instead, and are left open until it has
+// ended. A day whose last punch is later than its scheduled end is closed at
+// the time of that punch. The closed attendances are flagged as auto-closed and
+// returned.
+func (m AttendanceModel) CloseOpen(date, workdayEnd, defaultTZ string) ([]*Attendance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	query := `
-		UPDATE attendance 
-		SET updated_by = $1, checkout_at = $2, updated_at = now()
-		WHERE employee_id = $3 AND att_date = $4
-	`
+		SELECT a.id, a.employee_id, a.att_date::text, a.attendance_type, a.checkin_at, a.checkout_at, a.worked_minutes, a.break_minutes,
+			a.auto_closed, a.created_at, a.created_by, a.updated_at, a.updated_by,
+			e.scheduled_end
+		FROM attendance a
+		JOIN users u ON u.id = a.employee_id
+		LEFT JOIN offices o ON o.id = u.office_id
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(
+				(SELECT max(re.ends_at) FROM roster_entries re
+					JOIN shifts s ON s.id = re.shift_id
+					WHERE re.employee_id = a.employee_id AND re.shift_date = a.att_date AND NOT s.on_call),
+				(a.att_date + $2::time) AT TIME ZONE COALESCE(o.timezone, $3)
+			) AS scheduled_end
+		) e
+		WHERE a.checkout_at IS NULL AND a.att_date <= $1 AND e.scheduled_end <= now()
+		ORDER BY a.id
+		FOR UPDATE OF a`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	rows, err := tx.QueryContext(ctx, query, date, workdayEnd, defaultTZ)
+	if err != nil {
+		return nil, err
+	}
+
+	closed := []*Attendance{}
+	var scheduledEnds []time.Time
+	for rows.Next() {
+		var attendance Attendance
+		var scheduledEnd time.Time
+		err := rows.Scan(
+			&attendance.ID,
+			&attendance.EmployeeID,
+			&attendance.AttDate,
+			&attendance.Type,
+			&attendance.CheckInAt,
+			&attendance.CheckOutAt,
+			&attendance.WorkedMinutes,
+			&attendance.BreakMinutes,
+			&attendance.AutoClosed,
+			&attendance.CreatedAt,
+			&attendance.CreatedBy,
+			&attendance.UpdatedAt,
+			&attendance.UpdatedBy,
+			&scheduledEnd,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		closed = append(closed, &attendance)
+		scheduledEnds = append(scheduledEnds, scheduledEnd)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, attendance := range closed {
+		attendance.Punches, err = getPunches(ctx, tx, attendance.ID)
+		if err != nil {
+			return nil, err
+		}
 
-	result, err := m.DB.ExecContext(ctx, query,
-		attendance.UpdatedBy,
-		attendance.CheckOutAt,
-		attendance.EmployeeID,
-		attendance.AttDate,
+		out := &Punch{AttendanceID: attendance.ID, Kind: PunchOut, PunchedAt: scheduledEnds[i]}
+		if n := len(attendance.Punches); n > 0 && attendance.Punches[n-1].PunchedAt.After(out.PunchedAt) {
+			out.PunchedAt = attendance.Punches[n-1].PunchedAt
+		}
+
+		err = insertPunch(ctx, tx, out)
+		if err != nil {
+			return nil, err
+		}
+
+		attendance.Punches = append(attendance.Punches, out)
+		attendance.summarize()
+		attendance.AutoClosed = true
+
+		err = updateAttendanceSummary(ctx, tx, attendance)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return closed, tx.Commit()
+}
+
+const punchColumns = `id, attendance_id, kind, punched_at, latitude, longitude, kiosk_id, host(ip_address),
+	user_agent, device_id, created_at, created_by`
+
+func scanPunch(row interface{ Scan(...any) error }, p *Punch) error {
+	return row.Scan(
+		&p.ID,
+		&p.AttendanceID,
+		&p.Kind,
+		&p.PunchedAt,
+		&p.Latitude,
+		&p.Longitude,
+		&p.KioskID,
+		&p.IPAddress,
+		&p.UserAgent,
+		&p.DeviceID,
+		&p.CreatedAt,
+		&p.CreatedBy,
 	)
+}
+
+// getPunches returns the punches of an attendance in the order they happened
+func getPunches(ctx context.Context, db interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}, attendanceID int64) ([]*Punch, error) {
+	query := `
+		SELECT ` + punchColumns + `
+		FROM attendance_punches
+		WHERE attendance_id = $1
+		ORDER BY punched_at, id`
+
+	rows, err := db.QueryContext(ctx, query, attendanceID)
 	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	punches := []*Punch{}
+	for rows.Next() {
+		var p Punch
+		err := scanPunch(rows, &p)
+		if err != nil {
+			return nil, err
+		}
+		punches = append(punches, &p)
+	}
+
+	return punches, rows.Err()
+}
+
+// insertPunch inserts a punch within the transaction
+func insertPunch(ctx context.Context, tx *sql.Tx, p *Punch) error {
+	query := `
+		INSERT INTO attendance_punches (attendance_id, kind, punched_at, latitude, longitude, kiosk_id,
+			ip_address, user_agent, device_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`
+
+	return tx.QueryRowContext(ctx, query,
+		p.AttendanceID,
+		p.Kind,
+		p.PunchedAt,
+		p.Latitude,
+		p.Longitude,
+		p.KioskID,
+		p.IPAddress,
+		p.UserAgent,
+		p.DeviceID,
+		p.CreatedBy,
+	).Scan(&p.ID, &p.CreatedAt)
+}
+
+// AttendanceEventsChannel is the Postgres NOTIFY channel on which every change
+// to an attendance is published as an AttendanceEvent, once its transaction
+// commits
+const AttendanceEventsChannel = "attendance_events"
+
+// AttendanceEvent struct is the payload published on AttendanceEventsChannel:
+// the latest punch of an attendance and the resulting summary
+type AttendanceEvent struct {
+	AttendanceID int64      `json:"attendance_id"`
+	EmployeeID   int64      `json:"employee_id"`
+	EmployeeName string     `json:"employee_name"`
+	Date         string     `json:"date"`
+	Kind         string     `json:"kind"`
+	PunchedAt    time.Time  `json:"punched_at"`
+	CheckInAt    time.Time  `json:"checkin_at"`
+	CheckOutAt   *time.Time `json:"checkout_at"`
+	AutoClosed   bool       `json:"auto_closed"`
+}
+
+// updateAttendanceSummary stores the summary of the attendance's punches and
+// publishes the change on AttendanceEventsChannel
+func updateAttendanceSummary(ctx context.Context, tx *sql.Tx, a *Attendance) error {
+	query := `
+		UPDATE attendance
+		SET checkin_at = $1, checkout_at = $2, worked_minutes = $3, break_minutes = $4, auto_closed = $5,
+			updated_by = $6, updated_at = now()
+		WHERE id = $7
+		RETURNING updated_at`
+
+	err := tx.QueryRowContext(ctx, query,
+		a.CheckInAt,
+		a.CheckOutAt,
+		a.WorkedMinutes,
+		a.BreakMinutes,
+		a.AutoClosed,
+		a.UpdatedBy,
+		a.ID,
+	).Scan(&a.UpdatedAt)
+	if err != nil || len(a.Punches) == 0 {
 		return err
 	}
 
-	// Check if UPDATE applies to any row
-	rows, err := result.RowsAffected()
+	last := a.Punches[len(a.Punches)-1]
+	payload, err := json.Marshal(AttendanceEvent{
+		AttendanceID: a.ID,
+		EmployeeID:   a.EmployeeID,
+		Date:         a.AttDate,
+		Kind:         last.Kind,
+		PunchedAt:    last.PunchedAt,
+		CheckInAt:    a.CheckInAt,
+		CheckOutAt:   a.CheckOutAt,
+		AutoClosed:   a.AutoClosed,
+	})
 	if err != nil {
 		return err
 	}
 
-	// If there is no rows affected → no attendance data
-	if rows == 0 {
-		return ErrRecordNotFound
+	_, err = tx.ExecContext(ctx, `
+		SELECT pg_notify($1, jsonb_set($2::jsonb, '{employee_name}', to_jsonb(name))::text)
+		FROM users
+		WHERE id = $3`, AttendanceEventsChannel, payload, a.EmployeeID)
+	return err
+}
+
+// CountDays returns the number of days of the attendance type the employee
+// attended between two dates (inclusive)
+func (m AttendanceModel) CountDays(employeeID int64, attendanceType, from, to string) (int, error) {
+	query := `
+		SELECT count(*)
+		FROM attendance
+		WHERE employee_id = $1 AND attendance_type = $2 AND att_date BETWEEN $3 AND $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var days int
+	err := m.DB.QueryRowContext(ctx, query, employeeID, attendanceType, from, to).Scan(&days)
+	return days, err
+}
+
+// AttendanceSummary struct breaks down the attendance of an employee over a
+// range of dates by attendance type
+type AttendanceSummary struct {
+	Days          int            `json:"days"`
+	DaysByType    map[string]int `json:"days_by_type"`
+	WorkedMinutes int            `json:"worked_minutes"`
+	MealAllowance int64          `json:"meal_allowance"`
+}
+
+// Summary returns the attendance summary of the employee between two dates
+// (inclusive)
+func (m AttendanceModel) Summary(employeeID int64, from, to string) (*AttendanceSummary, error) {
+	query := `
+		SELECT t.code, count(a.id), COALESCE(sum(a.worked_minutes), 0), count(a.id) * t.meal_allowance
+		FROM attendance_types t
+		LEFT JOIN attendance a ON a.attendance_type = t.code
+			AND a.employee_id = $1 AND a.att_date BETWEEN $2 AND $3
+		GROUP BY t.id
+		ORDER BY t.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, employeeID, from, to)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
+	summary := AttendanceSummary{DaysByType: map[string]int{}}
+	for rows.Next() {
+		var code string
+		var days, workedMinutes int
+		var mealAllowance int64
+		if err := rows.Scan(&code, &days, &workedMinutes, &mealAllowance); err != nil {
+			return nil, err
+		}
+		summary.Days += days
+		summary.DaysByType[code] = days
+		summary.WorkedMinutes += workedMinutes
+		summary.MealAllowance += mealAllowance
+	}
+
+	return &summary, rows.Err()
 }
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// Attendance export statuses
+const (
+	ExportStatusPresent    = "present"
+	ExportStatusIncomplete = "incomplete"
+	ExportStatusAutoClosed = "auto_closed"
+	ExportStatusLeave      = "leave"
+	ExportStatusAbsent     = "absent"
+)
+
+// AttendanceExportRow struct is one row of the attendance export: the
+// attendance of an employee on a day they attended, or were expected to (a
+// weekday which is not a holiday for their office, before they were
+// deactivated). Check-in and check-out are
+// local date-times in the time zone of the employee's office.
+type AttendanceExportRow struct {
+	EmployeeID    int64
+	EmployeeName  string
+	Email         string
+	Date          string
+	Timezone      string
+	CheckIn       *string
+	CheckOut      *string
+	WorkedMinutes int
+	Status        string
+	LeaveType     *string
+}
+
+// Export calls fn with the attendance export rows between two dates (inclusive)
+// ordered by employee and date. Rows are read from the database as fn consumes
+// them, so exports of any size never have to be held in memory. defaultTZ is
+// the time zone of employees without an office.
+func (m AttendanceModel) Export(ctx context.Context, from, to, defaultTZ string, fn func(*AttendanceExportRow) error) error {
+	query := `
+	WITH employees AS (
+		SELECT u.id, u.name, u.email, u.office_id, COALESCE(o.timezone, $3) AS tz,
+			(u.deactivated_at AT TIME ZONE COALESCE(o.timezone, $3))::date AS deactivated_on
+		FROM users u
+		LEFT JOIN offices o ON o.id = u.office_id
+		WHERE u.role = 'employee'
+	),
+	days AS (
+		SELECT d::date AS day
+		FROM generate_series($1::date, $2::date, interval '1 day') d
+	)
+	SELECT e.id, e.name, e.email, d.day::text, e.tz,
+		to_char(a.checkin_at AT TIME ZONE e.tz, 'YYYY-MM-DD HH24:MI:SS'),
+		to_char(a.checkout_at AT TIME ZONE e.tz, 'YYYY-MM-DD HH24:MI:SS'),
+		COALESCE(a.worked_minutes, 0),
+		CASE
+			WHEN a.id IS NOT NULL AND a.auto_closed THEN 'auto_closed'
+			WHEN a.id IS NOT NULL AND a.checkout_at IS NULL THEN 'incomplete'
+			WHEN a.id IS NOT NULL THEN 'present'
+			WHEN lv.code IS NOT NULL THEN 'leave'
+			ELSE 'absent'
+		END,
+		lv.code
+	FROM employees e
+	CROSS JOIN days d
+	LEFT JOIN attendance a ON a.employee_id = e.id AND a.att_date = d.day
+	LEFT JOIN LATERAL (
+		SELECT lt.code
+		FROM leave_requests lr
+		JOIN leave_types lt ON lt.id = lr.leave_type_id
+		WHERE lr.employee_id = e.id AND lr.status = 'approved'
+		AND d.day BETWEEN lr.start_date AND lr.end_date
+		LIMIT 1
+	) lv ON true
+	WHERE a.id IS NOT NULL OR (
+		EXTRACT(ISODOW FROM d.day) < 6
+		AND (e.deactivated_on IS NULL OR d.day < e.deactivated_on)
+		AND NOT EXISTS (
+			SELECT 1 FROM holidays h
+			WHERE h.holiday_date = d.day
+			AND (h.office_id IS NULL OR h.office_id = e.office_id)
+		)
+	)
+	ORDER BY e.id, d.day`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, from, to, defaultTZ)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var row AttendanceExportRow
+	for rows.Next() {
+		err := rows.Scan(
+			&row.EmployeeID,
+			&row.EmployeeName,
+			&row.Email,
+			&row.Date,
+			&row.Timezone,
+			&row.CheckIn,
+			&row.CheckOut,
+			&row.WorkedMinutes,
+			&row.Status,
+			&row.LeaveType,
+		)
+		if err != nil {
+			return err
+		}
+
+		err = fn(&row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,125 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// Live attendance statuses
+const (
+	LiveStatusCheckedIn  = "checked_in"
+	LiveStatusCheckedOut = "checked_out"
+	LiveStatusOnLeave    = "on_leave"
+	LiveStatusAbsent     = "absent"
+)
+
+// LiveEmployee struct is the attendance of an employee today, in the time zone
+// of their office
+type LiveEmployee struct {
+	EmployeeID   int64      `json:"employee_id"`
+	EmployeeName string     `json:"employee_name"`
+	Date         string     `json:"date"`
+	Status       string     `json:"status"`
+	AttendanceID *int64     `json:"attendance_id,omitempty"`
+	Type         *string    `json:"attendance_type,omitempty"`
+	CheckInAt    *time.Time `json:"checkin_at,omitempty"`
+	CheckOutAt   *time.Time `json:"checkout_at,omitempty"`
+}
+
+// LiveBoard struct is a snapshot of who is present today. Present counts the
+// employees who checked in today, whether or not they have left since.
+type LiveBoard struct {
+	Employees  int             `json:"employees"`
+	Present    int             `json:"present"`
+	CheckedIn  int             `json:"checked_in"`
+	OnLeave    int             `json:"on_leave"`
+	Absent     int             `json:"absent"`
+	AsOf       time.Time       `json:"as_of"`
+	Attendance []*LiveEmployee `json:"attendance"`
+}
+
+// Board returns the attendance of every employee today. An employee still
+// checked in since yesterday, e.g. on a night shift, counts as present.
+// defaultTZ is the time zone of employees without an office.
+func (m AttendanceModel) Board(defaultTZ string) (*LiveBoard, error) {
+	query := `
+		WITH employees AS (
+			SELECT u.id, u.name, (now() AT TIME ZONE COALESCE(o.timezone, $1))::date AS today
+			FROM users u
+			LEFT JOIN offices o ON o.id = u.office_id
+			WHERE u.role = 'employee' AND u.active
+		)
+		SELECT e.id, e.name, e.today::text, a.id, a.attendance_type, a.checkin_at, a.checkout_at,
+			CASE
+				WHEN a.id IS NOT NULL AND a.checkout_at IS NULL THEN 'checked_in'
+				WHEN a.id IS NOT NULL THEN 'checked_out'
+				WHEN EXISTS (
+					SELECT 1 FROM leave_requests lr
+					WHERE lr.employee_id = e.id AND lr.status = 'approved'
+					AND e.today BETWEEN lr.start_date AND lr.end_date
+				) THEN 'on_leave'
+				ELSE 'absent'
+			END,
+			now()
+		FROM employees e
+		LEFT JOIN LATERAL (
+			SELECT a.id, a.attendance_type, a.checkin_at, a.checkout_at
+			FROM attendance a
+			WHERE a.employee_id = e.id
+			AND (a.att_date = e.today OR (a.att_date = e.today - 1 AND a.checkout_at IS NULL))
+			ORDER BY a.att_date DESC
+			LIMIT 1
+		) a ON true
+		ORDER BY e.name, e.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, defaultTZ)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	board := &LiveBoard{Attendance: []*LiveEmployee{}}
+	for rows.Next() {
+		var e LiveEmployee
+		err := rows.Scan(
+			&e.EmployeeID,
+			&e.EmployeeName,
+			&e.Date,
+			&e.AttendanceID,
+			&e.Type,
+			&e.CheckInAt,
+			&e.CheckOutAt,
+			&e.Status,
+			&board.AsOf,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		board.Employees++
+		switch e.Status {
+		case LiveStatusCheckedIn:
+			board.Present++
+			board.CheckedIn++
+		case LiveStatusCheckedOut:
+			board.Present++
+		case LiveStatusOnLeave:
+			board.OnLeave++
+		default:
+			board.Absent++
+		}
+		board.Attendance = append(board.Attendance, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if board.AsOf.IsZero() {
+		board.AsOf = time.Now()
+	}
+
+	return board, nil
+}
@@ -0,0 +1,180 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Attendance sync event statuses
+const (
+	SyncEventPending  = "pending"
+	SyncEventApplied  = "applied"
+	SyncEventRejected = "rejected"
+)
+
+// SyncEvent struct represents a check-in or check-out recorded offline by an
+// employee's device and uploaded later
+type SyncEvent struct {
+	ID           string          `json:"event_id"`
+	EmployeeID   int64           `json:"employee_id"`
+	DeviceID     string          `json:"device_id"`
+	Kind         string          `json:"type"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	Status       string          `json:"status"`
+	Error        json.RawMessage `json:"error,omitempty"`
+	AttendanceID *int64          `json:"attendance_id,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// SyncEventModel struct wraps the connection pool
+type SyncEventModel struct {
+	DB *sql.DB
+}
+
+// Claim records a new event as pending before it is applied. It returns false,
+// without modifying the database, if an event with the same ID was already
+// received, which makes replaying a batch safe.
+func (m SyncEventModel) Claim(event *SyncEvent) (bool, error) {
+	query := `
+		INSERT INTO attendance_sync_events (id, employee_id, device_id, kind, occurred_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING status, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		event.ID,
+		event.EmployeeID,
+		event.DeviceID,
+		event.Kind,
+		event.OccurredAt,
+	).Scan(&event.Status, &event.CreatedAt, &event.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Get sync event by its client-generated ID from the database
+func (m SyncEventModel) Get(id string) (*SyncEvent, error) {
+	query := `
+		SELECT id, employee_id, device_id, kind, occurred_at, status, error, attendance_id, created_at, updated_at
+		FROM attendance_sync_events
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var event SyncEvent
+	var eventError []byte
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&event.ID,
+		&event.EmployeeID,
+		&event.DeviceID,
+		&event.Kind,
+		&event.OccurredAt,
+		&event.Status,
+		&eventError,
+		&event.AttendanceID,
+		&event.CreatedAt,
+		&event.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	event.Error = eventError
+	return &event, nil
+}
+
+// Reclaim claims again an event left pending for longer than timeout, e.g.
+// because the server stopped while applying it. A punch and the outcome of its
+// event are committed together, so a pending event recorded nothing and can be
+// applied again. It returns false if the event is not the employee's, is no
+// longer pending, or may still be being applied.
+func (m SyncEventModel) Reclaim(event *SyncEvent, timeout time.Duration) (bool, error) {
+	query := `
+		UPDATE attendance_sync_events
+		SET updated_at = now()
+		WHERE id = $1 AND employee_id = $2 AND status = 'pending'
+		AND updated_at < now() - make_interval(secs => $3)
+		RETURNING status, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, event.ID, event.EmployeeID, timeout.Seconds()).Scan(
+		&event.Status,
+		&event.CreatedAt,
+		&event.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Resolve stores the outcome (applied or rejected) of a claimed event. Events
+// applied as a punch are resolved by AttendanceModel.RecordPunch instead.
+func (m SyncEventModel) Resolve(event *SyncEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return resolveSyncEvent(ctx, m.DB, event)
+}
+
+// resolveSyncEvent stores the outcome of the event, within a transaction or not
+func resolveSyncEvent(ctx context.Context, db interface {
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}, event *SyncEvent) error {
+	query := `
+		UPDATE attendance_sync_events
+		SET status = $1, error = $2, attendance_id = $3, updated_at = now()
+		WHERE id = $4
+		RETURNING updated_at`
+
+	// Store SQL NULL rather than a JSON null when there is no error. The JSON is
+	// passed as a string since lib/pq would otherwise send []byte as bytea.
+	var eventError *string
+	if len(event.Error) > 0 {
+		e := string(event.Error)
+		eventError = &e
+	}
+
+	return db.QueryRowContext(ctx, query,
+		event.Status,
+		eventError,
+		event.AttendanceID,
+		event.ID,
+	).Scan(&event.UpdatedAt)
+}
+
+// Release removes a claimed event which could not be processed because of an
+// unexpected error, so the device can retry it
+func (m SyncEventModel) Release(event *SyncEvent) error {
+	query := `DELETE FROM attendance_sync_events WHERE id = $1 AND status = 'pending'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, event.ID)
+	return err
+}
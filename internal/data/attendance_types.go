@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Attendance type codes
+const (
+	AttendanceOffice       = "office"
+	AttendanceWFH          = "wfh"
+	AttendanceBusinessTrip = "business_trip"
+	AttendanceClientSite   = "client_site"
+)
+
+// AttendanceType struct represents where an employee works on an attendance
+// day. Only days at the office are subject to the office geofence and kiosk.
+type AttendanceType struct {
+	ID            int64     `json:"id"`
+	Code          string    `json:"code"`
+	Name          string    `json:"name"`
+	AtOffice      bool      `json:"at_office"`
+	MealAllowance int64     `json:"meal_allowance"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// AttendanceTypeModel struct wraps the connection pool
+type AttendanceTypeModel struct {
+	DB *sql.DB
+}
+
+const attendanceTypeColumns = `id, code, name, at_office, meal_allowance, created_at, updated_at`
+
+func scanAttendanceType(row interface{ Scan(...any) error }, t *AttendanceType) error {
+	return row.Scan(
+		&t.ID,
+		&t.Code,
+		&t.Name,
+		&t.AtOffice,
+		&t.MealAllowance,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+}
+
+// GetByCode returns the attendance type with the code from the database
+func (m AttendanceTypeModel) GetByCode(code string) (*AttendanceType, error) {
+	query := `SELECT ` + attendanceTypeColumns + ` FROM attendance_types WHERE code = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var t AttendanceType
+	err := scanAttendanceType(m.DB.QueryRowContext(ctx, query, code), &t)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// GetAll returns all attendance types
+func (m AttendanceTypeModel) GetAll() ([]*AttendanceType, error) {
+	query := `SELECT ` + attendanceTypeColumns + ` FROM attendance_types ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := []*AttendanceType{}
+	for rows.Next() {
+		var t AttendanceType
+		if err := scanAttendanceType(rows, &t); err != nil {
+			return nil, err
+		}
+		types = append(types, &t)
+	}
+
+	return types, rows.Err()
+}
+
+// UpdateMealAllowance sets the meal allowance paid per day of the type
+func (m AttendanceTypeModel) UpdateMealAllowance(t *AttendanceType, mealAllowance int64) error {
+	query := `
+		UPDATE attendance_types
+		SET meal_allowance = $1, updated_at = now()
+		WHERE id = $2
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, mealAllowance, t.ID).Scan(&t.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	t.MealAllowance = mealAllowance
+	return nil
+}
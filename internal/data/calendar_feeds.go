@@ -0,0 +1,184 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Kinds of calendar events
+const (
+	CalendarHoliday = "holiday"
+	CalendarLeave   = "leave"
+	CalendarShift   = "shift"
+	CalendarPayDate = "pay_date"
+)
+
+// CalendarFeed struct represents the calendar feed of a user. The token in the
+// feed's URL is only known when the feed is created or rotated.
+type CalendarFeed struct {
+	UserID         int64      `json:"user_id"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// CalendarEvent struct is an entry of a calendar feed. Holidays, leave and pay
+// dates last whole days from StartDate to EndDate (inclusive), while shifts
+// run from StartsAt to EndsAt.
+type CalendarEvent struct {
+	Kind      string
+	ID        int64
+	Summary   string
+	StartDate string
+	EndDate   string
+	StartsAt  *time.Time
+	EndsAt    *time.Time
+}
+
+// CalendarFeedModel struct wraps the connection pool
+type CalendarFeedModel struct {
+	DB *sql.DB
+}
+
+// Rotate creates the user's calendar feed, or replaces its token if it already
+// exists so the previous URL stops working, and returns the plaintext token
+func (m CalendarFeedModel) Rotate(userID int64) (*CalendarFeed, string, error) {
+	token, hash, err := generateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `
+		INSERT INTO calendar_feeds (user_id, token_hash)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET token_hash = EXCLUDED.token_hash, last_accessed_at = NULL, created_at = now()
+		RETURNING user_id, last_accessed_at, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var feed CalendarFeed
+	err = m.DB.QueryRowContext(ctx, query, userID, hash).Scan(&feed.UserID, &feed.LastAccessedAt, &feed.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &feed, token, nil
+}
+
+// Get returns the calendar feed of the user
+func (m CalendarFeedModel) Get(userID int64) (*CalendarFeed, error) {
+	query := `SELECT user_id, last_accessed_at, created_at FROM calendar_feeds WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var feed CalendarFeed
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&feed.UserID, &feed.LastAccessedAt, &feed.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &feed, nil
+}
+
+// Delete revokes the calendar feed of the user
+func (m CalendarFeedModel) Delete(userID int64) error {
+	query := `DELETE FROM calendar_feeds WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetUserIDForToken returns the ID of the user whose calendar feed has the
+// plaintext token, and records that the feed has been accessed
+func (m CalendarFeedModel) GetUserIDForToken(token string) (int64, error) {
+	hash := sha256.Sum256([]byte(token))
+
+	query := `
+		UPDATE calendar_feeds
+		SET last_accessed_at = now()
+		WHERE token_hash = $1
+		RETURNING user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var userID int64
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Events returns the events of the user's calendar between two dates
+// (inclusive): the holidays of their office, their approved leave, their
+// rostered shifts and the pay dates of payroll periods, ordered by date
+func (m CalendarFeedModel) Events(user *User, from, to string) ([]*CalendarEvent, error) {
+	query := `
+		SELECT 'holiday', h.id, h.name, h.holiday_date::text, h.holiday_date::text, NULL::timestamptz, NULL::timestamptz
+		FROM holidays h
+		WHERE h.holiday_date BETWEEN $2 AND $3 AND (h.office_id IS NULL OR h.office_id = $4)
+		UNION ALL
+		SELECT 'leave', lr.id, lt.name, lr.start_date::text, lr.end_date::text, NULL, NULL
+		FROM leave_requests lr
+		JOIN leave_types lt ON lt.id = lr.leave_type_id
+		WHERE lr.employee_id = $1 AND lr.status = 'approved' AND lr.start_date <= $3 AND lr.end_date >= $2
+		UNION ALL
+		SELECT 'shift', re.id, s.name, re.shift_date::text, re.shift_date::text, re.starts_at, re.ends_at
+		FROM roster_entries re
+		JOIN shifts s ON s.id = re.shift_id
+		WHERE re.employee_id = $1 AND re.shift_date BETWEEN $2 AND $3
+		UNION ALL
+		SELECT 'pay_date', p.id, 'Pay day', p.pay_date::text, p.pay_date::text, NULL, NULL
+		FROM payroll_periods p
+		WHERE p.pay_date BETWEEN $2 AND $3
+		ORDER BY 4, 1, 2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, user.ID, from, to, user.OfficeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*CalendarEvent{}
+	for rows.Next() {
+		var e CalendarEvent
+		err := rows.Scan(&e.Kind, &e.ID, &e.Summary, &e.StartDate, &e.EndDate, &e.StartsAt, &e.EndsAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+
+	return events, rows.Err()
+}
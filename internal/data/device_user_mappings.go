@@ -0,0 +1,116 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateDeviceUserID = errors.New("device user ID is already mapped to an employee")
+)
+
+// DeviceUserMapping struct maps a user ID enrolled on biometric attendance
+// devices to an employee
+type DeviceUserMapping struct {
+	ID           int64     `json:"id"`
+	DeviceUserID string    `json:"device_user_id"`
+	UserID       int64     `json:"user_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	CreatedBy    int64     `json:"created_by"`
+}
+
+// DeviceUserMappingModel struct wraps the connection pool
+type DeviceUserMappingModel struct {
+	DB *sql.DB
+}
+
+// Insert new mapping in the database
+func (m DeviceUserMappingModel) Insert(mapping *DeviceUserMapping) error {
+	query := `
+		INSERT INTO device_user_mappings (device_user_id, user_id, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		mapping.DeviceUserID,
+		mapping.UserID,
+		mapping.CreatedBy,
+	).Scan(&mapping.ID, &mapping.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			if pqErr.Code == "23505" {
+				return ErrDuplicateDeviceUserID
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetAll returns all mappings ordered by device user ID
+func (m DeviceUserMappingModel) GetAll() ([]*DeviceUserMapping, error) {
+	query := `
+		SELECT id, device_user_id, user_id, created_at, created_by
+		FROM device_user_mappings
+		ORDER BY device_user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mappings := []*DeviceUserMapping{}
+	for rows.Next() {
+		var mapping DeviceUserMapping
+		err := rows.Scan(
+			&mapping.ID,
+			&mapping.DeviceUserID,
+			&mapping.UserID,
+			&mapping.CreatedAt,
+			&mapping.CreatedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, &mapping)
+	}
+
+	return mappings, rows.Err()
+}
+
+// Delete mapping from the database
+func (m DeviceUserMappingModel) Delete(id int64) error {
+	query := `DELETE FROM device_user_mappings WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
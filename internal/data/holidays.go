@@ -0,0 +1,176 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateHoliday = errors.New("a holiday already exists on this date")
+)
+
+// Holiday struct represents a public or office-specific holiday
+type Holiday struct {
+	ID        int64     `json:"id"`
+	Date      string    `json:"date"`
+	Name      string    `json:"name"`
+	OfficeID  *int64    `json:"office_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	CreatedBy int64     `json:"created_by"`
+	UpdatedBy int64     `json:"updated_by"`
+}
+
+// HolidayModel struct wraps the connection pool
+type HolidayModel struct {
+	DB *sql.DB
+}
+
+// Insert new holiday in the database
+func (m HolidayModel) Insert(holiday *Holiday) error {
+	query := `
+		INSERT INTO holidays (holiday_date, name, office_id, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		holiday.Date,
+		holiday.Name,
+		holiday.OfficeID,
+		holiday.CreatedBy,
+		holiday.UpdatedBy,
+	).Scan(&holiday.ID, &holiday.CreatedAt, &holiday.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			if pqErr.Code == "23505" {
+				return ErrDuplicateHoliday
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetAll returns the holidays of every office between two dates (inclusive)
+func (m HolidayModel) GetAll(from, to string) ([]*Holiday, error) {
+	query := `
+		SELECT ` + holidayColumns + `
+		FROM holidays
+		WHERE holiday_date BETWEEN $1 AND $2
+		ORDER BY holiday_date, id`
+
+	return m.query(query, from, to)
+}
+
+// GetForOffice returns the holidays observed by an employee of the given office
+// (nil for no office) between two dates (inclusive): national holidays and
+// those of that office
+func (m HolidayModel) GetForOffice(from, to string, officeID *int64) ([]*Holiday, error) {
+	query := `
+		SELECT ` + holidayColumns + `
+		FROM holidays
+		WHERE holiday_date BETWEEN $1 AND $2
+		AND (office_id IS NULL OR office_id = $3)
+		ORDER BY holiday_date, id`
+
+	return m.query(query, from, to, officeID)
+}
+
+const holidayColumns = `id, holiday_date::text, name, office_id, created_at, updated_at, created_by, updated_by`
+
+// query runs a query selecting the holidayColumns and scans its rows
+func (m HolidayModel) query(query string, args ...any) ([]*Holiday, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := []*Holiday{}
+	for rows.Next() {
+		var holiday Holiday
+		err := rows.Scan(
+			&holiday.ID,
+			&holiday.Date,
+			&holiday.Name,
+			&holiday.OfficeID,
+			&holiday.CreatedAt,
+			&holiday.UpdatedAt,
+			&holiday.CreatedBy,
+			&holiday.UpdatedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, &holiday)
+	}
+
+	return holidays, rows.Err()
+}
+
+// GetOn returns the holiday observed by an employee of the given office (nil for
+// no office) on a date, or ErrRecordNotFound if the date is not a holiday
+func (m HolidayModel) GetOn(date string, officeID *int64) (*Holiday, error) {
+	holidays, err := m.GetForOffice(date, date, officeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(holidays) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return holidays[0], nil
+}
+
+// CountWeekdays counts the holidays falling on a weekday between two dates
+// (inclusive) for an employee of the given office (nil for no office)
+func (m HolidayModel) CountWeekdays(from, to string, officeID *int64) (int, error) {
+	query := `
+		SELECT count(DISTINCT holiday_date)
+		FROM holidays
+		WHERE holiday_date BETWEEN $1 AND $2
+		AND EXTRACT(ISODOW FROM holiday_date) < 6
+		AND (office_id IS NULL OR office_id = $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query, from, to, officeID).Scan(&count)
+	return count, err
+}
+
+// Delete holiday by ID from the database
+func (m HolidayModel) Delete(id int64) error {
+	query := `DELETE FROM holidays WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Job run statuses
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+// A job which has been "running" for longer than this is assumed to have died
+// with its server, and may be started again
+const jobRunStaleAfter = time.Hour
+
+// JobRun struct represents one run of a scheduled job for a given day
+type JobRun struct {
+	ID           int64      `json:"id"`
+	JobName      string     `json:"job_name"`
+	RunDate      string     `json:"run_date"`
+	Status       string     `json:"status"`
+	AffectedRows int        `json:"affected_rows"`
+	Error        *string    `json:"error,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobRunModel struct wraps the connection pool
+type JobRunModel struct {
+	DB *sql.DB
+}
+
+// LastSucceeded returns the latest day (YYYY-MM-DD) the job ran successfully
+// for, or ErrRecordNotFound if it never did
+func (m JobRunModel) LastSucceeded(jobName string) (string, error) {
+	query := `
+		SELECT max(run_date)::text
+		FROM job_runs
+		WHERE job_name = $1 AND status = 'succeeded'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var runDate *string
+	err := m.DB.QueryRowContext(ctx, query, jobName).Scan(&runDate)
+	if err != nil {
+		return "", err
+	}
+	if runDate == nil {
+		return "", ErrRecordNotFound
+	}
+
+	return *runDate, nil
+}
+
+// Start records that the job is running for the day. It returns nil, without
+// starting anything, when the job already succeeded for that day or is being
+// run by another server, so each day is processed once across all instances.
+func (m JobRunModel) Start(jobName, runDate string) (*JobRun, error) {
+	query := `
+		INSERT INTO job_runs (job_name, run_date)
+		VALUES ($1, $2)
+		ON CONFLICT (job_name, run_date) DO UPDATE
+		SET status = 'running', error = NULL, affected_rows = 0, started_at = now(), finished_at = NULL
+		WHERE job_runs.status = 'failed'
+		OR (job_runs.status = 'running' AND job_runs.started_at < now() - $3::interval)
+		RETURNING id, job_name, run_date::text, status, started_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var run JobRun
+	err := m.DB.QueryRowContext(ctx, query, jobName, runDate, jobRunStaleAfter.String()).Scan(
+		&run.ID,
+		&run.JobName,
+		&run.RunDate,
+		&run.Status,
+		&run.StartedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// Finish records the outcome of a job run
+func (m JobRunModel) Finish(run *JobRun, affectedRows int, runErr error) error {
+	run.Status = JobRunSucceeded
+	run.AffectedRows = affectedRows
+	run.Error = nil
+	if runErr != nil {
+		message := runErr.Error()
+		run.Status = JobRunFailed
+		run.Error = &message
+	}
+
+	query := `
+		UPDATE job_runs
+		SET status = $1, affected_rows = $2, error = $3, finished_at = now()
+		WHERE id = $4
+		RETURNING finished_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, run.Status, run.AffectedRows, run.Error, run.ID).Scan(&run.FinishedAt)
+}
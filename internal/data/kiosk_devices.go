@@ -0,0 +1,187 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"time"
+)
+
+// KioskDevice struct represents a shared tablet which displays rotating QR codes
+// at an office so employees can prove they are physically present
+type KioskDevice struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	OfficeID   int64      `json:"office_id"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	CreatedBy  int64      `json:"created_by"`
+	UpdatedBy  int64      `json:"updated_by"`
+}
+
+// KioskDeviceModel struct wraps the connection pool
+type KioskDeviceModel struct {
+	DB *sql.DB
+}
+
+const kioskDeviceColumns = `
+	id, name, office_id, last_seen_at, revoked_at, created_at, updated_at, created_by, updated_by`
+
+func scanKioskDevice(row interface{ Scan(...any) error }, k *KioskDevice) error {
+	return row.Scan(
+		&k.ID,
+		&k.Name,
+		&k.OfficeID,
+		&k.LastSeenAt,
+		&k.RevokedAt,
+		&k.CreatedAt,
+		&k.UpdatedAt,
+		&k.CreatedBy,
+		&k.UpdatedBy,
+	)
+}
+
+// generateSecret returns a random, URL-safe plaintext secret together with its
+// SHA-256 hash, which is what gets stored in the database
+func generateSecret() (string, []byte, error) {
+	randomBytes := make([]byte, 32)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	plaintext := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	hash := sha256.Sum256([]byte(plaintext))
+
+	return plaintext, hash[:], nil
+}
+
+// Insert registers a new kiosk device in the database and returns the plaintext
+// device token. Only its hash is stored, so the token cannot be retrieved later.
+func (m KioskDeviceModel) Insert(kiosk *KioskDevice) (string, error) {
+	token, hash, err := generateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+		INSERT INTO kiosk_devices (name, office_id, token_hash, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query,
+		kiosk.Name,
+		kiosk.OfficeID,
+		hash,
+		kiosk.CreatedBy,
+		kiosk.UpdatedBy,
+	).Scan(&kiosk.ID, &kiosk.CreatedAt, &kiosk.UpdatedAt)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Get kiosk device by ID from the database
+func (m KioskDeviceModel) Get(id int64) (*KioskDevice, error) {
+	query := `SELECT ` + kioskDeviceColumns + ` FROM kiosk_devices WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var kiosk KioskDevice
+	err := scanKioskDevice(m.DB.QueryRowContext(ctx, query, id), &kiosk)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &kiosk, nil
+}
+
+// GetForToken returns the active kiosk device authenticated by the plaintext
+// device token, and records that the device has been seen
+func (m KioskDeviceModel) GetForToken(token string) (*KioskDevice, error) {
+	hash := sha256.Sum256([]byte(token))
+
+	query := `
+		UPDATE kiosk_devices
+		SET last_seen_at = now()
+		WHERE token_hash = $1 AND revoked_at IS NULL
+		RETURNING ` + kioskDeviceColumns
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var kiosk KioskDevice
+	err := scanKioskDevice(m.DB.QueryRowContext(ctx, query, hash[:]), &kiosk)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &kiosk, nil
+}
+
+// GetAll returns every kiosk device, active ones first
+func (m KioskDeviceModel) GetAll() ([]*KioskDevice, error) {
+	query := `SELECT ` + kioskDeviceColumns + `
+		FROM kiosk_devices
+		ORDER BY revoked_at IS NOT NULL, office_id, name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	kiosks := []*KioskDevice{}
+	for rows.Next() {
+		var kiosk KioskDevice
+		if err := scanKioskDevice(rows, &kiosk); err != nil {
+			return nil, err
+		}
+		kiosks = append(kiosks, &kiosk)
+	}
+
+	return kiosks, rows.Err()
+}
+
+// Revoke disables a kiosk device so its token and QR codes are no longer accepted
+func (m KioskDeviceModel) Revoke(kiosk *KioskDevice, revokedBy int64) error {
+	query := `
+		UPDATE kiosk_devices
+		SET revoked_at = COALESCE(revoked_at, now()), updated_by = $1, updated_at = now()
+		WHERE id = $2
+		RETURNING revoked_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, revokedBy, kiosk.ID).Scan(&kiosk.RevokedAt, &kiosk.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	kiosk.UpdatedBy = revokedBy
+	return nil
+}
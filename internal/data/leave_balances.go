@@ -0,0 +1,180 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInsufficientLeaveBalance = errors.New("insufficient leave balance")
+)
+
+// LeaveBalance struct represents the leave entitlement of one employee for one
+// leave type in a calendar year
+type LeaveBalance struct {
+	ID                  int64     `json:"id"`
+	EmployeeID          int64     `json:"employee_id"`
+	LeaveTypeID         int64     `json:"leave_type_id"`
+	Year                int       `json:"year"`
+	AccruedDays         int       `json:"accrued_days"`
+	UsedDays            int       `json:"used_days"`
+	CarriedOverDays     int       `json:"carried_over_days"`
+	CarriedOverUsedDays int       `json:"carried_over_used_days"`
+	CarryOverExpiresOn  *string   `json:"carry_over_expires_on,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// Available returns the number of days which can still be taken on the given
+// date (YYYY-MM-DD). Carried over days only count until they expire.
+func (b *LeaveBalance) Available(date string) int {
+	available := b.AccruedDays - b.UsedDays
+	if b.carryOverValidOn(date) {
+		available += b.CarriedOverDays - b.CarriedOverUsedDays
+	}
+	return available
+}
+
+// carryOverValidOn reports whether carried over days may still be used on the
+// given date (YYYY-MM-DD)
+func (b *LeaveBalance) carryOverValidOn(date string) bool {
+	return b.CarryOverExpiresOn != nil && date <= *b.CarryOverExpiresOn
+}
+
+// LeaveBalanceModel struct wraps the connection pool
+type LeaveBalanceModel struct {
+	DB *sql.DB
+}
+
+const leaveBalanceColumns = `
+	id, employee_id, leave_type_id, year, accrued_days, used_days, carried_over_days,
+	carried_over_used_days, carry_over_expires_on::text, created_at, updated_at`
+
+func scanLeaveBalance(row interface{ Scan(...any) error }, b *LeaveBalance) error {
+	return row.Scan(
+		&b.ID,
+		&b.EmployeeID,
+		&b.LeaveTypeID,
+		&b.Year,
+		&b.AccruedDays,
+		&b.UsedDays,
+		&b.CarriedOverDays,
+		&b.CarriedOverUsedDays,
+		&b.CarryOverExpiresOn,
+		&b.CreatedAt,
+		&b.UpdatedAt,
+	)
+}
+
+// get retrieves the balance row of an employee for a leave type and year
+func (m LeaveBalanceModel) get(employeeID, leaveTypeID int64, year int) (*LeaveBalance, error) {
+	query := `SELECT ` + leaveBalanceColumns + `
+		FROM leave_balances
+		WHERE employee_id = $1 AND leave_type_id = $2 AND year = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var balance LeaveBalance
+	err := scanLeaveBalance(m.DB.QueryRowContext(ctx, query, employeeID, leaveTypeID, year), &balance)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &balance, nil
+}
+
+// GetForYear returns the balance of an employee for a leave type and year. The
+// balance row is created on first access: the year's accrual is granted and any
+// unused days of the previous year are carried over up to the leave type's limit.
+func (m LeaveBalanceModel) GetForYear(employeeID int64, leaveType *LeaveType, year int) (*LeaveBalance, error) {
+	balance, err := m.get(employeeID, leaveType.ID, year)
+	if err == nil || !errors.Is(err, ErrRecordNotFound) {
+		return balance, err
+	}
+
+	// Carry over what was left of the previous year's accrual
+	carriedOver := 0
+	var expiresOn *string
+	previous, err := m.get(employeeID, leaveType.ID, year-1)
+	switch {
+	case err == nil:
+		carriedOver = min(previous.AccruedDays-previous.UsedDays, leaveType.MaxCarryOverDays)
+	case !errors.Is(err, ErrRecordNotFound):
+		return nil, err
+	}
+	if carriedOver > 0 && leaveType.CarryOverExpiryMonths > 0 {
+		expiry := time.Date(year, time.Month(1+leaveType.CarryOverExpiryMonths), 0, 0, 0, 0, 0, time.UTC)
+		date := expiry.Format("2006-01-02")
+		expiresOn = &date
+	} else {
+		carriedOver = 0
+	}
+
+	query := `
+		INSERT INTO leave_balances (employee_id, leave_type_id, year, accrued_days, carried_over_days, carry_over_expires_on)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (employee_id, leave_type_id, year) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Another request may have created the row in the meantime, in which case the
+	// insert is a no-op and we simply read the existing row back
+	_, err = m.DB.ExecContext(ctx, query,
+		employeeID,
+		leaveType.ID,
+		year,
+		leaveType.AnnualAccrualDays,
+		carriedOver,
+		expiresOn)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.get(employeeID, leaveType.ID, year)
+}
+
+// consume deducts days from a balance row inside an existing transaction.
+// Carried over days are used first as long as they have not expired on the
+// given date.
+func (m LeaveBalanceModel) consume(ctx context.Context, tx *sql.Tx, employeeID, leaveTypeID int64, date string, days int) error {
+	query := `SELECT ` + leaveBalanceColumns + `
+		FROM leave_balances
+		WHERE employee_id = $1 AND leave_type_id = $2 AND year = $3
+		FOR UPDATE`
+
+	year := date[:4]
+
+	var balance LeaveBalance
+	err := scanLeaveBalance(tx.QueryRowContext(ctx, query, employeeID, leaveTypeID, year), &balance)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: no balance for %s", ErrInsufficientLeaveBalance, year)
+		}
+		return err
+	}
+
+	if balance.Available(date) < days {
+		return ErrInsufficientLeaveBalance
+	}
+
+	fromCarryOver := 0
+	if balance.carryOverValidOn(date) {
+		fromCarryOver = min(days, balance.CarriedOverDays-balance.CarriedOverUsedDays)
+	}
+
+	query = `
+		UPDATE leave_balances
+		SET used_days = used_days + $1, carried_over_used_days = carried_over_used_days + $2, updated_at = now()
+		WHERE id = $3`
+
+	_, err = tx.ExecContext(ctx, query, days-fromCarryOver, fromCarryOver, balance.ID)
+	return err
+}
@@ -0,0 +1,336 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrLeaveOverlap    = errors.New("leave request overlaps with an existing pending or approved request")
+	ErrLeaveNotPending = errors.New("leave request is no longer pending")
+)
+
+// Leave request statuses
+const (
+	LeaveStatusPending   = "pending"
+	LeaveStatusApproved  = "approved"
+	LeaveStatusRejected  = "rejected"
+	LeaveStatusCancelled = "cancelled"
+)
+
+// LeaveAttachment struct holds a supporting document uploaded with a leave request
+type LeaveAttachment struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+// LeaveRequest struct represents an employee's request for leave
+type LeaveRequest struct {
+	ID            int64            `json:"id"`
+	EmployeeID    int64            `json:"employee_id"`
+	LeaveTypeID   int64            `json:"leave_type_id"`
+	LeaveType     string           `json:"leave_type"`
+	StartDate     string           `json:"start_date"`
+	EndDate       string           `json:"end_date"`
+	Days          int              `json:"days"`
+	Reason        string           `json:"reason"`
+	Status        string           `json:"status"`
+	HasAttachment bool             `json:"has_attachment"`
+	Attachment    *LeaveAttachment `json:"-"`
+	ReviewedBy    *int64           `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time       `json:"reviewed_at,omitempty"`
+	ReviewNote    string           `json:"review_note,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+	CreatedBy     int64            `json:"created_by"`
+	UpdatedBy     int64            `json:"updated_by"`
+}
+
+// LeaveRequestModel struct wraps the connection pool
+type LeaveRequestModel struct {
+	DB *sql.DB
+}
+
+const leaveRequestColumns = `
+	lr.id, lr.employee_id, lr.leave_type_id, lt.code, lr.start_date::text, lr.end_date::text, lr.days,
+	lr.reason, lr.status, lr.attachment IS NOT NULL, lr.reviewed_by, lr.reviewed_at, lr.review_note,
+	lr.created_at, lr.updated_at, lr.created_by, lr.updated_by`
+
+func scanLeaveRequest(row interface{ Scan(...any) error }, lr *LeaveRequest) error {
+	return row.Scan(
+		&lr.ID,
+		&lr.EmployeeID,
+		&lr.LeaveTypeID,
+		&lr.LeaveType,
+		&lr.StartDate,
+		&lr.EndDate,
+		&lr.Days,
+		&lr.Reason,
+		&lr.Status,
+		&lr.HasAttachment,
+		&lr.ReviewedBy,
+		&lr.ReviewedAt,
+		&lr.ReviewNote,
+		&lr.CreatedAt,
+		&lr.UpdatedAt,
+		&lr.CreatedBy,
+		&lr.UpdatedBy,
+	)
+}
+
+// WorkingDays counts the weekdays between two dates (inclusive)
+func WorkingDays(start, end time.Time) int {
+	days := 0
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+			days++
+		}
+	}
+	return days
+}
+
+// Insert new leave request in the database
+func (m LeaveRequestModel) Insert(lr *LeaveRequest) error {
+	query := `
+		INSERT INTO leave_requests (employee_id, leave_type_id, start_date, end_date, days, reason,
+			attachment_name, attachment_content_type, attachment, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, status, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var name, contentType *string
+	var content []byte
+	if lr.Attachment != nil {
+		name = &lr.Attachment.Name
+		contentType = &lr.Attachment.ContentType
+		content = lr.Attachment.Content
+	}
+
+	err := m.DB.QueryRowContext(ctx, query,
+		lr.EmployeeID,
+		lr.LeaveTypeID,
+		lr.StartDate,
+		lr.EndDate,
+		lr.Days,
+		lr.Reason,
+		name,
+		contentType,
+		content,
+		lr.CreatedBy,
+		lr.UpdatedBy,
+	).Scan(&lr.ID, &lr.Status, &lr.CreatedAt, &lr.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			if pqErr.Constraint == "leave_requests_prevent_overlap" {
+				return ErrLeaveOverlap
+			}
+		}
+		return err
+	}
+
+	lr.HasAttachment = lr.Attachment != nil
+	return nil
+}
+
+// Get leave request by ID from the database
+func (m LeaveRequestModel) Get(id int64) (*LeaveRequest, error) {
+	query := `SELECT ` + leaveRequestColumns + `
+		FROM leave_requests lr
+		JOIN leave_types lt ON lt.id = lr.leave_type_id
+		WHERE lr.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var lr LeaveRequest
+	err := scanLeaveRequest(m.DB.QueryRowContext(ctx, query, id), &lr)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &lr, nil
+}
+
+// GetAttachment returns the supporting document of a leave request
+func (m LeaveRequestModel) GetAttachment(id int64) (*LeaveAttachment, error) {
+	query := `
+		SELECT attachment_name, attachment_content_type, attachment
+		FROM leave_requests
+		WHERE id = $1 AND attachment IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var attachment LeaveAttachment
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&attachment.Name,
+		&attachment.ContentType,
+		&attachment.Content,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+// GetAll returns leave requests, optionally filtered by employee (0 for all
+// employees) and status (empty for all statuses), newest first
+func (m LeaveRequestModel) GetAll(employeeID int64, status string) ([]*LeaveRequest, error) {
+	query := `SELECT ` + leaveRequestColumns + `
+		FROM leave_requests lr
+		JOIN leave_types lt ON lt.id = lr.leave_type_id
+		WHERE (lr.employee_id = $1 OR $1 = 0)
+		AND (lr.status::text = $2 OR $2 = '')
+		ORDER BY lr.start_date DESC, lr.id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, employeeID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []*LeaveRequest{}
+	for rows.Next() {
+		var lr LeaveRequest
+		if err := scanLeaveRequest(rows, &lr); err != nil {
+			return nil, err
+		}
+		requests = append(requests, &lr)
+	}
+
+	return requests, rows.Err()
+}
+
+// HasApprovedLeave reports whether the employee has approved leave on the date
+func (m LeaveRequestModel) HasApprovedLeave(employeeID int64, date string) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT 1
+		FROM leave_requests
+		WHERE employee_id = $1
+		AND status = 'approved'
+		AND $2 BETWEEN start_date AND end_date
+	)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, query, employeeID, date).Scan(&exists)
+	return exists, err
+}
+
+// Approve marks a pending leave request as approved. For leave types which track
+// a balance, the requested days are deducted from the employee's balance in the
+// same transaction.
+func (m LeaveRequestModel) Approve(lr *LeaveRequest, leaveType *LeaveType, reviewerID int64, note string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = m.review(ctx, tx, lr, LeaveStatusApproved, reviewerID, note)
+	if err != nil {
+		return err
+	}
+
+	if leaveType.TracksBalance {
+		err = LeaveBalanceModel{DB: m.DB}.consume(ctx, tx, lr.EmployeeID, leaveType.ID, lr.StartDate, lr.Days)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Reject marks a pending leave request as rejected
+func (m LeaveRequestModel) Reject(lr *LeaveRequest, reviewerID int64, note string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = m.review(ctx, tx, lr, LeaveStatusRejected, reviewerID, note)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Cancel lets an employee withdraw their own pending leave request
+func (m LeaveRequestModel) Cancel(lr *LeaveRequest, userID int64) error {
+	query := `
+		UPDATE leave_requests
+		SET status = 'cancelled', updated_by = $1, updated_at = now()
+		WHERE id = $2 AND status = 'pending'
+		RETURNING status, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID, lr.ID).Scan(&lr.Status, &lr.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrLeaveNotPending
+		}
+		return err
+	}
+
+	lr.UpdatedBy = userID
+	return nil
+}
+
+// review moves a pending leave request to its final status
+func (m LeaveRequestModel) review(ctx context.Context, tx *sql.Tx, lr *LeaveRequest, status string, reviewerID int64, note string) error {
+	query := `
+		UPDATE leave_requests
+		SET status = $1, reviewed_by = $2, reviewed_at = now(), review_note = $3,
+			updated_by = $2, updated_at = now()
+		WHERE id = $4 AND status = 'pending'
+		RETURNING status, reviewed_at, updated_at`
+
+	err := tx.QueryRowContext(ctx, query, status, reviewerID, note, lr.ID).Scan(
+		&lr.Status,
+		&lr.ReviewedAt,
+		&lr.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrLeaveNotPending
+		}
+		return err
+	}
+
+	lr.ReviewedBy = &reviewerID
+	lr.ReviewNote = note
+	lr.UpdatedBy = reviewerID
+	return nil
+}
@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// LeaveType struct represents a kind of leave (annual, sick, unpaid, ...)
+type LeaveType struct {
+	ID                    int64     `json:"id"`
+	Code                  string    `json:"code"`
+	Name                  string    `json:"name"`
+	IsPaid                bool      `json:"is_paid"`
+	RequiresAttachment    bool      `json:"requires_attachment"`
+	TracksBalance         bool      `json:"tracks_balance"`
+	AnnualAccrualDays     int       `json:"annual_accrual_days"`
+	MaxCarryOverDays      int       `json:"max_carry_over_days"`
+	CarryOverExpiryMonths int       `json:"carry_over_expiry_months"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// LeaveTypeModel struct wraps the connection pool
+type LeaveTypeModel struct {
+	DB *sql.DB
+}
+
+const leaveTypeColumns = `
+	id, code, name, is_paid, requires_attachment, tracks_balance, annual_accrual_days,
+	max_carry_over_days, carry_over_expiry_months, created_at, updated_at`
+
+func scanLeaveType(row interface{ Scan(...any) error }, t *LeaveType) error {
+	return row.Scan(
+		&t.ID,
+		&t.Code,
+		&t.Name,
+		&t.IsPaid,
+		&t.RequiresAttachment,
+		&t.TracksBalance,
+		&t.AnnualAccrualDays,
+		&t.MaxCarryOverDays,
+		&t.CarryOverExpiryMonths,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+}
+
+// Get leave type by code from the database
+func (m LeaveTypeModel) GetByCode(code string) (*LeaveType, error) {
+	query := `SELECT ` + leaveTypeColumns + ` FROM leave_types WHERE code = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var leaveType LeaveType
+	err := scanLeaveType(m.DB.QueryRowContext(ctx, query, code), &leaveType)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &leaveType, nil
+}
+
+// Get leave type by ID from the database
+func (m LeaveTypeModel) Get(id int64) (*LeaveType, error) {
+	query := `SELECT ` + leaveTypeColumns + ` FROM leave_types WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var leaveType LeaveType
+	err := scanLeaveType(m.DB.QueryRowContext(ctx, query, id), &leaveType)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &leaveType, nil
+}
+
+// GetAll returns every leave type ordered by ID
+func (m LeaveTypeModel) GetAll() ([]*LeaveType, error) {
+	query := `SELECT ` + leaveTypeColumns + ` FROM leave_types ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leaveTypes := []*LeaveType{}
+	for rows.Next() {
+		var leaveType LeaveType
+		if err := scanLeaveType(rows, &leaveType); err != nil {
+			return nil, err
+		}
+		leaveTypes = append(leaveTypes, &leaveType)
+	}
+
+	return leaveTypes, rows.Err()
+}
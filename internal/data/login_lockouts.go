@@ -0,0 +1,208 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Kinds of login lockouts
+const (
+	LockoutAccount = "account"
+	LockoutIP      = "ip"
+)
+
+// Lockout struct holds the failed logins of an account or a client IP address
+type Lockout struct {
+	ID           int64      `json:"id"`
+	Kind         string     `json:"kind"`
+	Key          string     `json:"key"`
+	Failures     int        `json:"failures"`
+	Lockouts     int        `json:"lockouts"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+}
+
+// LockoutPolicy struct sets when failed logins lock logins out, and for how
+// long
+type LockoutPolicy struct {
+	// Failures within the window which lock logins out
+	MaxFailures int
+	Window      time.Duration
+	// Length of the first lockout, doubled by each following one up to the max
+	Lockout    time.Duration
+	MaxLockout time.Duration
+	// Time without failures after which earlier lockouts are forgotten
+	ResetAfter time.Duration
+}
+
+// LockoutModel struct wraps the connection pool
+type LockoutModel struct {
+	DB *sql.DB
+}
+
+const lockoutColumns = `id, kind, key, failures, lockouts, last_failed_at, locked_until`
+
+func scanLockout(row interface{ Scan(...any) error }, l *Lockout, extra ...any) error {
+	dest := []any{
+		&l.ID,
+		&l.Kind,
+		&l.Key,
+		&l.Failures,
+		&l.Lockouts,
+		&l.LastFailedAt,
+		&l.LockedUntil,
+	}
+
+	return row.Scan(append(dest, extra...)...)
+}
+
+// LockedUntil returns when the latest lockout of the account or IP address
+// ends, or nil if logins are not locked out
+func (m LockoutModel) LockedUntil(account, ip string) (*time.Time, error) {
+	query := `
+		SELECT max(locked_until)
+		FROM login_lockouts
+		WHERE ((kind = 'account' AND key = $1) OR (kind = 'ip' AND key = $2))
+		AND locked_until > now()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var lockedUntil *time.Time
+	err := m.DB.QueryRowContext(ctx, query, account, ip).Scan(&lockedUntil)
+	return lockedUntil, err
+}
+
+// RecordFailure counts a failed login of the account or IP address, locking
+// logins out once the policy's limit is reached
+func (m LockoutModel) RecordFailure(kind, key string, policy LockoutPolicy) (*Lockout, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO login_lockouts (kind, key)
+		VALUES ($1, $2)
+		ON CONFLICT (kind, key) DO NOTHING`
+
+	_, err = tx.ExecContext(ctx, query, kind, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lock the row so that concurrent failures are all counted
+	query = `
+		SELECT ` + lockoutColumns + `, now()
+		FROM login_lockouts
+		WHERE kind = $1 AND key = $2
+		FOR UPDATE`
+
+	var l Lockout
+	var now time.Time
+	err = scanLockout(tx.QueryRowContext(ctx, query, kind, key), &l, &now)
+	if err != nil {
+		return nil, err
+	}
+
+	since := now.Sub(l.LastFailedAt)
+	if since > policy.Window {
+		l.Failures = 0
+	}
+	if since > policy.ResetAfter {
+		l.Lockouts = 0
+	}
+
+	l.Failures++
+	l.LastFailedAt = now
+	if l.Failures >= policy.MaxFailures {
+		l.Lockouts++
+		l.Failures = 0
+
+		length := policy.MaxLockout
+		if shift := l.Lockouts - 1; shift < 32 && policy.Lockout<<shift < policy.MaxLockout {
+			length = policy.Lockout << shift
+		}
+		lockedUntil := now.Add(length)
+		l.LockedUntil = &lockedUntil
+	}
+
+	query = `
+		UPDATE login_lockouts
+		SET failures = $1, lockouts = $2, last_failed_at = $3, locked_until = $4
+		WHERE id = $5`
+
+	_, err = tx.ExecContext(ctx, query, l.Failures, l.Lockouts, l.LastFailedAt, l.LockedUntil, l.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &l, tx.Commit()
+}
+
+// Clear forgets the failed logins of the account or IP address
+func (m LockoutModel) Clear(kind, key string) error {
+	query := `DELETE FROM login_lockouts WHERE kind = $1 AND key = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, kind, key)
+	return err
+}
+
+// Delete lifts a lockout by ID and forgets its failed logins
+func (m LockoutModel) Delete(id int64) (*Lockout, error) {
+	query := `DELETE FROM login_lockouts WHERE id = $1 RETURNING ` + lockoutColumns
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var l Lockout
+	err := scanLockout(m.DB.QueryRowContext(ctx, query, id), &l)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// GetAll returns the accounts and IP addresses which are locked out, optionally
+// filtered by kind (empty for both). With all set, those which only have failed
+// logins are included too. Lockouts ending last come first.
+func (m LockoutModel) GetAll(kind string, all bool) ([]*Lockout, error) {
+	query := `
+		SELECT ` + lockoutColumns + `
+		FROM login_lockouts
+		WHERE (kind = $1 OR $1 = '') AND (locked_until > now() OR $2)
+		ORDER BY locked_until DESC NULLS LAST, last_failed_at DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, kind, all)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lockouts := []*Lockout{}
+	for rows.Next() {
+		var l Lockout
+		if err := scanLockout(rows, &l); err != nil {
+			return nil, err
+		}
+		lockouts = append(lockouts, &l)
+	}
+
+	return lockouts, rows.Err()
+}
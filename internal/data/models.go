@@ -6,9 +6,35 @@ import (
 
 // Models contrains all data models used in the application
 type Models struct {
-	Users         UserModel
-	Attendance    AttendanceModel
-	PayrollPeriod PayrollPeriodModel
+	Users          UserModel
+	Attendance     AttendanceModel
+	PayrollPeriod  PayrollPeriodModel
+	LeaveTypes     LeaveTypeModel
+	LeaveBalances  LeaveBalanceModel
+	LeaveRequests  LeaveRequestModel
+	Offices        OfficeModel
+	Holidays       HolidayModel
+	KioskDevices   KioskDeviceModel
+	SyncEvents     SyncEventModel
+	JobRuns        JobRunModel
+	Notifications  NotificationModel
+	DeviceUsers    DeviceUserMappingModel
+	AttTypes       AttendanceTypeModel
+	WFHQuotas      WFHQuotaModel
+	CostCenters    CostCenterModel
+	Projects       ProjectModel
+	Timesheets     TimesheetModel
+	Shifts         ShiftModel
+	Roster         RosterModel
+	ShiftSwaps     ShiftSwapModel
+	CalendarFeeds  CalendarFeedModel
+	ProfileChanges ProfileChangeModel
+	Tokens         TokenModel
+	Sessions       SessionModel
+	Lockouts       LockoutModel
+	TwoFactor      TwoFactorModel
+	SigningKeys    SigningKeyModel
+	OIDCLogins     OIDCLoginModel
 	// To be added:
 	// Payrolls    PayrollModel
 	// dll.
@@ -17,8 +43,34 @@ type Models struct {
 // Initialize all models with DB connection
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Users:         UserModel{DB: db},
-		Attendance:    AttendanceModel{DB: db},
-		PayrollPeriod: PayrollPeriodModel{DB: db},
+		Users:          UserModel{DB: db},
+		Attendance:     AttendanceModel{DB: db},
+		PayrollPeriod:  PayrollPeriodModel{DB: db},
+		LeaveTypes:     LeaveTypeModel{DB: db},
+		LeaveBalances:  LeaveBalanceModel{DB: db},
+		LeaveRequests:  LeaveRequestModel{DB: db},
+		Offices:        OfficeModel{DB: db},
+		Holidays:       HolidayModel{DB: db},
+		KioskDevices:   KioskDeviceModel{DB: db},
+		SyncEvents:     SyncEventModel{DB: db},
+		JobRuns:        JobRunModel{DB: db},
+		Notifications:  NotificationModel{DB: db},
+		DeviceUsers:    DeviceUserMappingModel{DB: db},
+		AttTypes:       AttendanceTypeModel{DB: db},
+		WFHQuotas:      WFHQuotaModel{DB: db},
+		CostCenters:    CostCenterModel{DB: db},
+		Projects:       ProjectModel{DB: db},
+		Timesheets:     TimesheetModel{DB: db},
+		Shifts:         ShiftModel{DB: db},
+		Roster:         RosterModel{DB: db},
+		ShiftSwaps:     ShiftSwapModel{DB: db},
+		CalendarFeeds:  CalendarFeedModel{DB: db},
+		ProfileChanges: ProfileChangeModel{DB: db},
+		Tokens:         TokenModel{DB: db},
+		Sessions:       SessionModel{DB: db},
+		Lockouts:       LockoutModel{DB: db},
+		TwoFactor:      TwoFactorModel{DB: db},
+		SigningKeys:    SigningKeyModel{DB: db},
+		OIDCLogins:     OIDCLoginModel{DB: db},
 	}
 }
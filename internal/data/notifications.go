@@ -0,0 +1,99 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Notification struct represents an in-app message to a user
+type Notification struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Kind      string     `json:"kind"`
+	Title     string     `json:"title"`
+	Message   string     `json:"message"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NotificationModel struct wraps the connection pool
+type NotificationModel struct {
+	DB *sql.DB
+}
+
+// Insert new notification in the database
+func (m NotificationModel) Insert(n *Notification) error {
+	query := `
+		INSERT INTO notifications (user_id, kind, title, message)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, n.UserID, n.Kind, n.Title, n.Message).Scan(&n.ID, &n.CreatedAt)
+}
+
+// GetAllForUser returns the latest notifications of a user, newest first
+func (m NotificationModel) GetAllForUser(userID int64, unreadOnly bool, limit int) ([]*Notification, error) {
+	query := `
+		SELECT id, user_id, kind, title, message, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND (read_at IS NULL OR NOT $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, unreadOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*Notification{}
+	for rows.Next() {
+		var n Notification
+		err := rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.Title, &n.Message, &n.ReadAt, &n.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, &n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// MarkRead marks a notification of the user as read
+func (m NotificationModel) MarkRead(id, userID int64) (*Notification, error) {
+	query := `
+		UPDATE notifications
+		SET read_at = COALESCE(read_at, now())
+		WHERE id = $1 AND user_id = $2
+		RETURNING id, user_id, kind, title, message, read_at, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var n Notification
+	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&n.ID,
+		&n.UserID,
+		&n.Kind,
+		&n.Title,
+		&n.Message,
+		&n.ReadAt,
+		&n.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &n, nil
+}
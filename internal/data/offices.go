@@ -0,0 +1,194 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateOfficeName = errors.New("an office with this name already exists")
+)
+
+// Office struct represents an office location employees are assigned to. When
+// Latitude, Longitude and RadiusM are set, check-ins must happen within RadiusM
+// meters of the office, and KioskRequired makes employees scan a kiosk QR code.
+type Office struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Timezone      string    `json:"timezone"`
+	Latitude      *float64  `json:"latitude,omitempty"`
+	Longitude     *float64  `json:"longitude,omitempty"`
+	RadiusM       *int      `json:"radius_m,omitempty"`
+	KioskRequired bool      `json:"kiosk_required"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	CreatedBy     int64     `json:"created_by"`
+	UpdatedBy     int64     `json:"updated_by"`
+}
+
+// Location returns the office's time zone
+func (o *Office) Location() (*time.Location, error) {
+	return time.LoadLocation(o.Timezone)
+}
+
+// HasGeofence reports whether check-ins at the office are restricted by location
+func (o *Office) HasGeofence() bool {
+	return o.Latitude != nil && o.Longitude != nil && o.RadiusM != nil
+}
+
+// DistanceM returns the great-circle distance in meters between the office and
+// the given coordinates, using the haversine formula
+func (o *Office) DistanceM(latitude, longitude float64) float64 {
+	const earthRadiusM = 6371000
+
+	lat1 := *o.Latitude * math.Pi / 180
+	lat2 := latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLng := (longitude - *o.Longitude) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusM * math.Asin(math.Sqrt(a))
+}
+
+// OfficeModel struct wraps the connection pool
+type OfficeModel struct {
+	DB *sql.DB
+}
+
+const officeColumns = `id, name, timezone, latitude, longitude, radius_m, kiosk_required, created_at, updated_at, created_by, updated_by`
+
+func scanOffice(row interface{ Scan(...any) error }, o *Office) error {
+	return row.Scan(
+		&o.ID,
+		&o.Name,
+		&o.Timezone,
+		&o.Latitude,
+		&o.Longitude,
+		&o.RadiusM,
+		&o.KioskRequired,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+		&o.CreatedBy,
+		&o.UpdatedBy,
+	)
+}
+
+// Insert new office in the database
+func (m OfficeModel) Insert(office *Office) error {
+	query := `
+		INSERT INTO offices (name, timezone, latitude, longitude, radius_m, kiosk_required, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		office.Name,
+		office.Timezone,
+		office.Latitude,
+		office.Longitude,
+		office.RadiusM,
+		office.KioskRequired,
+		office.CreatedBy,
+		office.UpdatedBy,
+	).Scan(&office.ID, &office.CreatedAt, &office.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			if pqErr.Code == "23505" {
+				return ErrDuplicateOfficeName
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Get office by ID from the database
+func (m OfficeModel) Get(id int64) (*Office, error) {
+	query := `SELECT ` + officeColumns + ` FROM offices WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var office Office
+	err := scanOffice(m.DB.QueryRowContext(ctx, query, id), &office)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &office, nil
+}
+
+// GetAll returns every office ordered by name
+func (m OfficeModel) GetAll() ([]*Office, error) {
+	query := `SELECT ` + officeColumns + ` FROM offices ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	offices := []*Office{}
+	for rows.Next() {
+		var office Office
+		if err := scanOffice(rows, &office); err != nil {
+			return nil, err
+		}
+		offices = append(offices, &office)
+	}
+
+	return offices, rows.Err()
+}
+
+// Update office details in the database
+func (m OfficeModel) Update(office *Office) error {
+	query := `
+		UPDATE offices
+		SET name = $1, timezone = $2, latitude = $3, longitude = $4, radius_m = $5,
+			kiosk_required = $6, updated_by = $7, updated_at = now()
+		WHERE id = $8
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		office.Name,
+		office.Timezone,
+		office.Latitude,
+		office.Longitude,
+		office.RadiusM,
+		office.KioskRequired,
+		office.UpdatedBy,
+		office.ID,
+	).Scan(&office.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateOfficeName
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// OIDCLogin struct represents a single sign-on login in progress. The client
+// only gets the state, which the identity provider sends back along with the
+// authorization code.
+type OIDCLogin struct {
+	State        string
+	CodeVerifier string
+	Nonce        string
+	Expiry       time.Time
+}
+
+// OIDCLoginModel struct wraps the connection pool
+type OIDCLoginModel struct {
+	DB *sql.DB
+}
+
+// New starts a login with the PKCE code verifier and nonce, valid for ttl, and
+// stores it in the database. Expired logins are deleted.
+func (m OIDCLoginModel) New(codeVerifier, nonce string, ttl time.Duration) (*OIDCLogin, error) {
+	state, hash, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	login := &OIDCLogin{
+		State:        state,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		Expiry:       time.Now().Add(ttl),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, `DELETE FROM oidc_logins WHERE expiry < now()`)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO oidc_logins (state_hash, code_verifier, nonce, expiry)
+		VALUES ($1, $2, $3, $4)`
+
+	_, err = m.DB.ExecContext(ctx, query, hash, login.CodeVerifier, login.Nonce, login.Expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return login, nil
+}
+
+// Consume deletes the unexpired login with the state and returns it, so that
+// an authorization code can only be redeemed once for it. It returns
+// ErrRecordNotFound if there is no such login.
+func (m OIDCLoginModel) Consume(state string) (*OIDCLogin, error) {
+	hash := sha256.Sum256([]byte(state))
+
+	query := `
+		DELETE FROM oidc_logins
+		WHERE state_hash = $1 AND expiry > now()
+		RETURNING code_verifier, nonce, expiry`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	login := OIDCLogin{State: state}
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(&login.CodeVerifier, &login.Nonce, &login.Expiry)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &login, nil
+}
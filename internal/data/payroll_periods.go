@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 var (
 	ErrPayrollPeriodOverlap   = errors.New("overlapping date with existing period")
 	ErrPayrollPeriodDateOrder = errors.New("start date is greater than end date")
+	ErrPayrollPeriodProcessed = errors.New("payroll period has already been processed")
 )
 
 // Payroll struct represents attendance data of one date
@@ -20,9 +22,12 @@ type PayrollPeriod struct {
 	StartDate string `json:"start_date"`
 	EndDate   string `json:"end_date"`
 	Status    string `json:"status"`
+	// Date the salaries of the period are paid out, if scheduled
+	PayDate *string `json:"pay_date"`
 
-	ProcessedAt time.Time `json:"processed_at"`
-	ProcessedBy time.Time `json:"processed_by"`
+	// Set once the period is processed, which locks its attendance
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+	ProcessedBy *int64     `json:"processed_by,omitempty"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -69,15 +74,15 @@ func (m PayrollPeriodModel) Insert(p PayrollPeriod) error {
 	}
 
 	query := `
-    INSERT INTO payroll_periods (start_date, end_date, created_by, updated_by)
-    VALUES ($1, $2, $3, $4)
+    INSERT INTO payroll_periods (start_date, end_date, pay_date, created_by, updated_by)
+    VALUES ($1, $2, $3, $4, $5)
     `
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	// Insert new payroll period
-	_, err := m.DB.ExecContext(ctx, query, p.StartDate, p.EndDate, p.CreatedBy, p.UpdatedBy)
+	_, err := m.DB.ExecContext(ctx, query, p.StartDate, p.EndDate, p.PayDate, p.CreatedBy, p.UpdatedBy)
 	if err != nil {
 		var pqErr *pq.Error
 		if errors.As(err, &pqErr) {
@@ -92,3 +97,236 @@ func (m PayrollPeriodModel) Insert(p PayrollPeriod) error {
 	}
 	return nil
 }
+
+// Get payroll period by ID from the database
+func (m PayrollPeriodModel) Get(id int64) (*PayrollPeriod, error) {
+	query := `
+		SELECT id, start_date::text, end_date::text, status, pay_date::text, processed_at, processed_by,
+			created_at, updated_at, created_by, updated_by
+		FROM payroll_periods
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var p PayrollPeriod
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&p.ID,
+		&p.StartDate,
+		&p.EndDate,
+		&p.Status,
+		&p.PayDate,
+		&p.ProcessedAt,
+		&p.ProcessedBy,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+		&p.CreatedBy,
+		&p.UpdatedBy,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// SetPayDate schedules the date the salaries of the period are paid out (nil
+// to unschedule) in the database
+func (m PayrollPeriodModel) SetPayDate(p *PayrollPeriod, payDate *string, updatedBy int64) error {
+	query := `
+		UPDATE payroll_periods
+		SET pay_date = $1, updated_by = $2, updated_at = now()
+		WHERE id = $3
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, payDate, updatedBy, p.ID).Scan(&p.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	p.PayDate = payDate
+	p.UpdatedBy = updatedBy
+	return nil
+}
+
+// Process marks the draft period as processed by the admin in the database,
+// which locks the attendance of its dates. It returns ErrPayrollPeriodProcessed
+// if the period was already processed.
+func (m PayrollPeriodModel) Process(p *PayrollPeriod, processedBy int64) error {
+	query := `
+		UPDATE payroll_periods
+		SET status = 'processed', processed_at = now(), processed_by = $1, updated_by = $1, updated_at = now()
+		WHERE id = $2 AND status = 'draft'
+		RETURNING status, processed_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, processedBy, p.ID).Scan(&p.Status, &p.ProcessedAt, &p.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrPayrollPeriodProcessed
+		}
+		return err
+	}
+
+	p.ProcessedBy = &processedBy
+	p.UpdatedBy = processedBy
+	return nil
+}
+
+// PayrollInput struct holds the attendance figures of one employee which feed
+// into payroll for a period. Present days are broken down by attendance type,
+// and the meal allowance sums the allowance of the type of each present day.
+// Rostered on-call shifts are paid with the allowance of their shift.
+type PayrollInput struct {
+	EmployeeID      int64          `json:"employee_id"`
+	Name            string         `json:"name"`
+	Salary          int64          `json:"salary"`
+	WorkingDays     int            `json:"working_days"`
+	PresentDays     int            `json:"present_days"`
+	PresentByType   map[string]int `json:"present_days_by_type"`
+	PaidLeaveDays   int            `json:"paid_leave_days"`
+	UnpaidLeaveDays int            `json:"unpaid_leave_days"`
+	AttendedDays    int            `json:"attended_days"`
+	AbsentDays      int            `json:"absent_days"`
+	MealAllowance   int64          `json:"meal_allowance"`
+	OnCallShifts    int            `json:"on_call_shifts"`
+	OnCallAllowance int64          `json:"on_call_allowance"`
+}
+
+// Inputs computes the payroll inputs of every active employee for the period.
+// Paid leave counts as attended days, while unpaid leave counts as absences.
+func (m PayrollPeriodModel) Inputs(p *PayrollPeriod) ([]*PayrollInput, error) {
+	query := `
+	WITH days AS (
+		SELECT d::date AS day
+		FROM generate_series($1::date, $2::date, interval '1 day') d
+		WHERE EXTRACT(ISODOW FROM d) < 6
+	),
+	workdays AS (
+		-- Weekdays which are not a holiday for the employee's office
+		SELECT u.id AS employee_id, d.day
+		FROM users u
+		CROSS JOIN days d
+		WHERE NOT EXISTS (
+			SELECT 1 FROM holidays h
+			WHERE h.holiday_date = d.day
+			AND (h.office_id IS NULL OR h.office_id = u.office_id)
+		)
+	)
+	SELECT u.id, u.name, u.salary,
+		(SELECT count(*) FROM workdays wd WHERE wd.employee_id = u.id),
+		(SELECT count(*) FROM attendance a
+			JOIN workdays wd ON wd.employee_id = a.employee_id AND wd.day = a.att_date
+			WHERE a.employee_id = u.id),
+		(SELECT COALESCE(jsonb_object_agg(t.attendance_type, t.days), '{}') FROM (
+			SELECT a.attendance_type, count(*) AS days FROM attendance a
+			JOIN workdays wd ON wd.employee_id = a.employee_id AND wd.day = a.att_date
+			WHERE a.employee_id = u.id
+			GROUP BY a.attendance_type) t),
+		(SELECT COALESCE(sum(typ.meal_allowance), 0) FROM attendance a
+			JOIN workdays wd ON wd.employee_id = a.employee_id AND wd.day = a.att_date
+			JOIN attendance_types typ ON typ.code = a.attendance_type
+			WHERE a.employee_id = u.id),
+		(SELECT count(DISTINCT wd.day) FROM leave_requests lr
+			JOIN leave_types lt ON lt.id = lr.leave_type_id
+			JOIN workdays wd ON wd.employee_id = lr.employee_id AND wd.day BETWEEN lr.start_date AND lr.end_date
+			WHERE lr.employee_id = u.id AND lr.status = 'approved' AND lt.is_paid),
+		(SELECT count(DISTINCT wd.day) FROM leave_requests lr
+			JOIN leave_types lt ON lt.id = lr.leave_type_id
+			JOIN workdays wd ON wd.employee_id = lr.employee_id AND wd.day BETWEEN lr.start_date AND lr.end_date
+			WHERE lr.employee_id = u.id AND lr.status = 'approved' AND NOT lt.is_paid),
+		-- A workday counts once whether it was attended, on paid leave, or both
+		-- as leave can be approved retroactively for a day the employee attended
+		(SELECT count(*) FROM workdays wd
+			WHERE wd.employee_id = u.id
+			AND (EXISTS (
+				SELECT 1 FROM attendance a
+				WHERE a.employee_id = wd.employee_id AND a.att_date = wd.day
+			) OR EXISTS (
+				SELECT 1 FROM leave_requests lr
+				JOIN leave_types lt ON lt.id = lr.leave_type_id
+				WHERE lr.employee_id = wd.employee_id AND lr.status = 'approved' AND lt.is_paid
+				AND wd.day BETWEEN lr.start_date AND lr.end_date
+			))),
+		(SELECT count(*) FROM roster_entries re
+			JOIN shifts s ON s.id = re.shift_id
+			WHERE re.employee_id = u.id AND s.on_call AND re.shift_date BETWEEN $1 AND $2),
+		(SELECT COALESCE(sum(s.allowance), 0) FROM roster_entries re
+			JOIN shifts s ON s.id = re.shift_id
+			WHERE re.employee_id = u.id AND s.on_call AND re.shift_date BETWEEN $1 AND $2)
+	FROM users u
+	WHERE u.role = 'employee' AND u.active
+	ORDER BY u.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, p.StartDate, p.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inputs := []*PayrollInput{}
+	for rows.Next() {
+		var in PayrollInput
+		var presentByType []byte
+		err := rows.Scan(
+			&in.EmployeeID,
+			&in.Name,
+			&in.Salary,
+			&in.WorkingDays,
+			&in.PresentDays,
+			&presentByType,
+			&in.MealAllowance,
+			&in.PaidLeaveDays,
+			&in.UnpaidLeaveDays,
+			&in.AttendedDays,
+			&in.OnCallShifts,
+			&in.OnCallAllowance,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(presentByType, &in.PresentByType)
+		if err != nil {
+			return nil, err
+		}
+
+		in.AbsentDays = in.WorkingDays - in.AttendedDays
+		inputs = append(inputs, &in)
+	}
+
+	return inputs, rows.Err()
+}
+
+// IsLocked reports whether the date (YYYY-MM-DD) belongs to a payroll period
+// which has already been processed, so its attendance can no longer change
+func (m PayrollPeriodModel) IsLocked(date string) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT 1
+		FROM payroll_periods
+		WHERE status = 'processed'
+		AND $1 BETWEEN start_date AND end_date
+	)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var locked bool
+	err := m.DB.QueryRowContext(ctx, query, date).Scan(&locked)
+	return locked, err
+}
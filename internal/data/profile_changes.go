@@ -0,0 +1,272 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrProfileChangeExists     = errors.New("a profile change request is already pending")
+	ErrProfileChangeNotPending = errors.New("profile change request is no longer pending")
+)
+
+// Profile change request statuses
+const (
+	ProfileChangePending   = "pending"
+	ProfileChangeApproved  = "approved"
+	ProfileChangeRejected  = "rejected"
+	ProfileChangeCancelled = "cancelled"
+)
+
+// ProfileChange struct represents a user's request to change the sensitive
+// fields of their profile: their name and bank account. Nil fields are left
+// unchanged. The changes are applied to the user once an admin approves them.
+type ProfileChange struct {
+	ID                int64      `json:"id"`
+	UserID            int64      `json:"user_id"`
+	Name              *string    `json:"name,omitempty"`
+	BankName          *string    `json:"bank_name,omitempty"`
+	BankAccountNumber *string    `json:"bank_account_number,omitempty"`
+	BankAccountHolder *string    `json:"bank_account_holder,omitempty"`
+	Status            string     `json:"status"`
+	ReviewedBy        *int64     `json:"reviewed_by,omitempty"`
+	ReviewedAt        *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote        string     `json:"review_note,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// ProfileChangeModel struct wraps the connection pool
+type ProfileChangeModel struct {
+	DB *sql.DB
+}
+
+const profileChangeColumns = `id, user_id, name, bank_name, bank_account_number, bank_account_holder, status,
+	reviewed_by, reviewed_at, review_note, created_at, updated_at`
+
+func scanProfileChange(row interface{ Scan(...any) error }, pc *ProfileChange) error {
+	return row.Scan(
+		&pc.ID,
+		&pc.UserID,
+		&pc.Name,
+		&pc.BankName,
+		&pc.BankAccountNumber,
+		&pc.BankAccountHolder,
+		&pc.Status,
+		&pc.ReviewedBy,
+		&pc.ReviewedAt,
+		&pc.ReviewNote,
+		&pc.CreatedAt,
+		&pc.UpdatedAt,
+	)
+}
+
+// Insert new profile change request in the database
+func (m ProfileChangeModel) Insert(pc *ProfileChange) error {
+	query := `
+		INSERT INTO profile_change_requests (user_id, name, bank_name, bank_account_number, bank_account_holder)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		pc.UserID,
+		pc.Name,
+		pc.BankName,
+		pc.BankAccountNumber,
+		pc.BankAccountHolder,
+	).Scan(&pc.ID, &pc.Status, &pc.CreatedAt, &pc.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrProfileChangeExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Get profile change request by ID from the database
+func (m ProfileChangeModel) Get(id int64) (*ProfileChange, error) {
+	query := `SELECT ` + profileChangeColumns + ` FROM profile_change_requests WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var pc ProfileChange
+	err := scanProfileChange(m.DB.QueryRowContext(ctx, query, id), &pc)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &pc, nil
+}
+
+// GetPending returns the pending profile change request of the user
+func (m ProfileChangeModel) GetPending(userID int64) (*ProfileChange, error) {
+	query := `
+		SELECT ` + profileChangeColumns + `
+		FROM profile_change_requests
+		WHERE user_id = $1 AND status = 'pending'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var pc ProfileChange
+	err := scanProfileChange(m.DB.QueryRowContext(ctx, query, userID), &pc)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &pc, nil
+}
+
+// GetAll returns profile change requests, optionally filtered by user (0 for
+// all users) and status (empty for all statuses), newest first
+func (m ProfileChangeModel) GetAll(userID int64, status string) ([]*ProfileChange, error) {
+	query := `
+		SELECT ` + profileChangeColumns + `
+		FROM profile_change_requests
+		WHERE (user_id = $1 OR $1 = 0) AND (status::text = $2 OR $2 = '')
+		ORDER BY created_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []*ProfileChange{}
+	for rows.Next() {
+		var pc ProfileChange
+		if err := scanProfileChange(rows, &pc); err != nil {
+			return nil, err
+		}
+		changes = append(changes, &pc)
+	}
+
+	return changes, rows.Err()
+}
+
+// Approve marks a pending profile change request as approved and applies the
+// changes to the user in the same transaction
+func (m ProfileChangeModel) Approve(pc *ProfileChange, reviewerID int64, note string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = m.review(ctx, tx, pc, ProfileChangeApproved, reviewerID, note)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE users
+		SET name = COALESCE($1, name),
+			bank_name = COALESCE($2, bank_name),
+			bank_account_number = COALESCE($3, bank_account_number),
+			bank_account_holder = COALESCE($4, bank_account_holder),
+			updated_by = $5, updated_at = now()
+		WHERE id = $6`
+
+	_, err = tx.ExecContext(ctx, query,
+		pc.Name,
+		pc.BankName,
+		pc.BankAccountNumber,
+		pc.BankAccountHolder,
+		reviewerID,
+		pc.UserID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reject marks a pending profile change request as rejected
+func (m ProfileChangeModel) Reject(pc *ProfileChange, reviewerID int64, note string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = m.review(ctx, tx, pc, ProfileChangeRejected, reviewerID, note)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Cancel lets a user withdraw their own pending profile change request
+func (m ProfileChangeModel) Cancel(pc *ProfileChange) error {
+	query := `
+		UPDATE profile_change_requests
+		SET status = 'cancelled', updated_at = now()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING status, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, pc.ID).Scan(&pc.Status, &pc.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrProfileChangeNotPending
+		}
+		return err
+	}
+
+	return nil
+}
+
+// review moves a pending profile change request to its final status
+func (m ProfileChangeModel) review(ctx context.Context, tx *sql.Tx, pc *ProfileChange, status string, reviewerID int64, note string) error {
+	query := `
+		UPDATE profile_change_requests
+		SET status = $1, reviewed_by = $2, reviewed_at = now(), review_note = $3, updated_at = now()
+		WHERE id = $4 AND status = 'pending'
+		RETURNING status, reviewed_at, updated_at`
+
+	err := tx.QueryRowContext(ctx, query, status, reviewerID, note, pc.ID).Scan(
+		&pc.Status,
+		&pc.ReviewedAt,
+		&pc.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrProfileChangeNotPending
+		}
+		return err
+	}
+
+	pc.ReviewedBy = &reviewerID
+	pc.ReviewNote = note
+	return nil
+}
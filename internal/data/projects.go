@@ -0,0 +1,246 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateCostCenterCode = errors.New("a cost center with this code already exists")
+	ErrDuplicateProjectCode    = errors.New("a project with this code already exists")
+)
+
+// CostCenter struct represents an accounting unit which projects are billed to
+type CostCenter struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy int64     `json:"created_by"`
+}
+
+// CostCenterModel struct wraps the connection pool
+type CostCenterModel struct {
+	DB *sql.DB
+}
+
+// Insert new cost center in the database
+func (m CostCenterModel) Insert(c *CostCenter) error {
+	query := `
+		INSERT INTO cost_centers (code, name, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, c.Code, c.Name, c.CreatedBy).Scan(&c.ID, &c.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrDuplicateCostCenterCode
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Get cost center by ID from the database
+func (m CostCenterModel) Get(id int64) (*CostCenter, error) {
+	query := `SELECT id, code, name, created_at, created_by FROM cost_centers WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var c CostCenter
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&c.ID, &c.Code, &c.Name, &c.CreatedAt, &c.CreatedBy)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// GetAll returns every cost center ordered by code
+func (m CostCenterModel) GetAll() ([]*CostCenter, error) {
+	query := `SELECT id, code, name, created_at, created_by FROM cost_centers ORDER BY code`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	costCenters := []*CostCenter{}
+	for rows.Next() {
+		var c CostCenter
+		if err := rows.Scan(&c.ID, &c.Code, &c.Name, &c.CreatedAt, &c.CreatedBy); err != nil {
+			return nil, err
+		}
+		costCenters = append(costCenters, &c)
+	}
+
+	return costCenters, rows.Err()
+}
+
+// Project struct represents a client project employees allocate worked hours
+// to. Only active projects accept new timesheet entries.
+type Project struct {
+	ID           int64     `json:"id"`
+	Code         string    `json:"code"`
+	Name         string    `json:"name"`
+	Client       *string   `json:"client,omitempty"`
+	CostCenterID int64     `json:"cost_center_id"`
+	Active       bool      `json:"active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	CreatedBy    int64     `json:"created_by"`
+	UpdatedBy    int64     `json:"updated_by"`
+}
+
+// ProjectModel struct wraps the connection pool
+type ProjectModel struct {
+	DB *sql.DB
+}
+
+const projectColumns = `id, code, name, client, cost_center_id, active, created_at, updated_at, created_by, updated_by`
+
+func scanProject(row interface{ Scan(...any) error }, p *Project) error {
+	return row.Scan(
+		&p.ID,
+		&p.Code,
+		&p.Name,
+		&p.Client,
+		&p.CostCenterID,
+		&p.Active,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+		&p.CreatedBy,
+		&p.UpdatedBy,
+	)
+}
+
+// Insert new project in the database
+func (m ProjectModel) Insert(p *Project) error {
+	query := `
+		INSERT INTO projects (code, name, client, cost_center_id, active, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		p.Code,
+		p.Name,
+		p.Client,
+		p.CostCenterID,
+		p.Active,
+		p.CreatedBy,
+		p.UpdatedBy,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrDuplicateProjectCode
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Get project by ID from the database
+func (m ProjectModel) Get(id int64) (*Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var p Project
+	err := scanProject(m.DB.QueryRowContext(ctx, query, id), &p)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// GetAll returns the projects ordered by code, only the active ones if
+// activeOnly is true
+func (m ProjectModel) GetAll(activeOnly bool) ([]*Project, error) {
+	query := `
+		SELECT ` + projectColumns + `
+		FROM projects
+		WHERE active OR NOT $1
+		ORDER BY code`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []*Project{}
+	for rows.Next() {
+		var p Project
+		if err := scanProject(rows, &p); err != nil {
+			return nil, err
+		}
+		projects = append(projects, &p)
+	}
+
+	return projects, rows.Err()
+}
+
+// Update project details in the database
+func (m ProjectModel) Update(p *Project) error {
+	query := `
+		UPDATE projects
+		SET code = $1, name = $2, client = $3, cost_center_id = $4, active = $5,
+			updated_by = $6, updated_at = now()
+		WHERE id = $7
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		p.Code,
+		p.Name,
+		p.Client,
+		p.CostCenterID,
+		p.Active,
+		p.UpdatedBy,
+		p.ID,
+	).Scan(&p.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateProjectCode
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	return nil
+}
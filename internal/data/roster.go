@@ -0,0 +1,295 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrRosterOverlap = errors.New("employee is already rostered on an overlapping shift")
+)
+
+// Kinds of roster conflicts
+const (
+	ConflictDoubleBooked = "double_booked"
+	ConflictRestPeriod   = "rest_period"
+)
+
+// RosterEntry struct represents a shift assigned to an employee on a date. The
+// start and end are resolved in the time zone of the employee's office.
+type RosterEntry struct {
+	ID         int64     `json:"id"`
+	EmployeeID int64     `json:"employee_id"`
+	ShiftID    int64     `json:"shift_id"`
+	ShiftCode  string    `json:"shift_code"`
+	OnCall     bool      `json:"on_call"`
+	ShiftDate  string    `json:"shift_date"`
+	StartsAt   time.Time `json:"starts_at"`
+	EndsAt     time.Time `json:"ends_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	CreatedBy  int64     `json:"created_by"`
+	UpdatedBy  int64     `json:"updated_by"`
+}
+
+// RosterConflict struct describes why a shift cannot be rostered to an
+// employee: it overlaps another of their shifts (double_booked), or leaves them
+// less than the minimum rest between two working shifts (rest_period)
+type RosterConflict struct {
+	Kind    string       `json:"kind"`
+	Entry   *RosterEntry `json:"entry"`
+	Message string       `json:"message"`
+}
+
+// RosterModel struct wraps the connection pool
+type RosterModel struct {
+	DB *sql.DB
+}
+
+const rosterEntryColumns = `re.id, re.employee_id, re.shift_id, s.code, s.on_call, re.shift_date::text,
+	re.starts_at, re.ends_at, re.created_at, re.updated_at, re.created_by, re.updated_by`
+
+func scanRosterEntry(row interface{ Scan(...any) error }, e *RosterEntry) error {
+	return row.Scan(
+		&e.ID,
+		&e.EmployeeID,
+		&e.ShiftID,
+		&e.ShiftCode,
+		&e.OnCall,
+		&e.ShiftDate,
+		&e.StartsAt,
+		&e.EndsAt,
+		&e.CreatedAt,
+		&e.UpdatedAt,
+		&e.CreatedBy,
+		&e.UpdatedBy,
+	)
+}
+
+// Insert rosters the shift to the employee unless it conflicts with their other
+// shifts, in which case nothing is inserted and the conflicts are returned.
+// The entry's ShiftCode and OnCall must be set from its shift.
+func (m RosterModel) Insert(e *RosterEntry, minRest time.Duration) ([]*RosterConflict, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = lockRosters(ctx, tx, e.EmployeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := findConflicts(ctx, tx, e, e.EmployeeID, minRest)
+	if err != nil || len(conflicts) > 0 {
+		return conflicts, err
+	}
+
+	query := `
+		INSERT INTO roster_entries (employee_id, shift_id, shift_date, starts_at, ends_at, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at`
+
+	err = tx.QueryRowContext(ctx, query,
+		e.EmployeeID,
+		e.ShiftID,
+		e.ShiftDate,
+		e.StartsAt,
+		e.EndsAt,
+		e.CreatedBy,
+		e.UpdatedBy,
+	).Scan(&e.ID, &e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23P01" {
+			return nil, ErrRosterOverlap
+		}
+		return nil, err
+	}
+
+	return nil, tx.Commit()
+}
+
+// Get roster entry by ID from the database
+func (m RosterModel) Get(id int64) (*RosterEntry, error) {
+	query := `
+		SELECT ` + rosterEntryColumns + `
+		FROM roster_entries re
+		JOIN shifts s ON s.id = re.shift_id
+		WHERE re.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var e RosterEntry
+	err := scanRosterEntry(m.DB.QueryRowContext(ctx, query, id), &e)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// GetAll returns the roster entries between two dates (inclusive), of one
+// employee if employeeID is not nil, ordered by start
+func (m RosterModel) GetAll(employeeID *int64, from, to string) ([]*RosterEntry, error) {
+	query := `
+		SELECT ` + rosterEntryColumns + `
+		FROM roster_entries re
+		JOIN shifts s ON s.id = re.shift_id
+		WHERE re.shift_date BETWEEN $1 AND $2 AND ($3::bigint IS NULL OR re.employee_id = $3)
+		ORDER BY re.starts_at, re.employee_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, from, to, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*RosterEntry{}
+	for rows.Next() {
+		var e RosterEntry
+		if err := scanRosterEntry(rows, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+
+	return entries, rows.Err()
+}
+
+// Current returns the shift of the employee which the time t belongs to: from
+// early before the shift starts until late after it ends. It returns
+// ErrRecordNotFound if the employee is not rostered at t.
+func (m RosterModel) Current(employeeID int64, t time.Time, early, late time.Duration) (*RosterEntry, error) {
+	query := `
+		SELECT ` + rosterEntryColumns + `
+		FROM roster_entries re
+		JOIN shifts s ON s.id = re.shift_id
+		WHERE re.employee_id = $1 AND re.starts_at <= $2 AND re.ends_at >= $3
+		ORDER BY re.starts_at
+		LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var e RosterEntry
+	err := scanRosterEntry(m.DB.QueryRowContext(ctx, query, employeeID, t.Add(early), t.Add(-late)), &e)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// Delete removes a roster entry, along with the swap requests involving it
+func (m RosterModel) Delete(id int64) error {
+	query := `DELETE FROM roster_entries WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Conflicts returns the conflicts the entry would have if it was rostered to
+// the employee, ignoring the entries listed in exclude
+func (m RosterModel) Conflicts(e *RosterEntry, employeeID int64, minRest time.Duration, exclude ...int64) ([]*RosterConflict, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return findConflicts(ctx, m.DB, e, employeeID, minRest, exclude...)
+}
+
+// lockRosters serializes changes to the roster of the employees until the end
+// of the transaction, so conflicts cannot be introduced concurrently
+func lockRosters(ctx context.Context, tx *sql.Tx, employeeIDs ...int64) error {
+	query := `SELECT id FROM users WHERE id = ANY($1) ORDER BY id FOR NO KEY UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(employeeIDs))
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// findConflicts returns the entries of the employee which e would overlap, or
+// which would leave less than minRest between e and them. Rest periods only
+// apply between working shifts, not on-call ones.
+func findConflicts(ctx context.Context, db interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}, e *RosterEntry, employeeID int64, minRest time.Duration, exclude ...int64) ([]*RosterConflict, error) {
+	query := `
+		SELECT ` + rosterEntryColumns + `
+		FROM roster_entries re
+		JOIN shifts s ON s.id = re.shift_id
+		WHERE re.employee_id = $1 AND NOT (re.id = ANY($2)) AND re.starts_at < $4 AND re.ends_at > $3
+		ORDER BY re.starts_at`
+
+	if exclude == nil {
+		exclude = []int64{}
+	}
+
+	rows, err := db.QueryContext(ctx, query, employeeID, pq.Array(exclude), e.StartsAt.Add(-minRest), e.EndsAt.Add(minRest))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conflicts []*RosterConflict
+	for rows.Next() {
+		var other RosterEntry
+		if err := scanRosterEntry(rows, &other); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case other.StartsAt.Before(e.EndsAt) && e.StartsAt.Before(other.EndsAt):
+			conflicts = append(conflicts, &RosterConflict{
+				Kind:    ConflictDoubleBooked,
+				Entry:   &other,
+				Message: fmt.Sprintf("overlaps the %s shift on %s", other.ShiftCode, other.ShiftDate),
+			})
+		case !e.OnCall && !other.OnCall:
+			conflicts = append(conflicts, &RosterConflict{
+				Kind:  ConflictRestPeriod,
+				Entry: &other,
+				Message: fmt.Sprintf("leaves less than %g hours of rest around the %s shift on %s",
+					minRest.Hours(), other.ShiftCode, other.ShiftDate),
+			})
+		}
+	}
+
+	return conflicts, rows.Err()
+}
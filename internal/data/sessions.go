@@ -0,0 +1,406 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrRefreshTokenReused = errors.New("refresh token was already used")
+
+// Session struct represents a login of a user, which lasts as long as its
+// refresh tokens keep being rotated. IPAddress is the address it was last seen
+// from.
+type Session struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Device     string     `json:"device"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	Current    bool       `json:"current"` // the session of the request
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// How often the last seen time of a session is updated at most
+const sessionSeenInterval = time.Minute
+
+const sessionColumns = `s.id, s.user_id, s.device, s.user_agent, s.ip_address, s.revoked_at, s.last_seen_at, s.created_at`
+
+func scanSession(row interface{ Scan(...any) error }, session *Session, extra ...any) error {
+	dest := []any{
+		&session.ID,
+		&session.UserID,
+		&session.Device,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.RevokedAt,
+		&session.LastSeenAt,
+		&session.CreatedAt,
+	}
+
+	return row.Scan(append(dest, extra...)...)
+}
+
+// RefreshToken struct is a single-use token to obtain a new access token. Only
+// its hash is stored, the plaintext is only known when the token is issued.
+type RefreshToken struct {
+	Plaintext string    `json:"token"`
+	SessionID int64     `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+// SessionModel struct wraps the connection pool
+type SessionModel struct {
+	DB *sql.DB
+}
+
+// New starts the session of a user and issues its first refresh token, valid
+// for ttl. The user's sessions which have expired are deleted.
+func (m SessionModel) New(session *Session, ttl time.Duration) (*RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE FROM sessions s
+		WHERE s.user_id = $1
+		AND NOT EXISTS (
+			SELECT 1 FROM refresh_tokens rt
+			WHERE rt.session_id = s.id AND rt.expiry > now()
+		)`
+
+	_, err = tx.ExecContext(ctx, query, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		INSERT INTO sessions (user_id, device, user_agent, ip_address)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, last_seen_at, created_at`
+
+	err = tx.QueryRowContext(ctx, query,
+		session.UserID,
+		session.Device,
+		session.UserAgent,
+		session.IPAddress,
+	).Scan(&session.ID, &session.LastSeenAt, &session.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := m.issue(ctx, tx, session.ID, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, tx.Commit()
+}
+
+// Refresh uses the refresh token matching the plaintext and issues the next
+// refresh token of its session, valid for ttl. The session is recorded as seen
+// from the IP address. Presenting a refresh token which was already used means
+// it was stolen, so every session of the user is revoked and
+// ErrRefreshTokenReused is returned. It returns ErrRecordNotFound if there is no
+// such token, or it expired or its session was revoked.
+func (m SessionModel) Refresh(plaintext, ipAddress string, ttl time.Duration) (*Session, *RefreshToken, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the token so that concurrent refreshes cannot both use it
+	query := `
+		SELECT ` + sessionColumns + `, rt.expiry, rt.used_at
+		FROM refresh_tokens rt
+		JOIN sessions s ON s.id = rt.session_id
+		WHERE rt.hash = $1
+		FOR UPDATE OF rt`
+
+	var session Session
+	var expiry time.Time
+	var usedAt *time.Time
+	err = scanSession(tx.QueryRowContext(ctx, query, hash[:]), &session, &expiry, &usedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrRecordNotFound
+		}
+		return nil, nil, err
+	}
+
+	if usedAt != nil {
+		err = m.revokeAllForUser(ctx, tx, session.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+	if session.RevokedAt != nil || !expiry.After(time.Now()) {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	query = `UPDATE refresh_tokens SET used_at = now() WHERE hash = $1`
+
+	_, err = tx.ExecContext(ctx, query, hash[:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query = `
+		UPDATE sessions
+		SET ip_address = $1, last_seen_at = now()
+		WHERE id = $2
+		RETURNING ip_address, last_seen_at`
+
+	err = tx.QueryRowContext(ctx, query, ipAddress, session.ID).Scan(&session.IPAddress, &session.LastSeenAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, err := m.issue(ctx, tx, session.ID, ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &session, token, nil
+}
+
+// issue stores a new refresh token of the session, valid for ttl
+func (m SessionModel) issue(ctx context.Context, tx *sql.Tx, sessionID int64, ttl time.Duration) (*RefreshToken, error) {
+	plaintext, hash, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &RefreshToken{
+		Plaintext: plaintext,
+		SessionID: sessionID,
+		Expiry:    time.Now().Add(ttl),
+	}
+
+	query := `
+		INSERT INTO refresh_tokens (hash, session_id, expiry)
+		VALUES ($1, $2, $3)`
+
+	_, err = tx.ExecContext(ctx, query, hash, sessionID, token.Expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// GetAllForUser returns the sessions of the user which have not been revoked or
+// expired, most recently seen first
+func (m SessionModel) GetAllForUser(userID int64) ([]*Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions s
+		WHERE s.user_id = $1 AND s.revoked_at IS NULL
+		AND EXISTS (
+			SELECT 1 FROM refresh_tokens rt
+			WHERE rt.session_id = s.id AND rt.used_at IS NULL AND rt.expiry > now()
+		)
+		ORDER BY s.last_seen_at DESC, s.id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		var session Session
+		if err := scanSession(rows, &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Get the session from the database. It returns ErrRecordNotFound if there is
+// no such session.
+func (m SessionModel) Get(sessionID int64) (*Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions s
+		WHERE s.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var session Session
+	err := scanSession(m.DB.QueryRowContext(ctx, query, sessionID), &session)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// RevokeForUser ends the session if it is one of the user's. It returns
+// ErrRecordNotFound if the user has no such session, or it was already revoked.
+func (m SessionModel) RevokeForUser(sessionID, userID int64) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = now()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, sessionID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Revoke ends the session, so that its refresh tokens and the access tokens
+// issued with them stop working
+func (m SessionModel) Revoke(sessionID int64) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = now()
+		WHERE id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, sessionID)
+	return err
+}
+
+// RevokeAllForUser ends every session of the user
+func (m SessionModel) RevokeAllForUser(userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = m.revokeAllForUser(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m SessionModel) revokeAllForUser(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = now()
+		WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
+
+// RevokeOthersForUser ends every session of the user except the one kept, e.g.
+// the session they changed their password from, and returns how many were
+// ended
+func (m SessionModel) RevokeOthersForUser(userID, keepSessionID int64) (int64, error) {
+	query := `
+		UPDATE sessions
+		SET revoked_at = now()
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, keepSessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// RevokeAccessToken adds the access token with the jti to the revocation list
+// until it expires. Expired entries are removed from the list.
+func (m SessionModel) RevokeAccessToken(jti string, expiry time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM revoked_access_tokens WHERE expiry < now()`)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO revoked_access_tokens (jti, expiry)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING`
+
+	_, err = m.DB.ExecContext(ctx, query, jti, expiry)
+	return err
+}
+
+// CheckAccessToken reports whether the access token with the jti was revoked,
+// either on its own or because its session was. Otherwise the session is
+// recorded as seen from the IP address, at most once per sessionSeenInterval.
+func (m SessionModel) CheckAccessToken(jti string, sessionID int64, ipAddress string) (bool, error) {
+	query := `
+		WITH seen AS (
+			UPDATE sessions
+			SET ip_address = $3, last_seen_at = now()
+			WHERE id = $2 AND revoked_at IS NULL
+			AND (last_seen_at < now() - make_interval(secs => $4) OR ip_address <> $3)
+			AND NOT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $1)
+		)
+		SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $1)
+			OR NOT EXISTS (SELECT 1 FROM sessions WHERE id = $2 AND revoked_at IS NULL)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var revoked bool
+	err := m.DB.QueryRowContext(ctx, query, jti, sessionID, ipAddress, sessionSeenInterval.Seconds()).Scan(&revoked)
+	return revoked, err
+}
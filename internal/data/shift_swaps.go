@@ -0,0 +1,361 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrShiftSwapExists      = errors.New("the shift is already offered in an open swap request")
+	ErrShiftSwapNotPending  = errors.New("shift swap request is no longer awaiting the peer's answer")
+	ErrShiftSwapNotAccepted = errors.New("shift swap request is not awaiting review")
+	ErrShiftSwapNotOpen     = errors.New("shift swap request is already closed")
+	ErrShiftSwapStale       = errors.New("the shifts have changed hands since the swap was requested")
+	ErrShiftStarted         = errors.New("cannot swap a shift which has already started")
+)
+
+// Shift swap request statuses
+const (
+	SwapStatusPending   = "pending"
+	SwapStatusAccepted  = "accepted"
+	SwapStatusDeclined  = "declined"
+	SwapStatusApproved  = "approved"
+	SwapStatusRejected  = "rejected"
+	SwapStatusCancelled = "cancelled"
+)
+
+// ShiftSwap struct represents an employee's request to hand one of their
+// rostered shifts over to a peer, taking the peer's shift PeerEntryID in
+// exchange if set. The peer accepts or declines first (pending), then an admin
+// approves or rejects it (accepted).
+type ShiftSwap struct {
+	ID               int64      `json:"id"`
+	RequesterID      int64      `json:"requester_id"`
+	RequesterEntryID int64      `json:"requester_entry_id"`
+	PeerID           int64      `json:"peer_id"`
+	PeerEntryID      *int64     `json:"peer_entry_id,omitempty"`
+	Reason           string     `json:"reason"`
+	Status           string     `json:"status"`
+	RespondedAt      *time.Time `json:"responded_at,omitempty"`
+	ReviewedBy       *int64     `json:"reviewed_by,omitempty"`
+	ReviewedAt       *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote       string     `json:"review_note,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// ShiftSwapModel struct wraps the connection pool
+type ShiftSwapModel struct {
+	DB *sql.DB
+}
+
+const shiftSwapColumns = `id, requester_id, requester_entry_id, peer_id, peer_entry_id, reason, status,
+	responded_at, reviewed_by, reviewed_at, review_note, created_at, updated_at`
+
+func scanShiftSwap(row interface{ Scan(...any) error }, s *ShiftSwap) error {
+	return row.Scan(
+		&s.ID,
+		&s.RequesterID,
+		&s.RequesterEntryID,
+		&s.PeerID,
+		&s.PeerEntryID,
+		&s.Reason,
+		&s.Status,
+		&s.RespondedAt,
+		&s.ReviewedBy,
+		&s.ReviewedAt,
+		&s.ReviewNote,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+}
+
+// Insert new shift swap request in the database
+func (m ShiftSwapModel) Insert(s *ShiftSwap) error {
+	query := `
+		INSERT INTO shift_swap_requests (requester_id, requester_entry_id, peer_id, peer_entry_id, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		s.RequesterID,
+		s.RequesterEntryID,
+		s.PeerID,
+		s.PeerEntryID,
+		s.Reason,
+	).Scan(&s.ID, &s.Status, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrShiftSwapExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Get shift swap request by ID from the database
+func (m ShiftSwapModel) Get(id int64) (*ShiftSwap, error) {
+	query := `SELECT ` + shiftSwapColumns + ` FROM shift_swap_requests WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var s ShiftSwap
+	err := scanShiftSwap(m.DB.QueryRowContext(ctx, query, id), &s)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetAllForUser returns the swap requests the employee made or received,
+// newest first
+func (m ShiftSwapModel) GetAllForUser(userID int64) ([]*ShiftSwap, error) {
+	query := `
+		SELECT ` + shiftSwapColumns + `
+		FROM shift_swap_requests
+		WHERE requester_id = $1 OR peer_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	return m.query(query, userID)
+}
+
+// GetAll returns every swap request, filtered by status if not empty, oldest
+// first so the admin reviews them in order
+func (m ShiftSwapModel) GetAll(status string) ([]*ShiftSwap, error) {
+	query := `
+		SELECT ` + shiftSwapColumns + `
+		FROM shift_swap_requests
+		WHERE ($1 = '' OR status::text = $1)
+		ORDER BY created_at, id`
+
+	return m.query(query, status)
+}
+
+func (m ShiftSwapModel) query(query string, args ...any) ([]*ShiftSwap, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	swaps := []*ShiftSwap{}
+	for rows.Next() {
+		var s ShiftSwap
+		if err := scanShiftSwap(rows, &s); err != nil {
+			return nil, err
+		}
+		swaps = append(swaps, &s)
+	}
+
+	return swaps, rows.Err()
+}
+
+// Respond records the peer's answer to a pending swap request
+func (m ShiftSwapModel) Respond(s *ShiftSwap, accept bool) error {
+	status := SwapStatusDeclined
+	if accept {
+		status = SwapStatusAccepted
+	}
+
+	query := `
+		UPDATE shift_swap_requests
+		SET status = $1, responded_at = now(), updated_at = now()
+		WHERE id = $2 AND status = 'pending'
+		RETURNING status, responded_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, status, s.ID).Scan(&s.Status, &s.RespondedAt, &s.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrShiftSwapNotPending
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Cancel lets the requester withdraw a swap request which is still open
+func (m ShiftSwapModel) Cancel(s *ShiftSwap) error {
+	query := `
+		UPDATE shift_swap_requests
+		SET status = 'cancelled', updated_at = now()
+		WHERE id = $1 AND status IN ('pending', 'accepted')
+		RETURNING status, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, s.ID).Scan(&s.Status, &s.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrShiftSwapNotOpen
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Reject marks an accepted swap request as rejected
+func (m ShiftSwapModel) Reject(s *ShiftSwap, reviewerID int64, note string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = m.review(ctx, tx, s, SwapStatusRejected, reviewerID, note)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Approve marks an accepted swap request as approved and exchanges the shifts,
+// unless the shifts started, changed hands or would conflict with the new
+// holder's roster. Conflicts are returned without changing anything.
+func (m ShiftSwapModel) Approve(s *ShiftSwap, reviewerID int64, note string, minRest time.Duration) ([]*RosterConflict, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = lockRosters(ctx, tx, s.RequesterID, s.PeerID)
+	if err != nil {
+		return nil, err
+	}
+
+	getEntry := func(id int64) (*RosterEntry, error) {
+		query := `
+			SELECT ` + rosterEntryColumns + `
+			FROM roster_entries re
+			JOIN shifts s ON s.id = re.shift_id
+			WHERE re.id = $1`
+
+		var e RosterEntry
+		err := scanRosterEntry(tx.QueryRowContext(ctx, query, id), &e)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrShiftSwapStale
+			}
+			return nil, err
+		}
+		if e.StartsAt.Before(time.Now()) {
+			return nil, ErrShiftStarted
+		}
+		return &e, nil
+	}
+
+	given, err := getEntry(s.RequesterEntryID)
+	if err != nil {
+		return nil, err
+	}
+	if given.EmployeeID != s.RequesterID {
+		return nil, ErrShiftSwapStale
+	}
+
+	var taken *RosterEntry
+	exclude := []int64{given.ID}
+	if s.PeerEntryID != nil {
+		taken, err = getEntry(*s.PeerEntryID)
+		if err != nil {
+			return nil, err
+		}
+		if taken.EmployeeID != s.PeerID {
+			return nil, ErrShiftSwapStale
+		}
+		exclude = append(exclude, taken.ID)
+	}
+
+	conflicts, err := findConflicts(ctx, tx, given, s.PeerID, minRest, exclude...)
+	if err != nil {
+		return nil, err
+	}
+	if taken != nil {
+		more, err := findConflicts(ctx, tx, taken, s.RequesterID, minRest, exclude...)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, more...)
+	}
+	if len(conflicts) > 0 {
+		return conflicts, nil
+	}
+
+	err = m.review(ctx, tx, s, SwapStatusApproved, reviewerID, note)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE roster_entries
+		SET employee_id = $1, updated_by = $2, updated_at = now()
+		WHERE id = $3`
+
+	_, err = tx.ExecContext(ctx, query, s.PeerID, reviewerID, given.ID)
+	if err == nil && taken != nil {
+		_, err = tx.ExecContext(ctx, query, s.RequesterID, reviewerID, taken.ID)
+	}
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23P01" {
+			return nil, ErrRosterOverlap
+		}
+		return nil, err
+	}
+
+	return nil, tx.Commit()
+}
+
+// review moves an accepted swap request to its final status
+func (m ShiftSwapModel) review(ctx context.Context, tx *sql.Tx, s *ShiftSwap, status string, reviewerID int64, note string) error {
+	query := `
+		UPDATE shift_swap_requests
+		SET status = $1, reviewed_by = $2, reviewed_at = now(), review_note = $3, updated_at = now()
+		WHERE id = $4 AND status = 'accepted'
+		RETURNING status, reviewed_at, updated_at`
+
+	err := tx.QueryRowContext(ctx, query, status, reviewerID, note, s.ID).Scan(
+		&s.Status,
+		&s.ReviewedAt,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrShiftSwapNotAccepted
+		}
+		return err
+	}
+
+	s.ReviewedBy = &reviewerID
+	s.ReviewNote = note
+	return nil
+}
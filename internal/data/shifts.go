@@ -0,0 +1,155 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateShiftCode = errors.New("a shift with this code already exists")
+)
+
+// Shift struct represents a shift template, e.g. a night shift from 22:00 to
+// 06:00. A shift ending at or before its start time ends on the next day.
+// On-call shifts are standby duty paid with Allowance per rostered shift.
+type Shift struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	StartTime string    `json:"start_time"`
+	EndTime   string    `json:"end_time"`
+	OnCall    bool      `json:"on_call"`
+	Allowance int64     `json:"allowance"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	CreatedBy int64     `json:"created_by"`
+	UpdatedBy int64     `json:"updated_by"`
+}
+
+// Window returns the start and end of the shift on the date (YYYY-MM-DD) in the
+// given time zone
+func (s *Shift) Window(date string, loc *time.Location) (time.Time, time.Time, error) {
+	day, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	start, err := time.Parse("15:04", s.StartTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := time.Parse("15:04", s.EndTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	startsAt := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc)
+	endsAt := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+	if !endsAt.After(startsAt) {
+		endsAt = time.Date(day.Year(), day.Month(), day.Day()+1, end.Hour(), end.Minute(), 0, 0, loc)
+	}
+
+	return startsAt, endsAt, nil
+}
+
+// ShiftModel struct wraps the connection pool
+type ShiftModel struct {
+	DB *sql.DB
+}
+
+const shiftColumns = `id, code, name, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI'), on_call, allowance,
+	created_at, updated_at, created_by, updated_by`
+
+func scanShift(row interface{ Scan(...any) error }, s *Shift) error {
+	return row.Scan(
+		&s.ID,
+		&s.Code,
+		&s.Name,
+		&s.StartTime,
+		&s.EndTime,
+		&s.OnCall,
+		&s.Allowance,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+		&s.CreatedBy,
+		&s.UpdatedBy,
+	)
+}
+
+// Insert new shift in the database
+func (m ShiftModel) Insert(s *Shift) error {
+	query := `
+		INSERT INTO shifts (code, name, start_time, end_time, on_call, allowance, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		s.Code,
+		s.Name,
+		s.StartTime,
+		s.EndTime,
+		s.OnCall,
+		s.Allowance,
+		s.CreatedBy,
+		s.UpdatedBy,
+	).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrDuplicateShiftCode
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Get shift by ID from the database
+func (m ShiftModel) Get(id int64) (*Shift, error) {
+	query := `SELECT ` + shiftColumns + ` FROM shifts WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var s Shift
+	err := scanShift(m.DB.QueryRowContext(ctx, query, id), &s)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetAll returns every shift ordered by start time
+func (m ShiftModel) GetAll() ([]*Shift, error) {
+	query := `SELECT ` + shiftColumns + ` FROM shifts ORDER BY start_time, code`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shifts := []*Shift{}
+	for rows.Next() {
+		var s Shift
+		if err := scanShift(rows, &s); err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, &s)
+	}
+
+	return shifts, rows.Err()
+}
@@ -0,0 +1,108 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SigningKey struct represents a key pair signing access tokens. Its private
+// key is stored encrypted.
+type SigningKey struct {
+	KID         string
+	Algorithm   string
+	PrivateKey  []byte
+	PublicKey   []byte
+	SignFrom    time.Time
+	SignUntil   time.Time
+	VerifyUntil time.Time
+	CreatedAt   time.Time
+}
+
+// SigningKeyModel struct wraps the connection pool
+type SigningKeyModel struct {
+	DB *sql.DB
+}
+
+// Insert new signing key in the database. It reports false if another key
+// already signs from the same time, e.g. because another server rotated the
+// keys at the same time.
+func (m SigningKeyModel) Insert(key *SigningKey) (bool, error) {
+	query := `
+		INSERT INTO signing_keys (kid, algorithm, private_key, public_key, sign_from, sign_until, verify_until)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (sign_from) DO NOTHING
+		RETURNING created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		key.KID,
+		key.Algorithm,
+		key.PrivateKey,
+		key.PublicKey,
+		key.SignFrom,
+		key.SignUntil,
+		key.VerifyUntil,
+	).Scan(&key.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetAll returns the keys which still verify tokens, oldest first
+func (m SigningKeyModel) GetAll() ([]*SigningKey, error) {
+	query := `
+		SELECT kid, algorithm, private_key, public_key, sign_from, sign_until, verify_until, created_at
+		FROM signing_keys
+		WHERE verify_until > now()
+		ORDER BY sign_from`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*SigningKey{}
+	for rows.Next() {
+		var key SigningKey
+		err := rows.Scan(
+			&key.KID,
+			&key.Algorithm,
+			&key.PrivateKey,
+			&key.PublicKey,
+			&key.SignFrom,
+			&key.SignUntil,
+			&key.VerifyUntil,
+			&key.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+
+	return keys, rows.Err()
+}
+
+// DeleteExpired deletes the keys which no longer verify tokens
+func (m SigningKeyModel) DeleteExpired() error {
+	query := `DELETE FROM signing_keys WHERE verify_until <= now()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,228 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+)
+
+// StandardMonthlyHours is the number of working hours in a month used to derive
+// an hourly rate from a monthly salary (40 hours a week × 52 weeks / 12 months)
+const StandardMonthlyHours = 173
+
+var (
+	ErrNotCheckedOut          = errors.New("employee has not checked out yet")
+	ErrTimesheetOverAllocated = errors.New("allocated hours exceed the hours worked")
+)
+
+// TimesheetEntry struct represents minutes of an attendance day which the
+// employee allocated to a project
+type TimesheetEntry struct {
+	ID           int64     `json:"id"`
+	AttendanceID int64     `json:"attendance_id"`
+	ProjectID    int64     `json:"project_id"`
+	Minutes      int       `json:"minutes"`
+	Note         *string   `json:"note,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	CreatedBy    int64     `json:"created_by"`
+}
+
+// Timesheet struct holds the allocation of one attendance day across projects.
+// The allocated minutes never exceed the minutes worked between check-in and
+// check-out.
+type Timesheet struct {
+	AttendanceID     int64             `json:"attendance_id"`
+	Date             string            `json:"date"`
+	WorkedMinutes    int               `json:"worked_minutes"`
+	AllocatedMinutes int               `json:"allocated_minutes"`
+	Entries          []*TimesheetEntry `json:"entries"`
+}
+
+// ProjectCost struct holds the hours employees allocated to a project over a
+// range of dates and the salary cost of those hours
+type ProjectCost struct {
+	ProjectID      int64   `json:"project_id"`
+	ProjectCode    string  `json:"project_code"`
+	ProjectName    string  `json:"project_name"`
+	CostCenterCode string  `json:"cost_center_code"`
+	Employees      int     `json:"employees"`
+	Hours          float64 `json:"hours"`
+	SalaryCost     int64   `json:"salary_cost"`
+}
+
+// TimesheetModel struct wraps the connection pool
+type TimesheetModel struct {
+	DB *sql.DB
+}
+
+// Replace replaces the timesheet entries of the attendance day. The day must be
+// checked out and the entries must not allocate more minutes than were worked.
+func (m TimesheetModel) Replace(attendanceID int64, entries []*TimesheetEntry) (*Timesheet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the attendance so a concurrent punch cannot reopen the day
+	ts := Timesheet{AttendanceID: attendanceID, Entries: []*TimesheetEntry{}}
+	var checkedOut bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT att_date::text, worked_minutes, checkout_at IS NOT NULL
+		FROM attendance
+		WHERE id = $1
+		FOR UPDATE`, attendanceID).Scan(&ts.Date, &ts.WorkedMinutes, &checkedOut)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	if !checkedOut {
+		return nil, ErrNotCheckedOut
+	}
+
+	for _, e := range entries {
+		ts.AllocatedMinutes += e.Minutes
+	}
+	if ts.AllocatedMinutes > ts.WorkedMinutes {
+		return nil, ErrTimesheetOverAllocated
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM timesheet_entries WHERE attendance_id = $1`, attendanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO timesheet_entries (attendance_id, project_id, minutes, note, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	for _, e := range entries {
+		e.AttendanceID = attendanceID
+		err = tx.QueryRowContext(ctx, query, e.AttendanceID, e.ProjectID, e.Minutes, e.Note, e.CreatedBy).
+			Scan(&e.ID, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		ts.Entries = append(ts.Entries, e)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &ts, nil
+}
+
+// GetAll returns the timesheets of the employee's attendance days between two
+// dates (inclusive), including days with no hours allocated yet
+func (m TimesheetModel) GetAll(employeeID int64, from, to string) ([]*Timesheet, error) {
+	query := `
+		SELECT a.id, a.att_date::text, a.worked_minutes,
+			te.id, te.project_id, te.minutes, te.note, te.created_at, te.created_by
+		FROM attendance a
+		LEFT JOIN timesheet_entries te ON te.attendance_id = a.id
+		WHERE a.employee_id = $1 AND a.att_date BETWEEN $2 AND $3
+		ORDER BY a.att_date, te.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, employeeID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	timesheets := []*Timesheet{}
+	var ts *Timesheet
+	for rows.Next() {
+		var attendanceID int64
+		var date string
+		var workedMinutes int
+		var entryID, projectID, createdBy sql.NullInt64
+		var minutes sql.NullInt32
+		var note *string
+		var createdAt sql.NullTime
+		err := rows.Scan(&attendanceID, &date, &workedMinutes,
+			&entryID, &projectID, &minutes, &note, &createdAt, &createdBy)
+		if err != nil {
+			return nil, err
+		}
+
+		if ts == nil || ts.AttendanceID != attendanceID {
+			ts = &Timesheet{
+				AttendanceID:  attendanceID,
+				Date:          date,
+				WorkedMinutes: workedMinutes,
+				Entries:       []*TimesheetEntry{},
+			}
+			timesheets = append(timesheets, ts)
+		}
+
+		if entryID.Valid {
+			ts.AllocatedMinutes += int(minutes.Int32)
+			ts.Entries = append(ts.Entries, &TimesheetEntry{
+				ID:           entryID.Int64,
+				AttendanceID: attendanceID,
+				ProjectID:    projectID.Int64,
+				Minutes:      int(minutes.Int32),
+				Note:         note,
+				CreatedAt:    createdAt.Time,
+				CreatedBy:    createdBy.Int64,
+			})
+		}
+	}
+
+	return timesheets, rows.Err()
+}
+
+// ProjectCosts returns the hours allocated to each project between two dates
+// (inclusive) and their salary cost, valuing an hour of an employee at their
+// monthly salary divided by StandardMonthlyHours
+func (m TimesheetModel) ProjectCosts(from, to string) ([]*ProjectCost, error) {
+	query := `
+		SELECT p.id, p.code, p.name, cc.code,
+			count(DISTINCT a.employee_id),
+			sum(te.minutes),
+			round(sum(te.minutes * u.salary::numeric) / ($3 * 60))::bigint
+		FROM timesheet_entries te
+		JOIN attendance a ON a.id = te.attendance_id
+		JOIN users u ON u.id = a.employee_id
+		JOIN projects p ON p.id = te.project_id
+		JOIN cost_centers cc ON cc.id = p.cost_center_id
+		WHERE a.att_date BETWEEN $1 AND $2
+		GROUP BY p.id, cc.code
+		ORDER BY p.code`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, from, to, StandardMonthlyHours)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	costs := []*ProjectCost{}
+	for rows.Next() {
+		var c ProjectCost
+		var minutes int
+		err := rows.Scan(&c.ProjectID, &c.ProjectCode, &c.ProjectName, &c.CostCenterCode,
+			&c.Employees, &minutes, &c.SalaryCost)
+		if err != nil {
+			return nil, err
+		}
+		c.Hours = math.Round(float64(minutes)/60*100) / 100
+		costs = append(costs, &c)
+	}
+
+	return costs, rows.Err()
+}
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Token scopes
+const (
+	ScopePasswordReset = "password-reset"
+	ScopeActivation    = "activation"
+	ScopeTwoFactor     = "2fa-challenge"
+)
+
+// Token struct represents a single-use token emailed to a user. Only its hash
+// is stored, the plaintext is only known when the token is created.
+type Token struct {
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	UserID    int64     `json:"-"`
+	Scope     string    `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+// TokenModel struct wraps the connection pool
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// New creates a token for the user valid for ttl and stores it in the database
+func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	plaintext, hash, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{
+		Plaintext: plaintext,
+		Hash:      hash,
+		UserID:    userID,
+		Scope:     scope,
+		Expiry:    time.Now().Add(ttl),
+	}
+
+	err = m.Insert(token)
+	return token, err
+}
+
+// Insert new token in the database
+func (m TokenModel) Insert(token *Token) error {
+	query := `
+		INSERT INTO tokens (hash, user_id, scope, expiry)
+		VALUES ($1, $2, $3, $4)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, token.Hash, token.UserID, token.Scope, token.Expiry)
+	return err
+}
+
+// Consume deletes the unexpired token of the scope matching the plaintext and
+// returns the ID of the user it was issued to, so that a token can only be used
+// once even by concurrent requests. It returns ErrRecordNotFound if there is no
+// such token.
+func (m TokenModel) Consume(scope, plaintext string) (int64, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	query := `
+		DELETE FROM tokens
+		WHERE hash = $1 AND scope = $2 AND expiry > now()
+		RETURNING user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var userID int64
+	err := m.DB.QueryRowContext(ctx, query, hash[:], scope).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// GetUserID returns the ID of the user the unexpired token of the scope matching
+// the plaintext was issued to, without using it up. It returns
+// ErrRecordNotFound if there is no such token.
+func (m TokenModel) GetUserID(scope, plaintext string) (int64, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	query := `
+		SELECT user_id
+		FROM tokens
+		WHERE hash = $1 AND scope = $2 AND expiry > now()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var userID int64
+	err := m.DB.QueryRowContext(ctx, query, hash[:], scope).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// HasValid reports whether the user has an unexpired token of the scope, e.g.
+// a pending invitation
+func (m TokenModel) HasValid(scope string, userID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM tokens
+			WHERE scope = $1 AND user_id = $2 AND expiry > now()
+		)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var valid bool
+	err := m.DB.QueryRowContext(ctx, query, scope, userID).Scan(&valid)
+	return valid, err
+}
+
+// DeleteAllForUser deletes every token of the scope issued to the user
+func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
+	query := `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, scope, userID)
+	return err
+}
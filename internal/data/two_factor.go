@@ -0,0 +1,290 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+
+// Number of recovery codes a user gets
+const recoveryCodeCount = 10
+
+// Alphabet of recovery codes, Crockford's base32 which leaves out the easily
+// confused i, l, o and u
+const recoveryCodeAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+// TOTP struct holds the authenticator app secret of a user, encrypted as it
+// would let anyone generate their codes. Two-factor authentication is enabled
+// once EnabledAt is set.
+type TOTP struct {
+	UserID       int64
+	Secret       []byte
+	EnabledAt    *time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// TwoFactorModel struct wraps the connection pool
+type TwoFactorModel struct {
+	DB *sql.DB
+}
+
+// Get the TOTP secret of the user from the database
+func (m TwoFactorModel) Get(userID int64) (*TOTP, error) {
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at
+		FROM user_totp
+		WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var t TOTP
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(
+		&t.UserID,
+		&t.Secret,
+		&t.EnabledAt,
+		&t.LastUsedStep,
+		&t.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Enrol stores a new encrypted secret pending confirmation for the user,
+// replacing a previous pending one. It returns ErrTwoFactorEnabled if the user already has
+// two-factor authentication enabled.
+func (m TwoFactorModel) Enrol(userID int64, secret []byte) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = now()
+		WHERE user_totp.enabled_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTwoFactorEnabled
+	}
+
+	return nil
+}
+
+// UseStep records that the code of the time step was accepted. It reports
+// false if a code of this or a later step was already used, so that a code
+// cannot be replayed.
+func (m TwoFactorModel) UseStep(userID, step int64) (bool, error) {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// Enable turns on two-factor authentication for the user once they confirmed
+// their pending secret, and returns their first recovery codes
+func (m TwoFactorModel) Enable(userID int64) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE user_totp
+		SET enabled_at = now()
+		WHERE user_id = $1 AND enabled_at IS NULL`
+
+	result, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	codes, err := m.replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, tx.Commit()
+}
+
+// Disable turns off two-factor authentication for the user, deleting their
+// secret and recovery codes
+func (m TwoFactorModel) Disable(userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes discards the recovery codes of the user and returns new
+// ones. Only their hashes are stored, so they cannot be shown again.
+func (m TwoFactorModel) ReplaceRecoveryCodes(userID int64) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, err := m.replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, tx.Commit()
+}
+
+func (m TwoFactorModel) replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64) ([]string, error) {
+	_, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		hash := hashRecoveryCode(codes[i])
+		_, err = tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, hash) VALUES ($1, $2)`, userID, hash[:])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode marks the recovery code of the user as used. It reports false
+// if the user has no such unused code.
+func (m TwoFactorModel) UseRecoveryCode(userID int64, code string) (bool, error) {
+	hash := hashRecoveryCode(code)
+
+	query := `
+		UPDATE recovery_codes
+		SET used_at = now()
+		WHERE user_id = $1 AND hash = $2 AND used_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, hash[:])
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// RecoveryCodesLeft returns how many unused recovery codes the user has
+func (m TwoFactorModel) RecoveryCodesLeft(userID int64) (int, error) {
+	query := `SELECT count(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var left int
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&left)
+	return left, err
+}
+
+// generateRecoveryCode returns a random code formatted as "xxxxx-xxxxx"
+func generateRecoveryCode() (string, error) {
+	randomBytes := make([]byte, 10)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	var code strings.Builder
+	for i, b := range randomBytes {
+		if i == 5 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(recoveryCodeAlphabet[b&31])
+	}
+
+	return code.String(), nil
+}
+
+// hashRecoveryCode hashes the code, ignoring case, spaces and dashes as users
+// may type it differently than it was shown
+func hashRecoveryCode(code string) [32]byte {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return sha256.Sum256([]byte(code))
+}
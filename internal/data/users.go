@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/lib/pq"
@@ -14,20 +17,40 @@ import (
 var (
 	ErrDuplicateEmail = errors.New("duplicate email")
 	ErrRecordNotFound = errors.New("record not found")
+	ErrActivated      = errors.New("account is already activated")
 )
 
 // User struct represents an individual user
 type User struct {
-	ID        int64     `json:"id"`
-	Role      string    `json:"role"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  Password  `json:"-"`
-	Salary    int64     `json:"salary"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	CreatedBy int64     `json:"created_by"`
-	UpdatedBy int64     `json:"updated_by"`
+	ID            int64       `json:"id"`
+	Role          string      `json:"role"`
+	Name          string      `json:"name"`
+	Email         string      `json:"email"`
+	Password      Password    `json:"-"`
+	Salary        int64       `json:"salary"`
+	OfficeID      *int64      `json:"office_id,omitempty"`
+	RemoteAllowed bool        `json:"remote_allowed"` // exempt from the office geofence
+	Active        bool        `json:"active"`
+	Activated     bool        `json:"activated"` // set their password from their invitation
+	TwoFactor     bool        `json:"two_factor_enabled"`
+	Profile       UserProfile `json:"profile"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	CreatedBy     int64       `json:"created_by"`
+	UpdatedBy     int64       `json:"updated_by"`
+}
+
+// UserProfile struct holds the personal details of a user. Users update their
+// contact details themselves, while changes to their bank account require the
+// approval of an admin.
+type UserProfile struct {
+	Phone                 string `json:"phone"`
+	Address               string `json:"address"`
+	EmergencyContactName  string `json:"emergency_contact_name"`
+	EmergencyContactPhone string `json:"emergency_contact_phone"`
+	BankName              string `json:"bank_name"`
+	BankAccountNumber     string `json:"bank_account_number"`
+	BankAccountHolder     string `json:"bank_account_holder"`
 }
 
 // UserModel struct wraps the connection pool
@@ -56,6 +79,12 @@ func (p *Password) Set(plaintextPassword string) error {
 // hashed password stored in the struct, returning true if it matches and false
 // otherwise.
 func (p *Password) Matches(plaintextPassword string) (bool, error) {
+	// Users who have not activated their account, or log in with single
+	// sign-on, have no password. Checking takes as long as for other users.
+	if p.hash == nil {
+		return MatchNoPassword(plaintextPassword), nil
+	}
+
 	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
 	if err != nil {
 		switch {
@@ -68,11 +97,37 @@ func (p *Password) Matches(plaintextPassword string) (bool, error) {
 	return true, nil
 }
 
-// Insert new user in the database
+// dummyHash is a bcrypt hash with the same cost as users' passwords
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("monday-hr unknown user"), 12)
+	return hash
+})
+
+// MatchNoPassword takes as long as checking a password, so that logging in as an
+// unknown or pending user cannot be told apart from a wrong password by the
+// response time. It always reports false.
+func MatchNoPassword(plaintextPassword string) bool {
+	bcrypt.CompareHashAndPassword(dummyHash(), []byte(plaintextPassword))
+	return false
+}
+
+// value returns the hash to store in the database, or nil for users who have
+// not activated their account and have no password yet
+func (p *Password) value() any {
+	if p.hash == nil {
+		return nil
+	}
+	return p.hash
+}
+
+// Insert new user in the database. A user inserted without a password is
+// pending until they activate their account by setting one, unless Activated
+// is set, as for users who log in with single sign-on.
 func (m UserModel) Insert(user *User) error {
 	query := `
-		INSERT INTO users (role, name, email, password_hash, salary, created_by, updated_by)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		INSERT INTO users (role, name, email, password_hash, activated, salary, office_id, remote_allowed, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $4 IS NOT NULL OR $10, $5, $6, $7, $8, $9)
+		RETURNING id, active, activated, created_at, updated_at`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -95,14 +150,18 @@ func (m UserModel) Insert(user *User) error {
 
 	// If the table already contains a record with this email address, then when we try
 	// to perform the insert there will be a violation of the UNIQUE users email constraint
-	_, err := m.DB.ExecContext(ctx, query,
+	err := m.DB.QueryRowContext(ctx, query,
 		user.Role,
 		user.Name,
 		user.Email,
-		user.Password.hash,
+		user.Password.value(),
 		user.Salary,
+		user.OfficeID,
+		user.RemoteAllowed,
 		createdBy,
-		updatedBy)
+		updatedBy,
+		user.Activated,
+	).Scan(&user.ID, &user.Active, &user.Activated, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		var pqErr *pq.Error
 		if errors.As(err, &pqErr) {
@@ -117,49 +176,73 @@ func (m UserModel) Insert(user *User) error {
 	return nil
 }
 
-// Get user by email from the database
-func (m UserModel) GetByEmail(email string) (*User, error) {
-	query := `
-        SELECT id, role, name, email, password_hash, salary, created_at, updated_at, created_by, updated_by
-        FROM users
-        WHERE email = $1`
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+const userColumns = `id, role, name, email, password_hash, salary, office_id, remote_allowed, active, activated,
+	phone, address, emergency_contact_name, emergency_contact_phone, bank_name, bank_account_number, bank_account_holder,
+	created_at, updated_at, created_by, updated_by,
+	EXISTS (SELECT 1 FROM user_totp t WHERE t.user_id = users.id AND t.enabled_at IS NOT NULL)`
 
-	var user User
+// scanUser scans the userColumns of a row into user, and the columns selected
+// after them into extra
+func scanUser(row interface{ Scan(...any) error }, user *User, extra ...any) error {
 	var createdBy *int64
 	var updatedBy *int64
 
-	err := m.DB.QueryRowContext(ctx, query, email).Scan(
+	dest := []any{
 		&user.ID,
 		&user.Role,
 		&user.Name,
 		&user.Email,
 		&user.Password.hash,
 		&user.Salary,
+		&user.OfficeID,
+		&user.RemoteAllowed,
+		&user.Active,
+		&user.Activated,
+		&user.Profile.Phone,
+		&user.Profile.Address,
+		&user.Profile.EmergencyContactName,
+		&user.Profile.EmergencyContactPhone,
+		&user.Profile.BankName,
+		&user.Profile.BankAccountNumber,
+		&user.Profile.BankAccountHolder,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&createdBy,
 		&updatedBy,
-	)
+		&user.TwoFactor,
+	}
+
+	err := row.Scan(append(dest, extra...)...)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrRecordNotFound
-		}
-		return nil, err
+		return err
 	}
 
+	// Users created by the seed have no creator
+	user.CreatedBy, user.UpdatedBy = 0, 0
 	if createdBy != nil {
 		user.CreatedBy = *createdBy
-	} else {
-		user.CreatedBy = 0
 	}
-
 	if updatedBy != nil {
 		user.UpdatedBy = *updatedBy
-	} else {
-		user.UpdatedBy = 0
+	}
+
+	return nil
+}
+
+// Get user by email from the database
+func (m UserModel) GetByEmail(email string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var user User
+	err := scanUser(m.DB.QueryRowContext(ctx, query, email), &user)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
 	}
 
 	return &user, nil
@@ -167,30 +250,13 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 
 // Get user by ID from the database
 func (m UserModel) Get(id int64) (*User, error) {
-	query := `
-        SELECT id, role, name, email, password_hash, salary, created_at, updated_at, created_by, updated_by
-        FROM users
-        WHERE id = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var user User
-	var createdBy *int64
-	var updatedBy *int64
-
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&user.ID,
-		&user.Role,
-		&user.Name,
-		&user.Email,
-		&user.Password.hash,
-		&user.Salary,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&createdBy,
-		&updatedBy,
-	)
+	err := scanUser(m.DB.QueryRowContext(ctx, query, id), &user)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrRecordNotFound
@@ -198,17 +264,256 @@ func (m UserModel) Get(id int64) (*User, error) {
 		return nil, err
 	}
 
-	if createdBy != nil {
-		user.CreatedBy = *createdBy
-	} else {
-		user.CreatedBy = 0
+	return &user, nil
+}
+
+// UserFilter struct narrows down the users listed by GetAll. Name and Email
+// match anywhere in the value, ignoring case; empty fields match every user.
+type UserFilter struct {
+	Role   string
+	Name   string
+	Email  string
+	Active *bool
+}
+
+// GetAll returns a page of the users matching the filter, along with the
+// pagination metadata
+func (m UserModel) GetAll(filter UserFilter, filters Filters) ([]*User, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT %s, count(*) OVER()
+		FROM users
+		WHERE ($1 = '' OR role = $1)
+		AND ($2 = '' OR name ILIKE '%%' || $2 || '%%')
+		AND ($3 = '' OR email ILIKE '%%' || $3 || '%%')
+		AND ($4::boolean IS NULL OR active = $4)
+		ORDER BY %s %s, id ASC
+		LIMIT $5 OFFSET $6`, userColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query,
+		filter.Role,
+		escapeLike(filter.Name),
+		escapeLike(filter.Email),
+		filter.Active,
+		filters.limit(),
+		filters.offset(),
+	)
+	if err != nil {
+		return nil, Metadata{}, err
 	}
+	defer rows.Close()
 
-	if updatedBy != nil {
-		user.UpdatedBy = *updatedBy
-	} else {
-		user.UpdatedBy = 0
+	totalRecords := 0
+	users := []*User{}
+	for rows.Next() {
+		var user User
+		err := scanUser(rows, &user, &totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		users = append(users, &user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
 	}
 
-	return &user, nil
+	return users, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern so that s matches itself
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// Update the role, name, email, password and salary of the user in the
+// database
+func (m UserModel) Update(user *User) error {
+	query := `
+		UPDATE users
+		SET role = $1, name = $2, email = $3, password_hash = $4, salary = $5, updated_by = $6, updated_at = now()
+		WHERE id = $7
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		user.Role,
+		user.Name,
+		user.Email,
+		user.Password.value(),
+		user.Salary,
+		user.UpdatedBy,
+		user.ID,
+	).Scan(&user.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateEmail
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateContact updates the contact details of the user's profile, which users
+// may change without approval, in the database
+func (m UserModel) UpdateContact(user *User) error {
+	query := `
+		UPDATE users
+		SET phone = $1, address = $2, emergency_contact_name = $3, emergency_contact_phone = $4,
+			updated_by = $5, updated_at = now()
+		WHERE id = $6
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query,
+		user.Profile.Phone,
+		user.Profile.Address,
+		user.Profile.EmergencyContactName,
+		user.Profile.EmergencyContactPhone,
+		user.UpdatedBy,
+		user.ID,
+	).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// UpdatePassword stores the user's new password hash in the database
+func (m UserModel) UpdatePassword(user *User) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1, updated_by = $2, updated_at = now()
+		WHERE id = $3
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, user.Password.hash, user.UpdatedBy, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Activate stores the password a pending user chose, if any, and marks their
+// account as activated in the database. Users activated by logging in with
+// single sign-on have no password. It returns ErrActivated if the account was
+// already activated.
+func (m UserModel) Activate(user *User) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1, activated = true, updated_by = $2, updated_at = now()
+		WHERE id = $2 AND NOT activated
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, user.Password.value(), user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrActivated
+		}
+		return err
+	}
+
+	user.Activated = true
+	user.UpdatedBy = user.ID
+	return nil
+}
+
+// SetActive deactivates a user, who can then no longer log in, or reactivates
+// them in the database
+func (m UserModel) SetActive(user *User, active bool, updatedBy int64) error {
+	query := `
+		UPDATE users
+		SET active = $1, updated_by = $2, updated_at = now(),
+			deactivated_at = CASE WHEN $1 THEN NULL ELSE COALESCE(deactivated_at, now()) END
+		WHERE id = $3
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, active, updatedBy, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	user.Active = active
+	user.UpdatedBy = updatedBy
+	return nil
+}
+
+// SetOffice assigns a user to an office (nil to unassign) in the database
+func (m UserModel) SetOffice(user *User, officeID *int64, updatedBy int64) error {
+	query := `
+		UPDATE users
+		SET office_id = $1, updated_by = $2, updated_at = now()
+		WHERE id = $3
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, officeID, updatedBy, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	user.OfficeID = officeID
+	user.UpdatedBy = updatedBy
+	return nil
+}
+
+// SetRemoteAllowed exempts a user from, or subjects them to, their office's
+// geofence in the database
+func (m UserModel) SetRemoteAllowed(user *User, remoteAllowed bool, updatedBy int64) error {
+	query := `
+		UPDATE users
+		SET remote_allowed = $1, updated_by = $2, updated_at = now()
+		WHERE id = $3
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, remoteAllowed, updatedBy, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	user.RemoteAllowed = remoteAllowed
+	user.UpdatedBy = updatedBy
+	return nil
 }
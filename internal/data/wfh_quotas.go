@@ -0,0 +1,64 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// WFHQuota struct holds the number of days per month an employee may work from
+// home. Employees without a quota may not work from home.
+type WFHQuota struct {
+	EmployeeID   int64     `json:"employee_id"`
+	DaysPerMonth int       `json:"days_per_month"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	UpdatedBy    int64     `json:"updated_by"`
+}
+
+// WFHQuotaModel struct wraps the connection pool
+type WFHQuotaModel struct {
+	DB *sql.DB
+}
+
+// Get returns the WFH quota of the employee, or ErrRecordNotFound if none is set
+func (m WFHQuotaModel) Get(employeeID int64) (*WFHQuota, error) {
+	query := `
+		SELECT employee_id, days_per_month, updated_at, updated_by
+		FROM wfh_quotas
+		WHERE employee_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var q WFHQuota
+	err := m.DB.QueryRowContext(ctx, query, employeeID).Scan(
+		&q.EmployeeID,
+		&q.DaysPerMonth,
+		&q.UpdatedAt,
+		&q.UpdatedBy,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &q, nil
+}
+
+// Set creates or replaces the WFH quota of the employee
+func (m WFHQuotaModel) Set(q *WFHQuota) error {
+	query := `
+		INSERT INTO wfh_quotas (employee_id, days_per_month, updated_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (employee_id) DO UPDATE
+		SET days_per_month = EXCLUDED.days_per_month, updated_by = EXCLUDED.updated_by, updated_at = now()
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, q.EmployeeID, q.DaysPerMonth, q.UpdatedBy).Scan(&q.UpdatedAt)
+}
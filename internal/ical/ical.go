@@ -0,0 +1,132 @@
+// Package ical writes iCalendar (RFC 5545) files with the subset of the format
+// calendar apps need to subscribe to a feed of events: all-day and timed
+// events with a summary and description.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Maximum length of a content line in octets, excluding the line break
+const maxLineOctets = 75
+
+// Event struct is a calendar event. All-day events last from Start to End
+// (inclusive) in whichever time zone the calendar is viewed in; only their
+// dates are used. Timed events happen from Start to End as instants.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	AllDay      bool
+	Start       time.Time
+	End         time.Time
+}
+
+// Calendar struct is a named collection of events
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []*Event
+}
+
+// WriteTo writes the calendar in iCalendar format. Every event is stamped with
+// the current time, as calendar feeds are generated on demand.
+func (c *Calendar) WriteTo(w io.Writer) (int64, error) {
+	cw := &writer{w: bufio.NewWriter(w)}
+	stamp := formatTime(time.Now())
+
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", c.ProdID)
+	cw.line("CALSCALE", "GREGORIAN")
+	cw.line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		cw.line("X-WR-CALNAME", escape(c.Name))
+	}
+
+	for _, e := range c.Events {
+		cw.line("BEGIN", "VEVENT")
+		cw.line("UID", escape(e.UID))
+		cw.line("DTSTAMP", stamp)
+		if e.AllDay {
+			// The end date of all-day events is exclusive
+			cw.line("DTSTART;VALUE=DATE", e.Start.Format("20060102"))
+			cw.line("DTEND;VALUE=DATE", e.End.AddDate(0, 0, 1).Format("20060102"))
+			cw.line("TRANSP", "TRANSPARENT")
+		} else {
+			cw.line("DTSTART", formatTime(e.Start))
+			cw.line("DTEND", formatTime(e.End))
+		}
+		cw.line("SUMMARY", escape(e.Summary))
+		if e.Description != "" {
+			cw.line("DESCRIPTION", escape(e.Description))
+		}
+		cw.line("END", "VEVENT")
+	}
+
+	cw.line("END", "VCALENDAR")
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// formatTime formats an instant in UTC, which needs no time zone definition
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escape escapes the characters with a meaning in TEXT values
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// writer writes content lines, remembering the first error
+type writer struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+// line writes a content line, folded into lines of at most maxLineOctets
+// octets without splitting UTF-8 characters. Continuation lines start with a
+// space, which counts towards their length.
+func (cw *writer) line(name, value string) {
+	s := name + ":" + value
+	limit := maxLineOctets
+	for cw.err == nil {
+		if len(s) <= limit {
+			cw.write(s + "\r\n")
+			return
+		}
+
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		cw.write(s[:cut] + "\r\n ")
+		s = s[cut:]
+		limit = maxLineOctets - 1
+	}
+}
+
+func (cw *writer) write(s string) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.WriteString(s)
+	cw.n += int64(n)
+	cw.err = err
+}
@@ -0,0 +1,385 @@
+// Package keys manages the asymmetric keys signing the JWT access tokens.
+// Keys are stored in the database so that every API server signs and verifies
+// with the same keys, and are rotated on a schedule: a new key is published a
+// while before it starts signing, and an old key is published until the tokens
+// it signed have expired. Other services verify tokens with the public keys
+// served as a JSON Web Key Set.
+package keys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/moniquelin/monday-hr/internal/data"
+	"github.com/moniquelin/monday-hr/internal/secretbox"
+)
+
+// Signing algorithms
+const (
+	EdDSA = "EdDSA"
+	RS256 = "RS256"
+)
+
+// Algorithms lists the signing algorithms tokens are accepted with. Anything
+// else, in particular HS256 and "none", is rejected by the parser.
+var Algorithms = []string{EdDSA, RS256}
+
+const (
+	// How often the keys are rotated if due and reloaded from the database
+	refreshInterval = time.Minute
+	// Minimum time between two reloads caused by tokens with an unknown kid
+	reloadInterval = 10 * time.Second
+	// Size of generated RSA keys in bits
+	rsaKeySize = 2048
+)
+
+var (
+	ErrUnknownKey     = errors.New("unknown signing key")
+	ErrNoSigningKey   = errors.New("no signing key")
+	errWrongAlgorithm = errors.New("signing algorithm does not match the key")
+)
+
+// key struct is a signing key decoded from the database
+type key struct {
+	kid         string
+	algorithm   string
+	private     crypto.Signer
+	public      crypto.PublicKey
+	signFrom    time.Time
+	signUntil   time.Time
+	verifyUntil time.Time
+}
+
+// Keyring struct holds the signing keys, reloaded from the database
+type Keyring struct {
+	model     data.SigningKeyModel
+	logger    *log.Logger
+	box       *secretbox.Box
+	algorithm string
+	// How long a key signs tokens
+	rotation time.Duration
+	// How long a new key is published before it signs tokens
+	publishAhead time.Duration
+	// How long a key is published after it stopped signing tokens
+	verifyAfter time.Duration
+
+	mu         sync.RWMutex
+	keys       []*key
+	lastReload time.Time
+}
+
+// NewKeyring returns a keyring generating keys for the algorithm, which sign
+// for the rotation period and verify tokens valid for up to tokenTTL. Private
+// keys are encrypted with a key derived from the secret. The keys are rotated
+// if due and loaded before it returns.
+func NewKeyring(model data.SigningKeyModel, secret, algorithm string, rotation, tokenTTL time.Duration, logger *log.Logger) (*Keyring, error) {
+	if !slices.Contains(Algorithms, algorithm) {
+		return nil, fmt.Errorf("unsupported signing algorithm %q, must be %s or %s", algorithm, EdDSA, RS256)
+	}
+	if rotation < 2*refreshInterval {
+		return nil, fmt.Errorf("key rotation period must be at least %s", 2*refreshInterval)
+	}
+
+	box, err := secretbox.New(secret, "monday-hr signing keys")
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Keyring{
+		model:        model,
+		logger:       logger,
+		box:          box,
+		algorithm:    algorithm,
+		rotation:     rotation,
+		publishAhead: min(24*time.Hour, rotation/2),
+		verifyAfter:  tokenTTL + refreshInterval,
+	}
+
+	err = k.refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Run rotates the keys when due and reloads them, so that keys created by other
+// servers are known. It never returns.
+func (k *Keyring) Run() {
+	for range time.Tick(refreshInterval) {
+		err := k.refresh()
+		if err != nil {
+			k.logger.Printf("signing keys: %v", err)
+		}
+	}
+}
+
+// refresh creates the next key if it is due to be published, deletes expired
+// keys and reloads them
+func (k *Keyring) refresh() error {
+	err := k.model.DeleteExpired()
+	if err != nil {
+		return err
+	}
+
+	err = k.reload()
+	if err != nil {
+		return err
+	}
+
+	// The next key starts signing when the last one stops, or right away if
+	// there is none
+	now := time.Now()
+	k.mu.RLock()
+	signFrom := now
+	if n := len(k.keys); n > 0 && k.keys[n-1].signUntil.After(now) {
+		signFrom = k.keys[n-1].signUntil
+	}
+	k.mu.RUnlock()
+
+	if signFrom.After(now.Add(k.publishAhead)) {
+		return nil
+	}
+
+	created, err := k.create(signFrom)
+	if err != nil {
+		return err
+	}
+	if created {
+		k.logger.Printf("signing keys: created a %s key signing from %s", k.algorithm, signFrom.Format(time.RFC3339))
+	}
+
+	return k.reload()
+}
+
+// create generates a key signing from the time and stores it. It reports false
+// if another server created the key first.
+func (k *Keyring) create(signFrom time.Time) (bool, error) {
+	var private crypto.Signer
+	var err error
+	switch k.algorithm {
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeySize)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return false, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return false, err
+	}
+
+	// The kid is derived from the public key, as in RFC 7638
+	sum := sha256.Sum256(publicDER)
+
+	privateKey, err := k.box.Seal(privateDER, nil)
+	if err != nil {
+		return false, err
+	}
+
+	signFrom = signFrom.Truncate(time.Second)
+	signUntil := signFrom.Add(k.rotation)
+
+	return k.model.Insert(&data.SigningKey{
+		KID:         base64.RawURLEncoding.EncodeToString(sum[:16]),
+		Algorithm:   k.algorithm,
+		PrivateKey:  privateKey,
+		PublicKey:   publicDER,
+		SignFrom:    signFrom,
+		SignUntil:   signUntil,
+		VerifyUntil: signUntil.Add(k.verifyAfter),
+	})
+}
+
+// reload loads the keys from the database
+func (k *Keyring) reload() error {
+	stored, err := k.model.GetAll()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]*key, 0, len(stored))
+	for _, sk := range stored {
+		decoded, err := k.decode(sk)
+		if err != nil {
+			return fmt.Errorf("decoding key %s: %w", sk.KID, err)
+		}
+		keys = append(keys, decoded)
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.lastReload = time.Now()
+	k.mu.Unlock()
+
+	return nil
+}
+
+// decode decrypts and parses a key from the database
+func (k *Keyring) decode(sk *data.SigningKey) (*key, error) {
+	privateDER, err := k.box.Open(sk.PrivateKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(privateDER)
+	if err != nil {
+		return nil, err
+	}
+
+	var private crypto.Signer
+	switch sk.Algorithm {
+	case EdDSA:
+		private, _ = parsed.(ed25519.PrivateKey)
+	case RS256:
+		private, _ = parsed.(*rsa.PrivateKey)
+	}
+	if private == nil {
+		return nil, errWrongAlgorithm
+	}
+
+	return &key{
+		kid:         sk.KID,
+		algorithm:   sk.Algorithm,
+		private:     private,
+		public:      private.Public(),
+		signFrom:    sk.SignFrom,
+		signUntil:   sk.SignUntil,
+		verifyUntil: sk.VerifyUntil,
+	}, nil
+}
+
+// Sign signs the claims with the current signing key
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	now := time.Now()
+
+	k.mu.RLock()
+	var current *key
+	for _, key := range k.keys {
+		if !key.signFrom.After(now) && key.signUntil.After(now) {
+			current = key
+		}
+	}
+	k.mu.RUnlock()
+
+	if current == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(current.algorithm), claims)
+	token.Header["kid"] = current.kid
+
+	return token.SignedString(current.private)
+}
+
+// Keyfunc returns the public key verifying the token, as identified by its kid
+// header. The token must be signed with the algorithm of the key. The keys are
+// reloaded if the kid is unknown, in case another server just created it.
+func (k *Keyring) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrUnknownKey
+	}
+
+	key := k.find(kid)
+	if key == nil {
+		k.mu.RLock()
+		stale := time.Since(k.lastReload) > reloadInterval
+		k.mu.RUnlock()
+
+		if stale {
+			err := k.reload()
+			if err != nil {
+				return nil, err
+			}
+			key = k.find(kid)
+		}
+	}
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+
+	if token.Method.Alg() != key.algorithm {
+		return nil, errWrongAlgorithm
+	}
+
+	return key.public, nil
+}
+
+// find returns the key with the kid which still verifies tokens, or nil
+func (k *Keyring) find(kid string) *key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range k.keys {
+		if key.kid == kid && key.verifyUntil.After(now) {
+			return key
+		}
+	}
+	return nil
+}
+
+// JWK struct is a public key in the JSON Web Key format of RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KID       string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	// Ed25519 keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// JWKS returns the public keys which verify tokens, including the next key
+// before it starts signing
+func (k *Keyring) JWKS() []JWK {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now()
+	jwks := []JWK{}
+	for _, key := range k.keys {
+		if !key.verifyUntil.After(now) {
+			continue
+		}
+
+		jwk := JWK{KID: key.kid, Algorithm: key.algorithm, Use: "sig"}
+		switch public := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	return jwks
+}
@@ -0,0 +1,137 @@
+// Package live fans out Postgres notifications to the clients of the API
+// instance. Every instance listens on the channel with its own connection, so a
+// change committed through any instance reaches the clients of all of them.
+package live
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Kinds of events
+const (
+	// EventNotification carries the payload of a notification
+	EventNotification = "notification"
+	// EventReset tells subscribers that notifications may have been missed
+	// while the connection to the database was lost, so any state built from
+	// them must be reloaded
+	EventReset = "reset"
+)
+
+// Size of a subscriber's buffer. A subscriber which falls further behind is
+// dropped rather than blocking the others.
+const subscriberBuffer = 64
+
+// Event struct is delivered to the subscribers of a Broker
+type Event struct {
+	Kind    string
+	Payload string
+}
+
+// Broker listens on a Postgres channel and broadcasts its notifications to the
+// subscribers
+type Broker struct {
+	listener *pq.Listener
+	logger   *log.Logger
+
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewBroker opens a dedicated connection to the database and starts listening
+// on the channel. Notifications are only delivered once Run is called.
+func NewBroker(dsn, channel string, logger *log.Logger) (*Broker, error) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Printf("live: %v", err)
+		}
+	})
+
+	err := listener.Listen(channel)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &Broker{
+		listener:    listener,
+		logger:      logger,
+		subscribers: map[chan Event]struct{}{},
+	}, nil
+}
+
+// Run broadcasts the notifications until Close is called
+func (b *Broker) Run() {
+	for {
+		select {
+		case n, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+			// pq sends nil after re-establishing a lost connection
+			if n == nil {
+				b.publish(Event{Kind: EventReset})
+				continue
+			}
+			b.publish(Event{Kind: EventNotification, Payload: n.Extra})
+		case <-time.After(90 * time.Second):
+			// Check the connection is still alive when the channel is quiet
+			go func() {
+				if err := b.listener.Ping(); err != nil {
+					b.logger.Printf("live: %v", err)
+				}
+			}()
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the events broadcast from now on, and
+// a function to unsubscribe. The channel is closed when unsubscribing, or if
+// the subscriber does not keep up with the events.
+func (b *Broker) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close stops listening and disconnects every subscriber
+func (b *Broker) Close() error {
+	err := b.listener.Close()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+
+	return err
+}
+
+func (b *Broker) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package mailer sends the emails of the application, such as password reset
+// links. Emails are rendered from the templates embedded in the binary and sent
+// by a Mailer: over SMTP, or only written to the log during development.
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"embed"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+//go:embed "templates"
+var templateFS embed.FS
+
+// Time allowed to deliver an email to the SMTP server
+const sendTimeout = 10 * time.Second
+
+// Mailer sends the email rendered from the template file with the data to the
+// recipient
+type Mailer interface {
+	Send(recipient, templateFile string, data any) error
+}
+
+// render executes the "subject" and "plainBody" templates of the file
+func render(templateFile string, data any) (string, string, error) {
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return "", "", err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return "", "", err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return subject.String(), plainBody.String(), nil
+}
+
+// SMTP struct sends emails through an SMTP server, upgrading the connection
+// with STARTTLS when the server supports it. A local stand-in such as Mailpit
+// or MailHog can be used during development.
+type SMTP struct {
+	host     string
+	port     int
+	username string
+	password string
+	sender   string
+}
+
+// NewSMTP returns a mailer sending through the SMTP server at host:port. The
+// username may be empty for servers which do not require authentication.
+func NewSMTP(host string, port int, username, password, sender string) *SMTP {
+	return &SMTP{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		sender:   sender,
+	}
+}
+
+// Send renders the email and delivers it to the SMTP server
+func (m *SMTP) Send(recipient, templateFile string, data any) error {
+	subject, body, err := render(templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	msg, err := m.message(recipient, subject, body)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(m.host, strconv.Itoa(m.port)), sendTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(sendTimeout))
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: m.host})
+		if err != nil {
+			return err
+		}
+	}
+	if m.username != "" {
+		// PlainAuth refuses to send credentials unencrypted, except to localhost
+		err = c.Auth(smtp.PlainAuth("", m.username, m.password, m.host))
+		if err != nil {
+			return err
+		}
+	}
+
+	from, err := mail.ParseAddress(m.sender)
+	if err != nil {
+		return err
+	}
+	err = c.Mail(from.Address)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(recipient)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// message builds the MIME message of a plain text email
+func (m *SMTP) message(recipient, subject, body string) ([]byte, error) {
+	msg := new(bytes.Buffer)
+	fmt.Fprintf(msg, "From: %s\r\n", m.sender)
+	fmt.Fprintf(msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	msg.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(msg)
+	_, err := qp.Write([]byte(body))
+	if err != nil {
+		return nil, err
+	}
+	err = qp.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return msg.Bytes(), nil
+}
+
+// Log struct only writes emails to the log instead of sending them, for
+// development without an SMTP server
+type Log struct {
+	logger *log.Logger
+}
+
+// NewLog returns a mailer writing emails to the logger
+func NewLog(logger *log.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+// Send renders the email and writes it to the log
+func (m *Log) Send(recipient, templateFile string, data any) error {
+	subject, body, err := render(templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	m.logger.Printf("mailer: email to %s\nSubject: %s\n%s", recipient, subject, body)
+	return nil
+}
@@ -0,0 +1,336 @@
+// Package oidc implements the client side of the OpenID Connect authorization
+// code flow with PKCE, used to log users in with the company's identity
+// provider. The provider's endpoints and keys are discovered from its issuer
+// URL, and the ID tokens it returns are verified before their claims are
+// trusted.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// Scopes requested from the provider
+	scopes = "openid email profile"
+	// Minimum time between two fetches of the provider's keys caused by ID
+	// tokens with an unknown kid
+	keysRefetchInterval = 10 * time.Second
+	// Allowed difference between our clock and the provider's
+	clockSkew = time.Minute
+)
+
+// Algorithms ID tokens are accepted with. Symmetric algorithms and "none" are
+// rejected.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+var (
+	// ErrRejected is returned when the provider refuses the authorization code
+	ErrRejected = errors.New("authorization code rejected by the identity provider")
+	// ErrInvalidIDToken is returned when the ID token fails verification
+	ErrInvalidIDToken = errors.New("invalid ID token")
+)
+
+// Claims struct holds the claims of a verified ID token the application uses
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider struct is an OpenID Connect provider the application is registered
+// with as a client
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	client       *http.Client
+
+	mu            sync.Mutex
+	metadata      *metadata
+	keys          []jwk
+	keysFetchedAt time.Time
+}
+
+// metadata struct holds the parts of the provider's discovery document the
+// flow uses
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewProvider returns the provider with the issuer URL, which the client is
+// registered with under the ID and redirect URL. The secret may be empty for
+// public clients, which rely on PKCE alone. The provider is only contacted
+// when a user first logs in, so that the API starts while it is unreachable.
+func NewProvider(issuer, clientID, clientSecret, redirectURL string) *Provider {
+	return &Provider{
+		issuer:       issuer,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// discover fetches the provider's discovery document, once it succeeded
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var md metadata
+	err := p.getJSON(ctx, strings.TrimSuffix(p.issuer, "/")+"/.well-known/openid-configuration", &md)
+	if err != nil {
+		return nil, fmt.Errorf("discovering the identity provider: %w", err)
+	}
+
+	// The document must be the issuer's own, or its ID tokens could be forged
+	// by whoever served it
+	if md.Issuer != p.issuer {
+		return nil, fmt.Errorf("discovering the identity provider: issuer %q does not match %q", md.Issuer, p.issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, errors.New("discovering the identity provider: missing endpoints")
+	}
+
+	p.metadata = &md
+	return p.metadata, nil
+}
+
+// AuthCodeURL returns the URL of the provider's login page, which redirects
+// the user back to the redirect URL with an authorization code and the state.
+// The code can only be exchanged with the verifier, and the ID token must
+// carry the nonce.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.clientID)
+	params.Set("redirect_uri", p.redirectURL)
+	params.Set("scope", scopes)
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return md.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems the authorization code with the PKCE verifier it was
+// requested with, and returns the claims of the verified ID token. It returns
+// ErrRejected if the provider refuses the code, and ErrInvalidIDToken if the
+// ID token fails verification or does not carry the nonce.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("code_verifier", verifier)
+	if p.clientSecret == "" {
+		form.Set("client_id", p.clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("exchanging the authorization code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body)
+	if err != nil {
+		return nil, fmt.Errorf("exchanging the authorization code: %s: %w", resp.Status, err)
+	}
+
+	// The provider answers 400 to invalid, expired or reused codes, and to
+	// verifiers which do not match the challenge. Other errors, such as 401 to
+	// a wrong client secret, are ours.
+	if resp.StatusCode == http.StatusBadRequest && body.Error != "invalid_client" {
+		return nil, fmt.Errorf("%w: %s %s", ErrRejected, body.Error, body.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchanging the authorization code: %s: %s %s", resp.Status, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return nil, fmt.Errorf("%w: missing from the token response", ErrInvalidIDToken)
+	}
+
+	return p.verify(ctx, md, body.IDToken, nonce)
+}
+
+// idTokenClaims struct holds the claims of an ID token
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string `json:"nonce"`
+	AuthorizedParty string `json:"azp"`
+	Email           string `json:"email"`
+	// A boolean, or a "true" or "false" string with some providers
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// verify checks the signature, issuer, audience, lifetime and nonce of the ID
+// token, and returns its claims
+func (p *Provider) verify(ctx context.Context, md *metadata, idToken, nonce string) (*Claims, error) {
+	var c idTokenClaims
+	_, err := jwt.ParseWithClaims(idToken, &c, p.keyfunc(ctx, md),
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(md.Issuer),
+		jwt.WithAudience(p.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	// A token issued to several clients must have been requested by us
+	if len(c.Audience) > 1 && c.AuthorizedParty != p.clientID {
+		return nil, fmt.Errorf("%w: issued to another client", ErrInvalidIDToken)
+	}
+
+	// The nonce ties the token to the login it was requested for, so that a
+	// token obtained elsewhere cannot be replayed
+	if subtle.ConstantTimeCompare([]byte(c.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	verified := c.EmailVerified == true || c.EmailVerified == "true"
+
+	return &Claims{
+		Subject:       c.Subject,
+		Email:         c.Email,
+		EmailVerified: verified,
+		Name:          c.Name,
+	}, nil
+}
+
+// keyfunc returns the provider's public key verifying the token, as identified
+// by its kid header. The keys are fetched again if the kid is unknown, as the
+// provider may have rotated them.
+func (p *Provider) keyfunc(ctx context.Context, md *metadata) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		key := findKey(p.keys, kid, token.Method.Alg())
+		if key == nil && time.Since(p.keysFetchedAt) > keysRefetchInterval {
+			var set jwkSet
+			err := p.getJSON(ctx, md.JWKSURI, &set)
+			if err != nil {
+				return nil, fmt.Errorf("fetching the identity provider's keys: %w", err)
+			}
+			p.keys = set.Keys
+			p.keysFetchedAt = time.Now()
+
+			key = findKey(p.keys, kid, token.Method.Alg())
+		}
+		if key == nil {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+
+		return key.publicKey()
+	}
+}
+
+// findKey returns the signing key with the kid, or the only signing key if the
+// token has no kid. A key restricted to another algorithm is not returned.
+func findKey(keys []jwk, kid, alg string) *jwk {
+	var candidates []*jwk
+	for i := range keys {
+		key := &keys[i]
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if key.Algorithm != "" && key.Algorithm != alg {
+			continue
+		}
+		if kid == "" || key.KID == kid {
+			candidates = append(candidates, key)
+		}
+	}
+
+	if len(candidates) != 1 {
+		return nil
+	}
+	return candidates[0]
+}
+
+// getJSON fetches the URL and decodes its JSON body into dst
+func (p *Provider) getJSON(ctx context.Context, rawURL string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dst)
+}
@@ -0,0 +1,67 @@
+// Package secretbox encrypts the secrets stored in the database, such as the
+// token signing keys and the TOTP secrets, with AES-256-GCM. Each use derives
+// its own key from the application secret, so that the keys of different uses
+// are never the same.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+var ErrDecrypt = errors.New("cannot decrypt, was the JWT secret changed?")
+
+// Box struct encrypts and decrypts with a key derived from the secret
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New returns a box with the key derived from the secret for the use named by
+// the label
+func New(secret, label string) (*Box, error) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(label))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts the plaintext, with a random nonce prepended to the result. The
+// additional data, e.g. the ID of the row, is authenticated but not stored, and
+// must be given again to open it.
+func (b *Box) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts what Seal encrypted. It returns ErrDecrypt if it was encrypted
+// with another key or additional data, or was tampered with.
+func (b *Box) Open(ciphertext, additionalData []byte) ([]byte, error) {
+	size := b.aead.NonceSize()
+	if len(ciphertext) < size {
+		return nil, ErrDecrypt
+	}
+
+	plaintext, err := b.aead.Open(nil, ciphertext[:size], ciphertext[size:], additionalData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
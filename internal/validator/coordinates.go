@@ -0,0 +1,24 @@
+package validator
+
+// ValidateCoordinates checks if the latitude and longitude are valid. Both must
+// be provided together, or not at all.
+func ValidateCoordinates(v *Validator, latitude, longitude *float64) {
+	v.Check((latitude == nil) == (longitude == nil), "latitude", "must be provided together with longitude")
+	if latitude != nil {
+		v.Check(*latitude >= -90 && *latitude <= 90, "latitude", "must be between -90 and 90")
+	}
+	if longitude != nil {
+		v.Check(*longitude >= -180 && *longitude <= 180, "longitude", "must be between -180 and 180")
+	}
+}
+
+// ValidateGeofence checks if an office geofence is valid. Either all of the
+// latitude, longitude and radius are provided, or none of them.
+func ValidateGeofence(v *Validator, latitude, longitude *float64, radiusM *int) {
+	ValidateCoordinates(v, latitude, longitude)
+	v.Check((latitude == nil) == (radiusM == nil), "radius_m", "must be provided together with latitude and longitude")
+	if radiusM != nil {
+		v.Check(*radiusM >= 10, "radius_m", "must be at least 10 meters")
+		v.Check(*radiusM <= 100000, "radius_m", "must not be more than 100000 meters")
+	}
+}
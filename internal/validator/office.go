@@ -0,0 +1,13 @@
+package validator
+
+import "time"
+
+// ValidateOffice checks if the office name and IANA time zone are valid
+func ValidateOffice(v *Validator, name, timezone string) {
+	v.Check(name != "", "name", "must be provided")
+	v.Check(len(name) <= 255, "name", "must not be more than 255 bytes long")
+
+	v.Check(timezone != "", "timezone", "must be provided")
+	_, err := time.LoadLocation(timezone)
+	v.Check(err == nil && timezone != "Local", "timezone", "must be a valid IANA time zone, e.g. Asia/Jakarta")
+}
@@ -0,0 +1,27 @@
+package validator
+
+import "regexp"
+
+var (
+	// PhoneRX matches phone numbers written with digits, spaces, dashes and
+	// parentheses, optionally starting with a + country code
+	PhoneRX = regexp.MustCompile(`^\+?[0-9][0-9 ()-]{5,30}$`)
+
+	// BankAccountRX matches bank account numbers, including IBANs
+	BankAccountRX = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z -]{3,63}$`)
+)
+
+// ValidatePhone checks if the phone number is valid. An empty number clears it.
+func ValidatePhone(v *Validator, phone, field string) {
+	v.Check(phone == "" || Matches(phone, PhoneRX), field, "must be a valid phone number")
+}
+
+// ValidateBankAccount checks if the bank account details are valid. The bank
+// name, account number and holder must be provided together.
+func ValidateBankAccount(v *Validator, bankName, accountNumber, accountHolder string) {
+	v.Check(bankName != "", "bank_name", "must be provided")
+	v.Check(len(bankName) <= 255, "bank_name", "must not be more than 255 bytes long")
+	v.Check(Matches(accountNumber, BankAccountRX), "bank_account_number", "must be a valid bank account number")
+	v.Check(accountHolder != "", "bank_account_holder", "must be provided")
+	v.Check(len(accountHolder) <= 255, "bank_account_holder", "must not be more than 255 bytes long")
+}
@@ -0,0 +1,21 @@
+package validator
+
+// ValidateCode checks if a short identifier such as a project or cost center
+// code is valid
+func ValidateCode(v *Validator, code string) {
+	v.Check(code != "", "code", "must be provided")
+	v.Check(len(code) <= 32, "code", "must not be more than 32 bytes long")
+}
+
+// ValidateProject checks if the project code, name and client are valid
+func ValidateProject(v *Validator, code, name string, client *string) {
+	ValidateCode(v, code)
+
+	v.Check(name != "", "name", "must be provided")
+	v.Check(len(name) <= 255, "name", "must not be more than 255 bytes long")
+
+	if client != nil {
+		v.Check(*client != "", "client", "must not be empty")
+		v.Check(len(*client) <= 255, "client", "must not be more than 255 bytes long")
+	}
+}
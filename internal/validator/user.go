@@ -0,0 +1,14 @@
+package validator
+
+// ValidateUser checks if the user's name, email, role and salary are valid.
+// Who may grant a role is checked by the handlers: only super admins can manage
+// admins and super admins.
+func ValidateUser(v *Validator, name, email, role string, salary int64) {
+	v.Check(name != "", "name", "must be provided")
+	v.Check(len(name) <= 255, "name", "must not be more than 255 bytes long")
+
+	ValidateEmail(v, email)
+
+	v.Check(In(role, "employee", "admin", "super_admin"), "role", "must be employee, admin or super_admin")
+	v.Check(salary >= 0, "salary", "must not be negative")
+}
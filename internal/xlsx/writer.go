@@ -0,0 +1,143 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	nsMain          = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	nsRelationships = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	nsPackageRels   = "http://schemas.openxmlformats.org/package/2006/relationships"
+)
+
+// Writer streams a spreadsheet with a single sheet row by row, so that large
+// sheets never have to be held in memory
+type Writer struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+}
+
+// NewWriter writes the parts of the spreadsheet which precede the rows of its
+// sheet to w, and returns a Writer for the rows
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+
+	var name strings.Builder
+	xml.EscapeText(&name, []byte(sheetName))
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="` + nsPackageRels + `">` +
+			`<Relationship Id="rId1" Type="` + nsRelationships + `/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<workbook xmlns="` + nsMain + `" xmlns:r="` + nsRelationships + `">` +
+			`<sheets><sheet name="` + name.String() + `" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="` + nsPackageRels + `">` +
+			`<Relationship Id="rId1" Type="` + nsRelationships + `/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+	}
+
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	// The sheet is the last part, so its rows can be streamed until Close
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := bufio.NewWriter(f)
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<worksheet xmlns="` + nsMain + `"><sheetData>`)
+
+	return &Writer{zw: zw, sheet: sheet}, nil
+}
+
+// WriteRow appends a row to the sheet. Cells may be strings, integers or
+// floats, or pointers to them; nil cells are left empty.
+func (w *Writer) WriteRow(cells ...any) error {
+	w.rows++
+	fmt.Fprintf(w.sheet, `<row r="%d">`, w.rows)
+
+	for _, cell := range cells {
+		switch v := cell.(type) {
+		case nil:
+			w.sheet.WriteString(`<c/>`)
+		case *string:
+			if v == nil {
+				w.sheet.WriteString(`<c/>`)
+				continue
+			}
+			w.writeString(*v)
+		case string:
+			w.writeString(v)
+		case int:
+			w.writeNumber(strconv.Itoa(v))
+		case int64:
+			w.writeNumber(strconv.FormatInt(v, 10))
+		case float64:
+			w.writeNumber(strconv.FormatFloat(v, 'f', -1, 64))
+		case *int64:
+			if v == nil {
+				w.sheet.WriteString(`<c/>`)
+				continue
+			}
+			w.writeNumber(strconv.FormatInt(*v, 10))
+		case *float64:
+			if v == nil {
+				w.sheet.WriteString(`<c/>`)
+				continue
+			}
+			w.writeNumber(strconv.FormatFloat(*v, 'f', -1, 64))
+		default:
+			return fmt.Errorf("xlsx: unsupported cell type %T", cell)
+		}
+	}
+
+	_, err := w.sheet.WriteString(`</row>`)
+	return err
+}
+
+// Close finishes the sheet and the spreadsheet. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	w.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+func (w *Writer) writeString(s string) {
+	w.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+	xml.EscapeText(w.sheet, []byte(s))
+	w.sheet.WriteString(`</t></is></c>`)
+}
+
+func (w *Writer) writeNumber(s string) {
+	w.sheet.WriteString(`<c><v>` + s + `</v></c>`)
+}
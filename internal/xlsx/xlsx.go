@@ -0,0 +1,230 @@
+// Package xlsx reads and writes the cell values of simple Office Open XML
+// spreadsheets, as exported by attendance devices and spreadsheet applications.
+// Formatting, formulas and every sheet but the first are ignored.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidFile = errors.New("not a valid XLSX file")
+
+const (
+	// Maximum uncompressed size of a part of the file, against zip bombs
+	maxPartBytes = 64 << 20
+	// Number of columns of a sheet in Excel
+	maxColumns = 16384
+)
+
+// ReadRows returns the rows of the first worksheet as strings. Numbers are
+// returned as stored, so dates and times are Excel serial numbers, see
+// ParseSerial. Missing cells are returned as empty strings.
+func ReadRows(r io.ReaderAt, size int64) ([][]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrInvalidFile
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheet, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		shared, err = readSharedStrings(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, ok := files[sheet]
+	if !ok {
+		return nil, ErrInvalidFile
+	}
+
+	return readSheet(f, shared)
+}
+
+// ParseSerial converts an Excel serial date-time (days since 1899-12-30, the
+// fraction being the time of day) to a wall-clock time in the given location
+func ParseSerial(value string, loc *time.Location) (time.Time, error) {
+	serial, err := strconv.ParseFloat(value, 64)
+	if err != nil || serial < 0 || serial > 2958465 { // 9999-12-31
+		return time.Time{}, fmt.Errorf("%q is not a serial date", value)
+	}
+
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 86400)
+	return time.Date(1899, 12, 30+int(days), 0, 0, int(seconds), 0, loc), nil
+}
+
+// firstSheetPath resolves the path of the first worksheet through the workbook
+// and its relationships
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodePart(files["xl/workbook.xml"], &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", ErrInvalidFile
+	}
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodePart(files["xl/_rels/workbook.xml.rels"], &rels); err != nil {
+		return "", err
+	}
+
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RelID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+
+	return "", ErrInvalidFile
+}
+
+// richText is a string which may be split into formatted runs
+type richText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t richText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+func readSharedStrings(f *zip.File) ([]string, error) {
+	var sst struct {
+		Items []richText `xml:"si"`
+	}
+	if err := decodePart(f, &sst); err != nil {
+		return nil, err
+	}
+
+	shared := make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		shared[i] = item.String()
+	}
+	return shared, nil
+}
+
+func readSheet(f *zip.File, shared []string) ([][]string, error) {
+	var ws struct {
+		Rows []struct {
+			Cells []struct {
+				Ref       string   `xml:"r,attr"`
+				Type      string   `xml:"t,attr"`
+				Value     string   `xml:"v"`
+				InlineStr richText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodePart(f, &ws); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(ws.Rows))
+	for _, row := range ws.Rows {
+		var values []string
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				var err error
+				col, err = columnIndex(c.Ref)
+				if err != nil || col >= maxColumns {
+					return nil, ErrInvalidFile
+				}
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+
+			switch c.Type {
+			case "s":
+				idx, err := strconv.Atoi(c.Value)
+				if err != nil || idx < 0 || idx >= len(shared) {
+					return nil, ErrInvalidFile
+				}
+				values[col] = shared[idx]
+			case "inlineStr":
+				values[col] = c.InlineStr.String()
+			default:
+				values[col] = c.Value
+			}
+		}
+		rows = append(rows, values)
+	}
+
+	return rows, nil
+}
+
+// columnIndex returns the zero-based column of a cell reference such as "C12"
+func columnIndex(ref string) (int, error) {
+	col := 0
+	for i, r := range ref {
+		if r >= 'A' && r <= 'Z' {
+			col = col*26 + int(r-'A'+1)
+			continue
+		}
+		if i == 0 {
+			return 0, ErrInvalidFile
+		}
+		break
+	}
+	return col - 1, nil
+}
+
+func decodePart(f *zip.File, v any) error {
+	if f == nil {
+		return ErrInvalidFile
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return ErrInvalidFile
+	}
+	defer rc.Close()
+
+	err = xml.NewDecoder(io.LimitReader(rc, maxPartBytes)).Decode(v)
+	if err != nil {
+		return ErrInvalidFile
+	}
+	return nil
+}
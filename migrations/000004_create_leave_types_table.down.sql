@@ -0,0 +1 @@
+DROP TABLE IF EXISTS leave_types;
\ No newline at end of file
@@ -0,0 +1,28 @@
+CREATE TABLE leave_types (
+  id                        BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  code                      TEXT           NOT NULL UNIQUE,
+  name                      VARCHAR(255)   NOT NULL,
+  is_paid                   BOOLEAN        NOT NULL DEFAULT true,
+  requires_attachment       BOOLEAN        NOT NULL DEFAULT false,
+  tracks_balance            BOOLEAN        NOT NULL DEFAULT false,
+
+  -- Days granted at the start of every year (only used when tracks_balance is true)
+  annual_accrual_days       INTEGER        NOT NULL DEFAULT 0,
+  -- Unused days which can be carried over to the next year, and how many months
+  -- into the next year they remain usable
+  max_carry_over_days       INTEGER        NOT NULL DEFAULT 0,
+  carry_over_expiry_months  INTEGER        NOT NULL DEFAULT 0,
+
+  created_at                TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  updated_at                TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT chk_leave_types_days CHECK (
+    annual_accrual_days >= 0 AND max_carry_over_days >= 0 AND carry_over_expiry_months BETWEEN 0 AND 12
+  )
+);
+
+INSERT INTO leave_types (code, name, is_paid, requires_attachment, tracks_balance, annual_accrual_days, max_carry_over_days, carry_over_expiry_months)
+VALUES
+  ('annual', 'Annual Leave', true,  false, true,  12, 6, 3),
+  ('sick',   'Sick Leave',   true,  true,  false, 0,  0, 0),
+  ('unpaid', 'Unpaid Leave', false, false, false, 0,  0, 0);
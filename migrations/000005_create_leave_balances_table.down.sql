@@ -0,0 +1 @@
+DROP TABLE IF EXISTS leave_balances;
\ No newline at end of file
@@ -0,0 +1,19 @@
+CREATE TABLE leave_balances (
+  id                      BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  employee_id             BIGINT  NOT NULL REFERENCES users(id),
+  leave_type_id           BIGINT  NOT NULL REFERENCES leave_types(id),
+  year                    INTEGER NOT NULL,
+
+  accrued_days            INTEGER NOT NULL DEFAULT 0,
+  used_days               INTEGER NOT NULL DEFAULT 0,
+  carried_over_days       INTEGER NOT NULL DEFAULT 0,
+  carried_over_used_days  INTEGER NOT NULL DEFAULT 0,
+  carry_over_expires_on   DATE,
+
+  created_at              TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  updated_at              TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+
+  UNIQUE (employee_id, leave_type_id, year),
+  CONSTRAINT chk_leave_balances_used CHECK (used_days BETWEEN 0 AND accrued_days),
+  CONSTRAINT chk_leave_balances_carried_over_used CHECK (carried_over_used_days BETWEEN 0 AND carried_over_days)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS leave_requests;
+
+DROP TYPE IF EXISTS leave_status;
\ No newline at end of file
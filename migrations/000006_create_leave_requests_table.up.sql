@@ -0,0 +1,39 @@
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TYPE leave_status AS ENUM ('pending', 'approved', 'rejected', 'cancelled');
+
+CREATE TABLE leave_requests (
+  id                       BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  employee_id              BIGINT       NOT NULL REFERENCES users(id),
+  leave_type_id            BIGINT       NOT NULL REFERENCES leave_types(id),
+  start_date               DATE         NOT NULL,
+  end_date                 DATE         NOT NULL,
+  days                     INTEGER      NOT NULL,
+  reason                   TEXT         NOT NULL DEFAULT '',
+  status                   leave_status NOT NULL DEFAULT 'pending',
+
+  -- Supporting document, e.g. a doctor's note for sick leave
+  attachment_name          TEXT,
+  attachment_content_type  TEXT,
+  attachment               BYTEA,
+
+  reviewed_by              BIGINT REFERENCES users(id),
+  reviewed_at              TIMESTAMPTZ(0),
+  review_note              TEXT         NOT NULL DEFAULT '',
+
+  created_at               TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  updated_at               TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  created_by               BIGINT,
+  updated_by               BIGINT,
+
+  CONSTRAINT fk_leave_created_by FOREIGN KEY (created_by) REFERENCES users(id),
+  CONSTRAINT fk_leave_updated_by FOREIGN KEY (updated_by) REFERENCES users(id),
+  CONSTRAINT chk_leave_date_order CHECK (end_date >= start_date),
+  CONSTRAINT chk_leave_days CHECK (days > 0),
+
+  -- An employee cannot have two active requests covering the same day
+  CONSTRAINT leave_requests_prevent_overlap EXCLUDE USING GIST (
+      employee_id WITH =,
+      daterange(start_date, end_date, '[]') WITH &&
+    ) WHERE (status IN ('pending', 'approved'))
+);
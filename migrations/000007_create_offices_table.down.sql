@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS office_id;
+
+DROP TABLE IF EXISTS offices;
\ No newline at end of file
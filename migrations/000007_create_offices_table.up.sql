@@ -0,0 +1,16 @@
+CREATE TABLE offices (
+  id           BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  name         VARCHAR(255)   NOT NULL UNIQUE,
+  -- IANA time zone name, e.g. Asia/Jakarta, Asia/Makassar, Asia/Jayapura
+  timezone     TEXT           NOT NULL DEFAULT 'Asia/Jakarta',
+
+  created_at   TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  updated_at   TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  created_by   BIGINT,
+  updated_by   BIGINT,
+
+  CONSTRAINT fk_offices_created_by FOREIGN KEY (created_by) REFERENCES users(id),
+  CONSTRAINT fk_offices_updated_by FOREIGN KEY (updated_by) REFERENCES users(id)
+);
+
+ALTER TABLE users ADD COLUMN office_id BIGINT REFERENCES offices(id);
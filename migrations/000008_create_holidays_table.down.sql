@@ -0,0 +1 @@
+DROP TABLE IF EXISTS holidays;
\ No newline at end of file
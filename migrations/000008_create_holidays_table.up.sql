@@ -0,0 +1,17 @@
+CREATE TABLE holidays (
+  id            BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  holiday_date  DATE           NOT NULL,
+  name          VARCHAR(255)   NOT NULL,
+  -- NULL for national holidays, otherwise only observed by the given office
+  office_id     BIGINT REFERENCES offices(id),
+
+  created_at    TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  updated_at    TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  created_by    BIGINT,
+  updated_by    BIGINT,
+
+  CONSTRAINT fk_holidays_created_by FOREIGN KEY (created_by) REFERENCES users(id),
+  CONSTRAINT fk_holidays_updated_by FOREIGN KEY (updated_by) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX holidays_date_office_key ON holidays (holiday_date, COALESCE(office_id, 0));
@@ -0,0 +1,13 @@
+ALTER TABLE attendance
+  DROP COLUMN IF EXISTS checkin_latitude,
+  DROP COLUMN IF EXISTS checkin_longitude,
+  DROP COLUMN IF EXISTS checkout_latitude,
+  DROP COLUMN IF EXISTS checkout_longitude;
+
+ALTER TABLE users DROP COLUMN IF EXISTS remote_allowed;
+
+ALTER TABLE offices
+  DROP CONSTRAINT IF EXISTS chk_offices_geofence,
+  DROP COLUMN IF EXISTS latitude,
+  DROP COLUMN IF EXISTS longitude,
+  DROP COLUMN IF EXISTS radius_m;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- Geofence of an office: check-ins must be within radius_m meters of the point.
+-- Offices without coordinates have no geofence.
+ALTER TABLE offices
+  ADD COLUMN latitude   DOUBLE PRECISION,
+  ADD COLUMN longitude  DOUBLE PRECISION,
+  ADD COLUMN radius_m   INTEGER,
+  ADD CONSTRAINT chk_offices_geofence CHECK (
+    (latitude IS NULL AND longitude IS NULL AND radius_m IS NULL) OR
+    (latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180 AND radius_m > 0)
+  );
+
+-- Remote-allowed employees may check in from anywhere
+ALTER TABLE users ADD COLUMN remote_allowed BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE attendance
+  ADD COLUMN checkin_latitude    DOUBLE PRECISION,
+  ADD COLUMN checkin_longitude   DOUBLE PRECISION,
+  ADD COLUMN checkout_latitude   DOUBLE PRECISION,
+  ADD COLUMN checkout_longitude  DOUBLE PRECISION;
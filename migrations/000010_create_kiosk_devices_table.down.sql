@@ -0,0 +1,7 @@
+ALTER TABLE attendance
+  DROP COLUMN IF EXISTS checkin_kiosk_id,
+  DROP COLUMN IF EXISTS checkout_kiosk_id;
+
+ALTER TABLE offices DROP COLUMN IF EXISTS kiosk_required;
+
+DROP TABLE IF EXISTS kiosk_devices;
\ No newline at end of file
@@ -0,0 +1,24 @@
+CREATE TABLE kiosk_devices (
+  id            BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  name          VARCHAR(255)   NOT NULL,
+  office_id     BIGINT         NOT NULL REFERENCES offices(id),
+  -- SHA-256 hash of the device token the kiosk authenticates with
+  token_hash    BYTEA          NOT NULL UNIQUE,
+  last_seen_at  TIMESTAMPTZ(0),
+  revoked_at    TIMESTAMPTZ(0),
+
+  created_at    TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  updated_at    TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  created_by    BIGINT,
+  updated_by    BIGINT,
+
+  CONSTRAINT fk_kiosk_created_by FOREIGN KEY (created_by) REFERENCES users(id),
+  CONSTRAINT fk_kiosk_updated_by FOREIGN KEY (updated_by) REFERENCES users(id)
+);
+
+-- Employees of offices requiring a kiosk must scan the kiosk's QR code to check in
+ALTER TABLE offices ADD COLUMN kiosk_required BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE attendance
+  ADD COLUMN checkin_kiosk_id   BIGINT REFERENCES kiosk_devices(id),
+  ADD COLUMN checkout_kiosk_id  BIGINT REFERENCES kiosk_devices(id);
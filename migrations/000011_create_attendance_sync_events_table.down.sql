@@ -0,0 +1 @@
+DROP TABLE IF EXISTS attendance_sync_events;
\ No newline at end of file
@@ -0,0 +1,21 @@
+-- Check-in/check-out events recorded offline by employees' devices, keyed by the
+-- client-generated UUID so replayed batches are applied only once
+CREATE TABLE attendance_sync_events (
+  id             UUID           PRIMARY KEY,
+  employee_id    BIGINT         NOT NULL REFERENCES users(id),
+  device_id      TEXT           NOT NULL,
+  kind           TEXT           NOT NULL,
+  occurred_at    TIMESTAMPTZ    NOT NULL,
+
+  status         TEXT           NOT NULL DEFAULT 'pending',
+  error          JSONB,
+  attendance_id  BIGINT REFERENCES attendance(id),
+
+  created_at     TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  updated_at     TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT chk_sync_events_kind CHECK (kind IN ('checkin', 'checkout')),
+  CONSTRAINT chk_sync_events_status CHECK (status IN ('pending', 'applied', 'rejected'))
+);
+
+CREATE INDEX attendance_sync_events_employee_idx ON attendance_sync_events (employee_id, occurred_at);
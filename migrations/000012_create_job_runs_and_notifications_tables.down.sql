@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS notifications;
+
+DROP TABLE IF EXISTS job_runs;
+
+ALTER TABLE attendance DROP COLUMN IF EXISTS auto_closed;
\ No newline at end of file
@@ -0,0 +1,29 @@
+-- Attendance closed by the auto-checkout job because the employee forgot to check out
+ALTER TABLE attendance ADD COLUMN auto_closed BOOLEAN NOT NULL DEFAULT false;
+
+-- One row per scheduled job and day it ran for, so missed runs can be caught up
+CREATE TABLE job_runs (
+  id             BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  job_name       TEXT           NOT NULL,
+  run_date       DATE           NOT NULL,
+  status         TEXT           NOT NULL DEFAULT 'running',
+  affected_rows  INTEGER        NOT NULL DEFAULT 0,
+  error          TEXT,
+  started_at     TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  finished_at    TIMESTAMPTZ(0),
+
+  UNIQUE (job_name, run_date),
+  CONSTRAINT chk_job_runs_status CHECK (status IN ('running', 'succeeded', 'failed'))
+);
+
+CREATE TABLE notifications (
+  id          BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  user_id     BIGINT         NOT NULL REFERENCES users(id),
+  kind        TEXT           NOT NULL,
+  title       VARCHAR(255)   NOT NULL,
+  message     TEXT           NOT NULL,
+  read_at     TIMESTAMPTZ(0),
+  created_at  TIMESTAMPTZ(0) NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX notifications_user_idx ON notifications (user_id, created_at DESC);
@@ -0,0 +1,38 @@
+ALTER TABLE attendance_sync_events DROP CONSTRAINT chk_sync_events_kind;
+UPDATE attendance_sync_events SET kind = 'checkin' WHERE kind = 'in';
+UPDATE attendance_sync_events SET kind = 'checkout' WHERE kind = 'out';
+DELETE FROM attendance_sync_events WHERE kind IN ('break_start', 'break_end');
+ALTER TABLE attendance_sync_events ADD CONSTRAINT chk_sync_events_kind CHECK (kind IN ('checkin', 'checkout'));
+
+ALTER TABLE attendance
+  ADD COLUMN checkin_latitude    DOUBLE PRECISION,
+  ADD COLUMN checkin_longitude   DOUBLE PRECISION,
+  ADD COLUMN checkout_latitude   DOUBLE PRECISION,
+  ADD COLUMN checkout_longitude  DOUBLE PRECISION,
+  ADD COLUMN checkin_kiosk_id    BIGINT REFERENCES kiosk_devices(id),
+  ADD COLUMN checkout_kiosk_id   BIGINT REFERENCES kiosk_devices(id);
+
+-- Restore the location of the first check-in and last check-out of each day
+UPDATE attendance a
+SET checkin_latitude = p.latitude, checkin_longitude = p.longitude, checkin_kiosk_id = p.kiosk_id
+FROM (
+  SELECT DISTINCT ON (attendance_id) attendance_id, latitude, longitude, kiosk_id
+  FROM attendance_punches WHERE kind = 'in'
+  ORDER BY attendance_id, punched_at, id
+) p
+WHERE p.attendance_id = a.id;
+
+UPDATE attendance a
+SET checkout_latitude = p.latitude, checkout_longitude = p.longitude, checkout_kiosk_id = p.kiosk_id
+FROM (
+  SELECT DISTINCT ON (attendance_id) attendance_id, latitude, longitude, kiosk_id
+  FROM attendance_punches WHERE kind = 'out'
+  ORDER BY attendance_id, punched_at DESC, id DESC
+) p
+WHERE p.attendance_id = a.id AND a.checkout_at IS NOT NULL;
+
+ALTER TABLE attendance
+  DROP COLUMN IF EXISTS worked_minutes,
+  DROP COLUMN IF EXISTS break_minutes;
+
+DROP TABLE IF EXISTS attendance_punches;
\ No newline at end of file
@@ -0,0 +1,53 @@
+-- Punch events of an attendance day. An employee may check in and out several
+-- times a day and take breaks; the attendance row summarizes the punches.
+CREATE TABLE attendance_punches (
+  id             BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  attendance_id  BIGINT           NOT NULL REFERENCES attendance(id) ON DELETE CASCADE,
+  kind           TEXT             NOT NULL,
+  punched_at     TIMESTAMPTZ      NOT NULL,
+  latitude       DOUBLE PRECISION,
+  longitude      DOUBLE PRECISION,
+  kiosk_id       BIGINT REFERENCES kiosk_devices(id),
+
+  created_at     TIMESTAMPTZ(0)   NOT NULL DEFAULT NOW(),
+  created_by     BIGINT,
+
+  CONSTRAINT fk_punch_created_by FOREIGN KEY (created_by) REFERENCES users(id),
+  CONSTRAINT chk_punches_kind CHECK (kind IN ('in', 'out', 'break_start', 'break_end'))
+);
+
+CREATE INDEX attendance_punches_attendance_idx ON attendance_punches (attendance_id, punched_at);
+
+-- Backfill the punches of existing attendance
+INSERT INTO attendance_punches (attendance_id, kind, punched_at, latitude, longitude, kiosk_id, created_at, created_by)
+SELECT id, 'in', checkin_at, checkin_latitude, checkin_longitude, checkin_kiosk_id, created_at, created_by
+FROM attendance;
+
+INSERT INTO attendance_punches (attendance_id, kind, punched_at, latitude, longitude, kiosk_id, created_at, created_by)
+SELECT id, 'out', checkout_at, checkout_latitude, checkout_longitude, checkout_kiosk_id, updated_at, updated_by
+FROM attendance
+WHERE checkout_at IS NOT NULL;
+
+-- checkin_at is now the first check-in of the day and checkout_at the last
+-- check-out, NULL while the employee is checked in. Net worked and break time
+-- are computed from the punches.
+ALTER TABLE attendance
+  ADD COLUMN worked_minutes  INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN break_minutes   INTEGER NOT NULL DEFAULT 0;
+
+UPDATE attendance
+SET worked_minutes = floor(extract(epoch FROM checkout_at - checkin_at) / 60)
+WHERE checkout_at IS NOT NULL;
+
+ALTER TABLE attendance
+  DROP COLUMN checkin_latitude,
+  DROP COLUMN checkin_longitude,
+  DROP COLUMN checkout_latitude,
+  DROP COLUMN checkout_longitude,
+  DROP COLUMN checkin_kiosk_id,
+  DROP COLUMN checkout_kiosk_id;
+
+-- Offline events may now be any punch
+ALTER TABLE attendance_sync_events DROP CONSTRAINT chk_sync_events_kind;
+ALTER TABLE attendance_sync_events ADD CONSTRAINT chk_sync_events_kind
+  CHECK (kind IN ('checkin', 'checkout', 'in', 'out', 'break_start', 'break_end'));
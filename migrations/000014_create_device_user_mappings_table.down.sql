@@ -0,0 +1 @@
+DROP TABLE IF EXISTS device_user_mappings;
\ No newline at end of file
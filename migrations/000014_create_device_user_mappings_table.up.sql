@@ -0,0 +1,12 @@
+-- Maps the user IDs enrolled on biometric attendance devices to employees, for
+-- importing the punch logs the devices export
+CREATE TABLE device_user_mappings (
+  id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  device_user_id  VARCHAR(64)    NOT NULL UNIQUE,
+  user_id         BIGINT         NOT NULL REFERENCES users(id),
+
+  created_at      TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  created_by      BIGINT,
+
+  CONSTRAINT fk_device_mapping_created_by FOREIGN KEY (created_by) REFERENCES users(id)
+);
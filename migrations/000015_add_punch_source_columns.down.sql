@@ -0,0 +1,4 @@
+ALTER TABLE attendance_punches
+  DROP COLUMN IF EXISTS ip_address,
+  DROP COLUMN IF EXISTS user_agent,
+  DROP COLUMN IF EXISTS device_id;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- Where a punch was recorded from, for detecting attendance anomalies
+ALTER TABLE attendance_punches
+  ADD COLUMN ip_address  INET,
+  ADD COLUMN user_agent  TEXT,
+  ADD COLUMN device_id   TEXT;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS wfh_quotas;
+
+ALTER TABLE attendance DROP COLUMN IF EXISTS attendance_type;
+
+DROP TABLE IF EXISTS attendance_types;
\ No newline at end of file
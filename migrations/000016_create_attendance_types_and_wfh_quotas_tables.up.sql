@@ -0,0 +1,37 @@
+-- Where an employee works on an attendance day. Only office days are subject to
+-- the office geofence and kiosk; the meal allowance is paid per attended day.
+CREATE TABLE attendance_types (
+  id                 BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  code               TEXT           NOT NULL UNIQUE,
+  name               VARCHAR(255)   NOT NULL,
+  at_office          BOOLEAN        NOT NULL DEFAULT false,
+  meal_allowance     BIGINT         NOT NULL DEFAULT 0,
+
+  created_at         TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  updated_at         TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT chk_attendance_types_meal_allowance CHECK (meal_allowance >= 0)
+);
+
+INSERT INTO attendance_types (code, name, at_office, meal_allowance)
+VALUES
+  ('office',        'Office',         true,  50000),
+  ('wfh',           'Work From Home', false, 0),
+  ('business_trip', 'Business Trip',  false, 150000),
+  ('client_site',   'Client Site',    false, 75000);
+
+ALTER TABLE attendance
+  ADD COLUMN attendance_type TEXT NOT NULL DEFAULT 'office' REFERENCES attendance_types(code);
+
+-- Number of days per month an employee may work from home. Employees without a
+-- quota may not work from home.
+CREATE TABLE wfh_quotas (
+  employee_id     BIGINT         PRIMARY KEY REFERENCES users(id),
+  days_per_month  INTEGER        NOT NULL,
+
+  updated_at      TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  updated_by      BIGINT,
+
+  CONSTRAINT fk_wfh_quota_updated_by FOREIGN KEY (updated_by) REFERENCES users(id),
+  CONSTRAINT chk_wfh_quotas_days CHECK (days_per_month BETWEEN 0 AND 31)
+);
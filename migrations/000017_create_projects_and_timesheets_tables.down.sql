@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS timesheet_entries;
+
+DROP TABLE IF EXISTS projects;
+
+DROP TABLE IF EXISTS cost_centers;
\ No newline at end of file
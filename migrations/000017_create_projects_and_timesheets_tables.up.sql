@@ -0,0 +1,48 @@
+-- Cost centers group projects for accounting
+CREATE TABLE cost_centers (
+  id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  code            VARCHAR(32)    NOT NULL UNIQUE,
+  name            VARCHAR(255)   NOT NULL,
+
+  created_at      TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  created_by      BIGINT,
+
+  CONSTRAINT fk_cost_center_created_by FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+-- Client projects employees allocate their worked hours to. Inactive projects
+-- keep their timesheets but no longer accept new hours.
+CREATE TABLE projects (
+  id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  code            VARCHAR(32)    NOT NULL UNIQUE,
+  name            VARCHAR(255)   NOT NULL,
+  client          VARCHAR(255),
+  cost_center_id  BIGINT         NOT NULL REFERENCES cost_centers(id),
+  active          BOOLEAN        NOT NULL DEFAULT true,
+
+  created_at      TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  updated_at      TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  created_by      BIGINT,
+  updated_by      BIGINT,
+
+  CONSTRAINT fk_project_created_by FOREIGN KEY (created_by) REFERENCES users(id),
+  CONSTRAINT fk_project_updated_by FOREIGN KEY (updated_by) REFERENCES users(id)
+);
+
+-- Minutes of an attendance day allocated to a project
+CREATE TABLE timesheet_entries (
+  id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  attendance_id   BIGINT         NOT NULL REFERENCES attendance(id) ON DELETE CASCADE,
+  project_id      BIGINT         NOT NULL REFERENCES projects(id),
+  minutes         INTEGER        NOT NULL,
+  note            TEXT,
+
+  created_at      TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  created_by      BIGINT,
+
+  CONSTRAINT fk_timesheet_entry_created_by FOREIGN KEY (created_by) REFERENCES users(id),
+  CONSTRAINT uq_timesheet_entries_project UNIQUE (attendance_id, project_id),
+  CONSTRAINT chk_timesheet_entries_minutes CHECK (minutes > 0)
+);
+
+CREATE INDEX idx_timesheet_entries_project ON timesheet_entries (project_id);
@@ -0,0 +1,10 @@
+ALTER TABLE attendance
+  ADD CONSTRAINT chk_att_weekday CHECK (EXTRACT(DOW FROM att_date) BETWEEN 2 AND 6) NOT VALID;
+
+DROP TABLE IF EXISTS shift_swap_requests;
+
+DROP TYPE IF EXISTS shift_swap_status;
+
+DROP TABLE IF EXISTS roster_entries;
+
+DROP TABLE IF EXISTS shifts;
\ No newline at end of file
@@ -0,0 +1,78 @@
+-- Shift templates. A shift ending at or before its start time ends on the next
+-- day. On-call shifts are standby duty paid with a fixed allowance.
+CREATE TABLE shifts (
+  id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  code            VARCHAR(32)    NOT NULL UNIQUE,
+  name            VARCHAR(255)   NOT NULL,
+  start_time      TIME(0)        NOT NULL,
+  end_time        TIME(0)        NOT NULL,
+  on_call         BOOLEAN        NOT NULL DEFAULT false,
+  allowance       BIGINT         NOT NULL DEFAULT 0,
+
+  created_at      TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  updated_at      TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  created_by      BIGINT,
+  updated_by      BIGINT,
+
+  CONSTRAINT fk_shift_created_by FOREIGN KEY (created_by) REFERENCES users(id),
+  CONSTRAINT fk_shift_updated_by FOREIGN KEY (updated_by) REFERENCES users(id),
+  CONSTRAINT chk_shifts_allowance CHECK (allowance >= 0)
+);
+
+-- Shifts assigned to employees. starts_at and ends_at are resolved in the time
+-- zone of the employee's office when the entry is rostered.
+CREATE TABLE roster_entries (
+  id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  employee_id     BIGINT         NOT NULL REFERENCES users(id),
+  shift_id        BIGINT         NOT NULL REFERENCES shifts(id),
+  shift_date      DATE           NOT NULL,
+  starts_at       TIMESTAMPTZ(0) NOT NULL,
+  ends_at         TIMESTAMPTZ(0) NOT NULL,
+
+  created_at      TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  updated_at      TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  created_by      BIGINT,
+  updated_by      BIGINT,
+
+  CONSTRAINT fk_roster_created_by FOREIGN KEY (created_by) REFERENCES users(id),
+  CONSTRAINT fk_roster_updated_by FOREIGN KEY (updated_by) REFERENCES users(id),
+  CONSTRAINT chk_roster_time_order CHECK (ends_at > starts_at),
+
+  -- An employee cannot be rostered on two overlapping shifts
+  CONSTRAINT roster_entries_prevent_overlap EXCLUDE USING GIST (
+      employee_id WITH =,
+      tstzrange(starts_at, ends_at) WITH &&
+    )
+);
+
+CREATE INDEX idx_roster_entries_date ON roster_entries (shift_date);
+
+CREATE TYPE shift_swap_status AS ENUM ('pending', 'accepted', 'declined', 'approved', 'rejected', 'cancelled');
+
+-- Requests to hand a rostered shift over to a peer, optionally taking one of the
+-- peer's shifts in exchange. The peer accepts first, then an admin approves.
+CREATE TABLE shift_swap_requests (
+  id                 BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  requester_id       BIGINT            NOT NULL REFERENCES users(id),
+  requester_entry_id BIGINT            NOT NULL REFERENCES roster_entries(id) ON DELETE CASCADE,
+  peer_id            BIGINT            NOT NULL REFERENCES users(id),
+  peer_entry_id      BIGINT            REFERENCES roster_entries(id) ON DELETE CASCADE,
+  reason             TEXT              NOT NULL DEFAULT '',
+  status             shift_swap_status NOT NULL DEFAULT 'pending',
+  responded_at       TIMESTAMPTZ(0),
+  reviewed_by        BIGINT            REFERENCES users(id),
+  reviewed_at        TIMESTAMPTZ(0),
+  review_note        TEXT              NOT NULL DEFAULT '',
+
+  created_at         TIMESTAMPTZ(0)    NOT NULL DEFAULT NOW(),
+  updated_at         TIMESTAMPTZ(0)    NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT chk_shift_swap_peer CHECK (peer_id <> requester_id)
+);
+
+-- A shift can only be offered in one open swap at a time
+CREATE UNIQUE INDEX uq_shift_swap_requests_open ON shift_swap_requests (requester_entry_id)
+  WHERE status IN ('pending', 'accepted');
+
+-- Rostered shifts may fall on weekends
+ALTER TABLE attendance DROP CONSTRAINT IF EXISTS chk_att_weekday;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS calendar_feeds;
+
+ALTER TABLE payroll_periods DROP COLUMN IF EXISTS pay_date;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- Date salaries for the period are paid out, shown in the employees' calendars
+ALTER TABLE payroll_periods ADD COLUMN pay_date DATE;
+
+-- One calendar feed per user, read by calendar apps without logging in
+CREATE TABLE calendar_feeds (
+  user_id           BIGINT         PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  -- SHA-256 hash of the token in the feed's URL
+  token_hash        BYTEA          NOT NULL UNIQUE,
+  last_accessed_at  TIMESTAMPTZ(0),
+  created_at        TIMESTAMPTZ(0) NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+-- Deactivated users can no longer log in, but their records are kept
+ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS profile_change_requests;
+
+DROP TYPE IF EXISTS profile_change_status;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS phone,
+  DROP COLUMN IF EXISTS address,
+  DROP COLUMN IF EXISTS emergency_contact_name,
+  DROP COLUMN IF EXISTS emergency_contact_phone,
+  DROP COLUMN IF EXISTS bank_name,
+  DROP COLUMN IF EXISTS bank_account_number,
+  DROP COLUMN IF EXISTS bank_account_holder;
\ No newline at end of file
@@ -0,0 +1,36 @@
+ALTER TABLE users
+  ADD COLUMN phone                    VARCHAR(32)  NOT NULL DEFAULT '',
+  ADD COLUMN address                  TEXT         NOT NULL DEFAULT '',
+  ADD COLUMN emergency_contact_name   VARCHAR(255) NOT NULL DEFAULT '',
+  ADD COLUMN emergency_contact_phone  VARCHAR(32)  NOT NULL DEFAULT '',
+  ADD COLUMN bank_name                VARCHAR(255) NOT NULL DEFAULT '',
+  ADD COLUMN bank_account_number      VARCHAR(64)  NOT NULL DEFAULT '',
+  ADD COLUMN bank_account_holder      VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE TYPE profile_change_status AS ENUM ('pending', 'approved', 'rejected', 'cancelled');
+
+-- Changes to sensitive profile fields requested by users, applied once approved
+-- by an admin. NULL fields are left unchanged.
+CREATE TABLE profile_change_requests (
+  id                   BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  user_id              BIGINT                NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name                 VARCHAR(255),
+  bank_name            VARCHAR(255),
+  bank_account_number  VARCHAR(64),
+  bank_account_holder  VARCHAR(255),
+  status               profile_change_status NOT NULL DEFAULT 'pending',
+
+  reviewed_by          BIGINT REFERENCES users(id),
+  reviewed_at          TIMESTAMPTZ(0),
+  review_note          TEXT                  NOT NULL DEFAULT '',
+
+  created_at           TIMESTAMPTZ(0)        NOT NULL DEFAULT NOW(),
+  updated_at           TIMESTAMPTZ(0)        NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT chk_profile_change_fields CHECK (
+    name IS NOT NULL OR bank_name IS NOT NULL OR bank_account_number IS NOT NULL OR bank_account_holder IS NOT NULL
+  )
+);
+
+-- A user has at most one pending change request at a time
+CREATE UNIQUE INDEX profile_change_requests_pending_key ON profile_change_requests (user_id) WHERE status = 'pending';
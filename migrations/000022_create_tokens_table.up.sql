@@ -0,0 +1,11 @@
+-- Single-use tokens emailed to users, such as password reset tokens
+CREATE TABLE tokens (
+  -- SHA-256 hash of the token, which is only known to its recipient
+  hash        BYTEA          PRIMARY KEY,
+  user_id     BIGINT         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  scope       TEXT           NOT NULL,
+  expiry      TIMESTAMPTZ(0) NOT NULL,
+  created_at  TIMESTAMPTZ(0) NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX tokens_user_scope_idx ON tokens (user_id, scope);
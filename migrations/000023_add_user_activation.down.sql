@@ -0,0 +1,3 @@
+UPDATE users SET password_hash = ''::bytea WHERE password_hash IS NULL;
+ALTER TABLE users ALTER COLUMN password_hash SET NOT NULL;
+ALTER TABLE users DROP COLUMN IF EXISTS activated;
\ No newline at end of file
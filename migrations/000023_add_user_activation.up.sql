@@ -0,0 +1,4 @@
+-- Invited users set their own password when they activate their account, so
+-- they have none until then. Existing users are already activated.
+ALTER TABLE users ADD COLUMN activated BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE users ALTER COLUMN password_hash DROP NOT NULL;
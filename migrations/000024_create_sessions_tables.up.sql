@@ -0,0 +1,30 @@
+-- A session starts when a user logs in and lasts as long as its refresh
+-- tokens keep being rotated. All the refresh tokens of a session form a family
+-- which is revoked as a whole.
+CREATE TABLE sessions (
+  id          BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  user_id     BIGINT         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  revoked_at  TIMESTAMPTZ(0),
+  created_at  TIMESTAMPTZ(0) NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX sessions_user_idx ON sessions (user_id);
+
+-- Refresh tokens are single-use: each refresh marks the token as used and
+-- issues the next one of the session
+CREATE TABLE refresh_tokens (
+  -- SHA-256 hash of the token, which is only known to the client
+  hash        BYTEA          PRIMARY KEY,
+  session_id  BIGINT         NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+  expiry      TIMESTAMPTZ(0) NOT NULL,
+  used_at     TIMESTAMPTZ(0),
+  created_at  TIMESTAMPTZ(0) NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX refresh_tokens_session_idx ON refresh_tokens (session_id);
+
+-- Access tokens revoked before they expire, by the jti claim
+CREATE TABLE revoked_access_tokens (
+  jti         TEXT           PRIMARY KEY,
+  expiry      TIMESTAMPTZ(0) NOT NULL
+);
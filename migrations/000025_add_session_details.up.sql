@@ -0,0 +1,6 @@
+-- Where each session was started from, so users can recognise their logins
+ALTER TABLE sessions ADD COLUMN device TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+-- Address the session was last seen from
+ALTER TABLE sessions ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMPTZ(0) NOT NULL DEFAULT NOW();
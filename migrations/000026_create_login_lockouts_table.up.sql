@@ -0,0 +1,17 @@
+-- Failed logins, counted per account (by email, whether or not a user has it)
+-- and per client IP address. Reaching the limit locks logins out for a time
+-- which doubles with each lockout.
+CREATE TABLE login_lockouts (
+  id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  kind            TEXT           NOT NULL,
+  key             TEXT           NOT NULL,
+  -- Failures since the last lockout, within the counting window
+  failures        INTEGER        NOT NULL DEFAULT 0,
+  lockouts        INTEGER        NOT NULL DEFAULT 0,
+  last_failed_at  TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+  locked_until    TIMESTAMPTZ(0),
+
+  CONSTRAINT uq_login_lockouts_kind_key UNIQUE (kind, key),
+  CONSTRAINT chk_login_lockouts_kind CHECK (kind IN ('account', 'ip')),
+  CONSTRAINT chk_login_lockouts_counts CHECK (failures >= 0 AND lockouts >= 0)
+);
@@ -0,0 +1,23 @@
+-- TOTP secret of a user's authenticator app. Two-factor authentication is only
+-- enabled once the user confirmed a code, until then the enrolment is pending.
+CREATE TABLE user_totp (
+  user_id         BIGINT         PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  -- Encrypted with a key derived from the JWT secret, the nonce prepended
+  secret          BYTEA          NOT NULL,
+  enabled_at      TIMESTAMPTZ(0),
+  -- Time step of the last accepted code, so that a code cannot be used twice
+  last_used_step  BIGINT         NOT NULL DEFAULT 0,
+  created_at      TIMESTAMPTZ(0) NOT NULL DEFAULT NOW()
+);
+
+-- Single-use codes to log in without the authenticator app
+CREATE TABLE recovery_codes (
+  id          BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  user_id     BIGINT         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  -- SHA-256 hash of the code, which is only shown to the user once
+  hash        BYTEA          NOT NULL,
+  used_at     TIMESTAMPTZ(0),
+  created_at  TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT uq_recovery_codes_user_hash UNIQUE (user_id, hash)
+);
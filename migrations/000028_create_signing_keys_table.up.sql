@@ -0,0 +1,19 @@
+-- Keys signing the JWT access tokens, identified by the kid header. The newest
+-- key whose signing period has started signs new tokens; keys are published
+-- for verification from their creation until the tokens they signed expired.
+CREATE TABLE signing_keys (
+  kid           TEXT           PRIMARY KEY,
+  algorithm     TEXT           NOT NULL,
+  -- PKCS #8 private key, encrypted with a key derived from the JWT secret
+  private_key   BYTEA          NOT NULL,
+  -- PKIX public key
+  public_key    BYTEA          NOT NULL,
+  sign_from     TIMESTAMPTZ    NOT NULL,
+  sign_until    TIMESTAMPTZ    NOT NULL,
+  verify_until  TIMESTAMPTZ    NOT NULL,
+  created_at    TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT uq_signing_keys_sign_from UNIQUE (sign_from),
+  CONSTRAINT chk_signing_keys_algorithm CHECK (algorithm IN ('EdDSA', 'RS256')),
+  CONSTRAINT chk_signing_keys_period_order CHECK (sign_until > sign_from AND verify_until >= sign_until)
+);
@@ -0,0 +1,12 @@
+-- Single sign-on logins in progress, from the redirect to the identity provider
+-- until the user comes back with an authorization code
+CREATE TABLE oidc_logins (
+  -- SHA-256 hash of the state parameter, which is only known to the client
+  state_hash     BYTEA          PRIMARY KEY,
+  -- PKCE code verifier, only sent to the identity provider along with the code
+  code_verifier  TEXT           NOT NULL,
+  -- Nonce the ID token must carry
+  nonce          TEXT           NOT NULL,
+  expiry         TIMESTAMPTZ(0) NOT NULL,
+  created_at     TIMESTAMPTZ(0) NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN IF EXISTS deactivated_at;
\ No newline at end of file
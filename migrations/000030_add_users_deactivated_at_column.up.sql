@@ -0,0 +1,8 @@
+-- When a user was deactivated, so that they are no longer expected to attend
+-- from then on. Users deactivated before are assumed to have been deactivated
+-- at their last update.
+ALTER TABLE users ADD COLUMN deactivated_at TIMESTAMPTZ(0);
+
+UPDATE users SET deactivated_at = updated_at WHERE NOT active;
+
+ALTER TABLE users ADD CONSTRAINT chk_users_deactivated_at CHECK ((deactivated_at IS NULL) = active);